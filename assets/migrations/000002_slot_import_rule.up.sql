@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS slot_import_rule (
+    id         INTEGER
+        PRIMARY KEY,
+    project_id INTEGER NOT NULL
+        REFERENCES project (id),
+    activity   INTEGER NOT NULL,
+    keyword    TEXT,
+    organizer  TEXT
+);
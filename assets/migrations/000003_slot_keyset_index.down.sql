@@ -0,0 +1 @@
+DROP INDEX IF EXISTS slot_started_at_id;
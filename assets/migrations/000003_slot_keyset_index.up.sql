@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS slot_started_at_id ON slot (started_at, id);
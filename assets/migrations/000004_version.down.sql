@@ -0,0 +1,18 @@
+DROP TRIGGER IF EXISTS client_revision_insert;
+DROP TRIGGER IF EXISTS client_revision_update;
+DROP TRIGGER IF EXISTS client_revision_delete;
+DROP TRIGGER IF EXISTS project_revision_insert;
+DROP TRIGGER IF EXISTS project_revision_update;
+DROP TRIGGER IF EXISTS project_revision_delete;
+DROP TRIGGER IF EXISTS slot_revision_insert;
+DROP TRIGGER IF EXISTS slot_revision_update;
+DROP TRIGGER IF EXISTS slot_revision_delete;
+
+DROP TABLE IF EXISTS revision;
+
+ALTER TABLE client
+    DROP COLUMN version;
+ALTER TABLE project
+    DROP COLUMN version;
+ALTER TABLE slot
+    DROP COLUMN version;
@@ -0,0 +1,57 @@
+ALTER TABLE client
+    ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE project
+    ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE slot
+    ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- revision counts the changes of a table, it changes the ETag of collections
+CREATE TABLE IF NOT EXISTS revision (
+    name  TEXT    NOT NULL
+        PRIMARY KEY,
+    value INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO revision (name)
+VALUES ('client'),
+       ('project'),
+       ('slot');
+
+CREATE TRIGGER IF NOT EXISTS client_revision_insert AFTER INSERT ON client
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'client';
+END;
+CREATE TRIGGER IF NOT EXISTS client_revision_update AFTER UPDATE ON client
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'client';
+END;
+CREATE TRIGGER IF NOT EXISTS client_revision_delete AFTER DELETE ON client
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'client';
+END;
+
+CREATE TRIGGER IF NOT EXISTS project_revision_insert AFTER INSERT ON project
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'project';
+END;
+CREATE TRIGGER IF NOT EXISTS project_revision_update AFTER UPDATE ON project
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'project';
+END;
+CREATE TRIGGER IF NOT EXISTS project_revision_delete AFTER DELETE ON project
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'project';
+END;
+
+CREATE TRIGGER IF NOT EXISTS slot_revision_insert AFTER INSERT ON slot
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'slot';
+END;
+CREATE TRIGGER IF NOT EXISTS slot_revision_update AFTER UPDATE ON slot
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'slot';
+END;
+CREATE TRIGGER IF NOT EXISTS slot_revision_delete AFTER DELETE ON slot
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'slot';
+END;
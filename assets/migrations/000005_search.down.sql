@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS client_search_insert;
+DROP TRIGGER IF EXISTS client_search_update;
+DROP TRIGGER IF EXISTS client_search_delete;
+DROP TRIGGER IF EXISTS project_search_insert;
+DROP TRIGGER IF EXISTS project_search_update;
+DROP TRIGGER IF EXISTS project_search_delete;
+DROP TRIGGER IF EXISTS slot_search_insert;
+DROP TRIGGER IF EXISTS slot_search_update;
+DROP TRIGGER IF EXISTS slot_search_delete;
+
+DROP TABLE IF EXISTS search;
@@ -0,0 +1,67 @@
+-- search indexes the texts of clients, projects and slots. The rowid is derived from the id and the type,
+-- 3 * id for clients, 3 * id + 1 for projects and 3 * id + 2 for slots, so triggers can update an entry
+-- without scanning the index. The columns type and ref_id are stored only.
+CREATE VIRTUAL TABLE IF NOT EXISTS search USING fts5
+(
+    type UNINDEXED,
+    ref_id UNINDEXED,
+    name,
+    description,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+INSERT INTO search (rowid, type, ref_id, name, description)
+SELECT 3 * id, 'client', id, name, description
+FROM client;
+INSERT INTO search (rowid, type, ref_id, name, description)
+SELECT 3 * id + 1, 'project', id, name, description
+FROM project;
+INSERT INTO search (rowid, type, ref_id, name, description)
+SELECT 3 * id + 2, 'project.slot', id, NULL, description
+FROM slot
+WHERE description IS NOT NULL;
+
+CREATE TRIGGER IF NOT EXISTS client_search_insert AFTER INSERT ON client
+BEGIN
+    INSERT INTO search (rowid, type, ref_id, name, description) VALUES (3 * new.id, 'client', new.id, new.name, new.description);
+END;
+CREATE TRIGGER IF NOT EXISTS client_search_update AFTER UPDATE OF name, description ON client
+BEGIN
+    DELETE FROM search WHERE rowid = 3 * old.id;
+    INSERT INTO search (rowid, type, ref_id, name, description) VALUES (3 * new.id, 'client', new.id, new.name, new.description);
+END;
+CREATE TRIGGER IF NOT EXISTS client_search_delete AFTER DELETE ON client
+BEGIN
+    DELETE FROM search WHERE rowid = 3 * old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS project_search_insert AFTER INSERT ON project
+BEGIN
+    INSERT INTO search (rowid, type, ref_id, name, description) VALUES (3 * new.id + 1, 'project', new.id, new.name, new.description);
+END;
+CREATE TRIGGER IF NOT EXISTS project_search_update AFTER UPDATE OF name, description ON project
+BEGIN
+    DELETE FROM search WHERE rowid = 3 * old.id + 1;
+    INSERT INTO search (rowid, type, ref_id, name, description) VALUES (3 * new.id + 1, 'project', new.id, new.name, new.description);
+END;
+CREATE TRIGGER IF NOT EXISTS project_search_delete AFTER DELETE ON project
+BEGIN
+    DELETE FROM search WHERE rowid = 3 * old.id + 1;
+END;
+
+-- slots without description are not indexed
+CREATE TRIGGER IF NOT EXISTS slot_search_insert AFTER INSERT ON slot WHEN new.description IS NOT NULL
+BEGIN
+    INSERT INTO search (rowid, type, ref_id, name, description) VALUES (3 * new.id + 2, 'project.slot', new.id, NULL, new.description);
+END;
+CREATE TRIGGER IF NOT EXISTS slot_search_update AFTER UPDATE OF description ON slot
+BEGIN
+    DELETE FROM search WHERE rowid = 3 * old.id + 2;
+    INSERT INTO search (rowid, type, ref_id, name, description)
+    SELECT 3 * new.id + 2, 'project.slot', new.id, NULL, new.description
+    WHERE new.description IS NOT NULL;
+END;
+CREATE TRIGGER IF NOT EXISTS slot_search_delete AFTER DELETE ON slot
+BEGIN
+    DELETE FROM search WHERE rowid = 3 * old.id + 2;
+END;
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS timesheet_revision_insert;
+DROP TRIGGER IF EXISTS timesheet_revision_update;
+DROP TRIGGER IF EXISTS timesheet_revision_delete;
+
+DELETE FROM revision WHERE name = 'timesheet';
+
+DROP TABLE IF EXISTS timesheet;
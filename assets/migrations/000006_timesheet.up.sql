@@ -0,0 +1,29 @@
+-- timesheet is the sign-off of a week, its totals are computed from the slots of the week
+CREATE TABLE IF NOT EXISTS timesheet (
+    id           INTEGER
+        PRIMARY KEY,
+    week         TEXT      NOT NULL
+        UNIQUE,
+    state        TEXT      NOT NULL DEFAULT 'open'
+        CHECK (state IN ('open', 'submitted', 'approved', 'rejected')),
+    comment      TEXT,
+    submitted_at TIMESTAMP,
+    decided_at   TIMESTAMP,
+    version      INTEGER   NOT NULL DEFAULT 1
+);
+
+INSERT INTO revision (name)
+VALUES ('timesheet');
+
+CREATE TRIGGER IF NOT EXISTS timesheet_revision_insert AFTER INSERT ON timesheet
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'timesheet';
+END;
+CREATE TRIGGER IF NOT EXISTS timesheet_revision_update AFTER UPDATE ON timesheet
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'timesheet';
+END;
+CREATE TRIGGER IF NOT EXISTS timesheet_revision_delete AFTER DELETE ON timesheet
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'timesheet';
+END;
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS period_lock_revision_insert;
+DROP TRIGGER IF EXISTS period_lock_revision_update;
+DROP TRIGGER IF EXISTS period_lock_revision_delete;
+
+DELETE FROM revision WHERE name = 'period_lock';
+
+DROP TABLE IF EXISTS period_lock;
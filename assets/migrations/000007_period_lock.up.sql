@@ -0,0 +1,30 @@
+-- period_lock closes the slots up to a date, of one client or of all clients if client_id is null.
+-- Locks are never deleted, unlocking records the time and the reason.
+CREATE TABLE IF NOT EXISTS period_lock (
+    id            INTEGER
+        PRIMARY KEY,
+    client_id     INTEGER
+        REFERENCES client (id),
+    locked_until  TEXT      NOT NULL,
+    reason        TEXT      NOT NULL,
+    locked_at     TIMESTAMP NOT NULL,
+    unlock_reason TEXT,
+    unlocked_at   TIMESTAMP,
+    version       INTEGER   NOT NULL DEFAULT 1
+);
+
+INSERT INTO revision (name)
+VALUES ('period_lock');
+
+CREATE TRIGGER IF NOT EXISTS period_lock_revision_insert AFTER INSERT ON period_lock
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'period_lock';
+END;
+CREATE TRIGGER IF NOT EXISTS period_lock_revision_update AFTER UPDATE ON period_lock
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'period_lock';
+END;
+CREATE TRIGGER IF NOT EXISTS period_lock_revision_delete AFTER DELETE ON period_lock
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'period_lock';
+END;
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS work_schedule_revision_insert;
+DROP TRIGGER IF EXISTS work_schedule_revision_update;
+DROP TRIGGER IF EXISTS work_schedule_revision_delete;
+
+DELETE FROM revision WHERE name = 'work_schedule';
+
+DROP TABLE IF EXISTS work_schedule;
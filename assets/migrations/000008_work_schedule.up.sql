@@ -0,0 +1,27 @@
+-- work_schedule is the target working time from valid_from until the next schedule,
+-- working_days is a bit mask of the weekdays with Sunday as bit 0
+CREATE TABLE IF NOT EXISTS work_schedule (
+    id             INTEGER
+        PRIMARY KEY,
+    valid_from     TEXT    NOT NULL
+        UNIQUE,
+    weekly_minutes INTEGER NOT NULL,
+    working_days   INTEGER NOT NULL,
+    version        INTEGER NOT NULL DEFAULT 1
+);
+
+INSERT INTO revision (name)
+VALUES ('work_schedule');
+
+CREATE TRIGGER IF NOT EXISTS work_schedule_revision_insert AFTER INSERT ON work_schedule
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'work_schedule';
+END;
+CREATE TRIGGER IF NOT EXISTS work_schedule_revision_update AFTER UPDATE ON work_schedule
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'work_schedule';
+END;
+CREATE TRIGGER IF NOT EXISTS work_schedule_revision_delete AFTER DELETE ON work_schedule
+BEGIN
+    UPDATE revision SET value = value + 1 WHERE name = 'work_schedule';
+END;
@@ -0,0 +1,387 @@
+// Package admin implements the commands operators use to manage an instance without starting the web server.
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/vloryan/protrakgon/internal/app/server"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/db/sqlite"
+)
+
+// Usage lists the admin commands.
+const Usage = `server and admin commands:
+  serve                     run the server, the default without command
+  migrate up                apply all pending migrations
+  migrate down [steps]      revert the last migration or the last steps migrations
+  migrate version           print the version of the database
+  backup [file]             write a consistent copy of the database to file or the backup directory
+  restore <file|time>       replace the database by the backup file or the newest backup of the
+                            backup directory at time, e.g. 2025-03-10T08:00, stop the server first
+  export <file.zip>         write all data as portable archive to file
+  import <file.zip>         add all data of the archive to the empty database
+  check                     verify the integrity and the foreign keys of the database
+`
+
+// ErrUsage is returned for unknown commands or invalid arguments.
+var ErrUsage = errors.New("invalid command")
+
+// Run executes the admin command args[0] with the arguments args[1:], without arguments it runs the server.
+func Run(srv *server.Server, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return srv.Run()
+	}
+	switch args[0] {
+	case "serve":
+		return srv.Run()
+	case "migrate":
+		return migrate(srv, args[1:], out)
+	case "backup":
+		if len(args) > 2 {
+			return usage(out)
+		}
+		return backup(srv, args[1:], out)
+	case "restore":
+		if len(args) != 2 {
+			return usage(out)
+		}
+		return restore(srv, args[1], out)
+	case "export":
+		if len(args) != 2 {
+			return usage(out)
+		}
+		return export(srv, args[1], out)
+	case "import":
+		if len(args) != 2 {
+			return usage(out)
+		}
+		return importArchive(srv, args[1], out)
+	case "check":
+		return check(srv, out)
+	default:
+		return usage(out)
+	}
+}
+
+// IsCommand reports whether name is an admin command.
+func IsCommand(name string) bool {
+	switch name {
+	case "serve", "migrate", "backup", "restore", "export", "import", "check":
+		return true
+	default:
+		return false
+	}
+}
+
+func usage(out io.Writer) error {
+	_, _ = fmt.Fprint(out, Usage)
+	return ErrUsage
+}
+
+func migrate(srv *server.Server, args []string, out io.Writer) error {
+	if len(args) == 0 || len(args) > 2 {
+		return usage(out)
+	}
+	connection, err := srv.OpenDB()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = connection.Close() }()
+	migrator, err := srv.Migrator(connection)
+	if err != nil {
+		return err
+	}
+	var info *db.MigrationInfo
+	switch args[0] {
+	case "up":
+		info, err = migrator.Up()
+	case "down":
+		steps := 1
+		if len(args) == 2 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid steps %s", args[1])
+			}
+		}
+		info, err = migrator.Down(steps)
+	case "version":
+		info, err = migrator.Version()
+	default:
+		return usage(out)
+	}
+	if err != nil {
+		return err
+	}
+	printInfo(out, info)
+	return nil
+}
+
+func printInfo(out io.Writer, info *db.MigrationInfo) {
+	if info.Migrated() {
+		_, _ = fmt.Fprintf(out, "migrated from version %d to %d\n", info.MigratedVersion, info.CurrentVersion)
+	} else {
+		_, _ = fmt.Fprintf(out, "version %d\n", info.CurrentVersion)
+	}
+	if info.Dirty {
+		_, _ = fmt.Fprintln(out, "dirty: the last migration failed, fix the database and force the version")
+	}
+}
+
+func check(srv *server.Server, out io.Writer) error {
+	connection, err := srv.OpenDB()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = connection.Close() }()
+	if err := connection.Check(); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(out, "ok")
+	return nil
+}
+
+func backup(srv *server.Server, args []string, out io.Writer) error {
+	connection, err := srv.OpenDB()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = connection.Close() }()
+	if len(args) == 0 {
+		if srv.Backups() == nil {
+			return errors.New("backups are not configured, pass a file")
+		}
+		backup, err := srv.Backups().Create(connection)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(out, "backup written to %s\n", filepath.Join(srv.Backups().Dir, backup.Name))
+		return nil
+	}
+	if _, err := os.Stat(args[0]); err == nil {
+		return fmt.Errorf("backup %s already exists", args[0])
+	}
+	if err := connection.Backup(args[0]); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "backup written to %s\n", args[0])
+	return nil
+}
+
+// restore replaces the database by a copy of the backup ref, which is either a file or a point in time
+// selecting the newest backup of the backup directory created at or before it. The copy is checked and
+// migrated before it replaces the database, backups of newer versions are rejected.
+func restore(srv *server.Server, ref string, out io.Writer) error {
+	fileName, err := resolveBackup(srv, ref)
+	if err != nil {
+		return err
+	}
+	target := srv.DBFileName()
+	tmp := target + ".restore"
+	if err := copyFile(fileName, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	info, err := prepareRestore(srv, tmp)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("backup %s: %w", fileName, err)
+	}
+	// the journal files belong to the replaced database
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(target + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			_ = os.Remove(tmp)
+			return err
+		}
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "database %s restored from %s\n", target, fileName)
+	printInfo(out, info)
+	return nil
+}
+
+// export writes the data of the database to the archive fileName, which must not exist.
+func export(srv *server.Server, fileName string, out io.Writer) error {
+	srv.Modules()
+	connection, err := srv.OpenDB()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = connection.Close() }()
+	migrator, err := srv.Migrator(connection)
+	if err != nil {
+		return err
+	}
+	info, err := migratedVersion(migrator)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	var manifest *server.ArchiveManifest
+	err = connection.DoTransaction(func(tx db.Transaction) error {
+		manifest, err = server.ExportArchive(tx, file, info.CurrentVersion)
+		return err
+	})
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(fileName)
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "exported to %s\n", fileName)
+	printManifest(out, manifest)
+	return nil
+}
+
+// importArchive migrates the database and adds the data of the archive fileName in one transaction.
+func importArchive(srv *server.Server, fileName string, out io.Writer) error {
+	srv.Modules()
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	connection, err := srv.OpenDB()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = connection.Close() }()
+	migrator, err := srv.Migrator(connection)
+	if err != nil {
+		return err
+	}
+	info, err := migrator.Up()
+	if err != nil {
+		return err
+	}
+	var manifest *server.ArchiveManifest
+	err = connection.DoTransaction(func(tx db.Transaction) error {
+		manifest, err = server.ImportArchive(tx, file, stat.Size(), info.CurrentVersion)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("import %s: %w", fileName, err)
+	}
+	_, _ = fmt.Fprintf(out, "imported %s\n", fileName)
+	printManifest(out, manifest)
+	return nil
+}
+
+// migratedVersion returns the version of a migrated database and rejects failed migrations.
+func migratedVersion(migrator *db.Migrator) (*db.MigrationInfo, error) {
+	info, err := migrator.Version()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case info.CurrentVersion == 0:
+		return nil, errors.New("no migrated database")
+	case info.Dirty:
+		return nil, fmt.Errorf("migration %d failed", info.CurrentVersion)
+	}
+	return info, nil
+}
+
+func printManifest(out io.Writer, manifest *server.ArchiveManifest) {
+	_, _ = fmt.Fprintf(out, "schema version %d\n", manifest.SchemaVersion)
+	for _, entry := range manifest.Resources {
+		_, _ = fmt.Fprintf(out, "%s: %d\n", entry.Type, entry.Count)
+	}
+}
+
+var restoreTimeFormats = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", time.DateOnly}
+
+func resolveBackup(srv *server.Server, ref string) (string, error) {
+	if _, err := os.Stat(ref); err == nil {
+		return ref, nil
+	}
+	if srv.Backups() == nil {
+		return "", fmt.Errorf("backup %s not found", ref)
+	}
+	for _, format := range restoreTimeFormats {
+		t, err := time.ParseInLocation(format, ref, time.Local)
+		if err != nil {
+			continue
+		}
+		if format == time.DateOnly {
+			// a day includes the backups of the whole day
+			t = t.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		backup, err := srv.Backups().At(t)
+		if err != nil {
+			return "", err
+		}
+		return srv.Backups().Path(backup.Name)
+	}
+	return "", fmt.Errorf("backup %s not found, use a file or a time like 2006-01-02T15:04", ref)
+}
+
+// prepareRestore checks the database fileName and migrates it to the latest version.
+func prepareRestore(srv *server.Server, fileName string) (*db.MigrationInfo, error) {
+	connection, err := sqlite.NewConnection(fileName + "?_fk=on")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = connection.Close() }()
+	if err := connection.Check(); err != nil {
+		return nil, err
+	}
+	migrator, err := srv.Migrator(connection)
+	if err != nil {
+		return nil, err
+	}
+	info, err := migratedVersion(migrator)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := migrator.Latest()
+	if err != nil {
+		return nil, err
+	}
+	if info.CurrentVersion > latest {
+		return nil, fmt.Errorf("schema version %d is newer than the supported version %d", info.CurrentVersion, latest)
+	}
+	return migrator.Up()
+}
+
+func copyFile(source, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+		return err
+	}
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	outFile, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(outFile, in); err != nil {
+		_ = outFile.Close()
+		return err
+	}
+	if err := outFile.Sync(); err != nil {
+		_ = outFile.Close()
+		return err
+	}
+	return outFile.Close()
+}
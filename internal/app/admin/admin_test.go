@@ -0,0 +1,86 @@
+package admin
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/protrakgon/internal/app/server"
+)
+
+func TestRun_Migrate(t *testing.T) {
+	srv := server.New().
+		WithAssets(os.DirFS("../../../assets")).
+		WithDBFile(filepath.Join(t.TempDir(), "test.db"))
+	tests := []struct {
+		name    string
+		args    []string
+		wantOut string
+		wantErr bool
+	}{{
+		name:    "GIVEN new database THEN version is 0",
+		args:    []string{"migrate", "version"},
+		wantOut: "version 0\n",
+	}, {
+		name:    "GIVEN migrate up THEN apply all migrations",
+		args:    []string{"migrate", "up"},
+		wantOut: "migrated from version 0 to 8\n",
+	}, {
+		name:    "GIVEN migrate down with steps THEN revert migrations",
+		args:    []string{"migrate", "down", "2"},
+		wantOut: "migrated from version 8 to 6\n",
+	}, {
+		name:    "GIVEN invalid steps THEN fail",
+		args:    []string{"migrate", "down", "-1"},
+		wantErr: true,
+	}, {
+		name:    "GIVEN unknown command THEN print usage",
+		args:    []string{"user", "add"},
+		wantOut: Usage,
+		wantErr: true,
+	}}
+	// the cases share the database and run in order
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+
+			err := Run(srv, tt.args, out)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.wantOut, out.String()); diff != "" {
+				t.Errorf("Run() output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRun_Restore(t *testing.T) {
+	dir := t.TempDir()
+	source := server.New().
+		WithAssets(os.DirFS("../../../assets")).
+		WithDBFile(filepath.Join(dir, "source.db"))
+	if err := Run(source, []string{"migrate", "up"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Run() migrate up error = %v", err)
+	}
+	target := server.New().
+		WithAssets(os.DirFS("../../../assets")).
+		WithDBFile(filepath.Join(dir, "target.db"))
+	out := &bytes.Buffer{}
+
+	err := Run(target, []string{"restore", filepath.Join(dir, "source.db")}, out)
+
+	if err != nil {
+		t.Fatalf("Run() restore error = %v", err)
+	}
+	want := "database " + filepath.Join(dir, "target.db") + " restored from " + filepath.Join(dir, "source.db") + "\nversion 8\n"
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("Run() output mismatch (-want +got):\n%s", diff)
+	}
+	if err := Run(target, []string{"restore", filepath.Join(dir, "missing.db")}, out); err == nil {
+		t.Error("Run() restore of missing backup succeeded")
+	}
+}
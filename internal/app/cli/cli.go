@@ -0,0 +1,527 @@
+// Package cli implements the command line client, which tracks time by calling the JSON:API of a server.
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vloryan/protrakgon/internal/app/admin"
+)
+
+type command struct {
+	usage string
+	help  string
+	run   func(e *environment, args []string) error
+}
+
+var commands = map[string]*command{
+	"start": {
+		usage: "start [flags] <project> [work|break]",
+		help:  "start a slot of the project now, the activity is work by default",
+		run:   runStart,
+	},
+	"stop": {
+		usage: "stop [flags] [project]",
+		help:  "end the open slots now",
+		run:   runStop,
+	},
+	"status": {
+		usage: "status [flags]",
+		help:  "show the open slots",
+		run:   runStatus,
+	},
+	"log": {
+		usage: "log [flags] [project]",
+		help:  "list the slots, by default of today",
+		run:   runLog,
+	},
+	"add": {
+		usage: "add [flags] <project> <start> <end> [work|break]",
+		help:  "add a slot retroactively, start and end are HH:MM of -date",
+		run:   runAdd,
+	},
+	"report": {
+		usage: "report [flags] [project]",
+		help:  "sum the durations per project and activity, by default of the current week",
+		run:   runReport,
+	},
+	"config": {
+		usage: "config [url|token|output <value>]",
+		help:  "show or change the config file",
+		run:   runConfig,
+	},
+	"completion": {
+		usage: "completion bash|zsh",
+		help:  "print the shell completion script",
+		run:   runCompletion,
+	},
+}
+
+func init() {
+	// hidden command of the completion scripts, registered here as it lists the commands
+	commands["__complete"] = &command{run: runComplete}
+}
+
+// IsCommand reports whether name is a command of the command line client.
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok || name == "help"
+}
+
+// environment of a command.
+type environment struct {
+	cfg    *Config
+	flags  *flag.FlagSet
+	out    io.Writer
+	now    func() time.Time
+	client *client
+}
+
+// Run executes the command args[0] with the arguments args[1:] and returns the exit code.
+func Run(args []string, out, errOut io.Writer) int {
+	cfg, err := loadConfig()
+	if err != nil {
+		_, _ = fmt.Fprintln(errOut, "protrakgon:", err)
+		return 1
+	}
+	return run(cfg, time.Now, args, out, errOut)
+}
+
+func run(cfg *Config, now func() time.Time, args []string, out, errOut io.Writer) int {
+	if len(args) == 0 || args[0] == "help" {
+		printUsage(errOut)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		_, _ = fmt.Fprintf(errOut, "protrakgon: unknown command %s\n", args[0])
+		printUsage(errOut)
+		return 2
+	}
+	e := &environment{
+		cfg:   cfg,
+		flags: flag.NewFlagSet(args[0], flag.ContinueOnError),
+		out:   out,
+		now:   now,
+	}
+	e.flags.SetOutput(errOut)
+	e.flags.Usage = func() {
+		_, _ = fmt.Fprintf(errOut, "usage: protrakgon %s\n\n%s\n\nflags:\n", cmd.usage, cmd.help)
+		e.flags.PrintDefaults()
+	}
+	cfg.bindFlags(e.flags)
+	if err := cmd.run(e, args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		_, _ = fmt.Fprintln(errOut, "protrakgon:", err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name, cmd := range commands {
+		if cmd.usage != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	_, _ = fmt.Fprintln(w, "usage: protrakgon <command> [flags] [arguments]")
+	_, _ = fmt.Fprintln(w, "\ncommands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\n", name, commands[name].help)
+	}
+	_ = tw.Flush()
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprint(w, admin.Usage)
+}
+
+// parse parses the flags and returns the positional arguments, at least minArgs and at most maxArgs.
+func (e *environment) parse(args []string, minArgs, maxArgs int) ([]string, error) {
+	if err := e.flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := e.cfg.validate(); err != nil {
+		return nil, err
+	}
+	if e.flags.NArg() < minArgs || e.flags.NArg() > maxArgs {
+		e.flags.Usage()
+		return nil, flag.ErrHelp
+	}
+	var err error
+	e.client, err = newClient(e.cfg)
+	return e.flags.Args(), err
+}
+
+// dateRange adds the flags -from and -until, the returned function returns their values.
+func (e *environment) dateRange(from, until time.Time) func() (time.Time, time.Time, error) {
+	fromText := e.flags.String("from", from.Format(time.DateOnly), "first day, YYYY-MM-DD")
+	untilText := e.flags.String("until", until.Format(time.DateOnly), "last day, YYYY-MM-DD")
+	return func() (time.Time, time.Time, error) {
+		from, err := time.ParseInLocation(time.DateOnly, *fromText, time.Local)
+		if err != nil {
+			return from, from, fmt.Errorf("invalid -from: %w", err)
+		}
+		until, err := time.ParseInLocation(time.DateOnly, *untilText, time.Local)
+		if err != nil {
+			return from, until, fmt.Errorf("invalid -until: %w", err)
+		}
+		if until.Before(from) {
+			return from, until, errors.New("-until is before -from")
+		}
+		return from, until, nil
+	}
+}
+
+func parseActivity(args []string, index int) (string, error) {
+	if len(args) <= index {
+		return "work", nil
+	}
+	switch activity := strings.ToLower(args[index]); activity {
+	case "work", "break":
+		return activity, nil
+	default:
+		return "", fmt.Errorf("unknown activity %s, use work or break", args[index])
+	}
+}
+
+func runStart(e *environment, args []string) error {
+	description := e.flags.String("m", "", "description of the slot")
+	args, err := e.parse(args, 1, 2)
+	if err != nil {
+		return err
+	}
+	activity, err := parseActivity(args, 1)
+	if err != nil {
+		return err
+	}
+	p, err := e.client.findProject(args[0])
+	if err != nil {
+		return err
+	}
+	s := &slot{ProjectID: p.ID, Activity: activity}
+	if *description != "" {
+		s.Description = description
+	}
+	if s, err = e.client.createSlot(s); err != nil {
+		return err
+	}
+	return e.printSlots(map[int]*project{p.ID: p}, []*slot{s})
+}
+
+func runStop(e *environment, args []string) error {
+	args, err := e.parse(args, 0, 1)
+	if err != nil {
+		return err
+	}
+	projects, open, err := e.openSlots(args)
+	if err != nil {
+		return err
+	}
+	if len(open) == 0 {
+		return errors.New("no open slot")
+	}
+	end := e.now().UTC().Truncate(time.Minute)
+	stopped := make([]*slot, len(open))
+	for i, s := range open {
+		s.End = &end
+		if stopped[i], err = e.client.updateSlot(s); err != nil {
+			return fmt.Errorf("stop slot %d of %s: %w", s.ID, projects[s.ProjectID].Name, err)
+		}
+	}
+	return e.printSlots(projects, stopped)
+}
+
+func runStatus(e *environment, args []string) error {
+	if _, err := e.parse(args, 0, 0); err != nil {
+		return err
+	}
+	projects, open, err := e.openSlots(nil)
+	if err != nil {
+		return err
+	}
+	if len(open) == 0 && e.cfg.Output == OutputHuman {
+		_, err := fmt.Fprintln(e.out, "no open slot")
+		return err
+	}
+	return e.printSlots(projects, open)
+}
+
+// openSlots returns the projects by id and the open slots of the project args[0] or of all projects.
+func (e *environment) openSlots(args []string) (map[int]*project, []*slot, error) {
+	return e.slots(args, url.Values{"filter[isOpen]": {"true"}})
+}
+
+// slots returns the projects by id and the slots matching query of the project args[0] or of all projects.
+func (e *environment) slots(args []string, query url.Values) (map[int]*project, []*slot, error) {
+	var projects []*project
+	if len(args) > 0 {
+		p, err := e.client.findProject(args[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		projects = []*project{p}
+	} else {
+		var err error
+		if projects, err = e.client.projects(""); err != nil {
+			return nil, nil, err
+		}
+	}
+	byID := make(map[int]*project, len(projects))
+	var slots []*slot
+	for _, p := range projects {
+		byID[p.ID] = p
+		projectSlots, err := e.client.slots(p.ID, cloneValues(query))
+		if err != nil {
+			return nil, nil, err
+		}
+		slots = append(slots, projectSlots...)
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].Start.Before(*slots[j].Start)
+	})
+	return byID, slots, nil
+}
+
+// slotsBetween returns the slots starting between from and the end of until.
+func (e *environment) slotsBetween(args []string, from, until time.Time) (map[int]*project, []*slot, error) {
+	projects, slots, err := e.slots(args, url.Values{
+		"filter[from]":           {from.UTC().Format(time.DateOnly)},
+		"filter[fromComparator]": {"5"}, // greater than or equal
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	end := until.AddDate(0, 0, 1)
+	var filtered []*slot
+	for _, s := range slots {
+		if !s.Start.Before(from) && s.Start.Before(end) {
+			filtered = append(filtered, s)
+		}
+	}
+	return projects, filtered, nil
+}
+
+func cloneValues(values url.Values) url.Values {
+	clone := make(url.Values, len(values))
+	for key, value := range values {
+		clone[key] = append([]string(nil), value...)
+	}
+	return clone
+}
+
+func runLog(e *environment, args []string) error {
+	today := startOfDay(e.now())
+	dates := e.dateRange(today, today)
+	args, err := e.parse(args, 0, 1)
+	if err != nil {
+		return err
+	}
+	from, until, err := dates()
+	if err != nil {
+		return err
+	}
+	projects, slots, err := e.slotsBetween(args, from, until)
+	if err != nil {
+		return err
+	}
+	return e.printSlots(projects, slots)
+}
+
+func runAdd(e *environment, args []string) error {
+	date := e.flags.String("date", e.now().Format(time.DateOnly), "day of the slot, YYYY-MM-DD")
+	description := e.flags.String("m", "", "description of the slot")
+	args, err := e.parse(args, 3, 4)
+	if err != nil {
+		return err
+	}
+	start, err := time.ParseInLocation(time.DateOnly+" 15:04", *date+" "+args[1], time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid start: %w", err)
+	}
+	end, err := time.ParseInLocation(time.DateOnly+" 15:04", *date+" "+args[2], time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid end: %w", err)
+	}
+	activity, err := parseActivity(args, 3)
+	if err != nil {
+		return err
+	}
+	p, err := e.client.findProject(args[0])
+	if err != nil {
+		return err
+	}
+	start, end = start.UTC(), end.UTC()
+	s := &slot{ProjectID: p.ID, Activity: activity, Start: &start, End: &end}
+	if *description != "" {
+		s.Description = description
+	}
+	if s, err = e.client.createSlot(s); err != nil {
+		return err
+	}
+	return e.printSlots(map[int]*project{p.ID: p}, []*slot{s})
+}
+
+// reportLine is the total duration of an activity of a project.
+type reportLine struct {
+	Project  string        `json:"project"`
+	Activity string        `json:"activity"`
+	Duration time.Duration `json:"-"`
+	Minutes  int           `json:"minutes"`
+}
+
+func runReport(e *environment, args []string) error {
+	today := startOfDay(e.now())
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	dates := e.dateRange(monday, monday.AddDate(0, 0, 6))
+	args, err := e.parse(args, 0, 1)
+	if err != nil {
+		return err
+	}
+	from, until, err := dates()
+	if err != nil {
+		return err
+	}
+	projects, slots, err := e.slotsBetween(args, from, until)
+	if err != nil {
+		return err
+	}
+	lines := report(projects, slots, e.now())
+	if e.cfg.Output == OutputJSON {
+		return writeJSON(e.out, lines)
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "PROJECT\tACTIVITY\tDURATION\n")
+	var total time.Duration
+	for _, line := range lines {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", line.Project, line.Activity, formatDuration(line.Duration))
+		if line.Activity == "work" {
+			total += line.Duration
+		}
+	}
+	_, _ = fmt.Fprintf(tw, "TOTAL\twork\t%s\n", formatDuration(total))
+	return tw.Flush()
+}
+
+// report sums the durations of slots per project and activity, open slots last until now.
+func report(projects map[int]*project, slots []*slot, now time.Time) []*reportLine {
+	byKey := make(map[string]*reportLine)
+	var lines []*reportLine
+	for _, s := range slots {
+		name := projectName(projects, s.ProjectID)
+		key := name + "\x00" + s.Activity
+		line, ok := byKey[key]
+		if !ok {
+			line = &reportLine{Project: name, Activity: s.Activity}
+			byKey[key] = line
+			lines = append(lines, line)
+		}
+		line.Duration += s.duration(now).Truncate(time.Minute)
+		line.Minutes = int(line.Duration / time.Minute)
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].Project != lines[j].Project {
+			return lines[i].Project < lines[j].Project
+		}
+		return lines[i].Activity > lines[j].Activity
+	})
+	return lines
+}
+
+func runConfig(e *environment, args []string) error {
+	if err := e.flags.Parse(args); err != nil {
+		return err
+	}
+	args = e.flags.Args()
+	switch len(args) {
+	case 0:
+		token := e.cfg.Token
+		if token != "" {
+			token = "***"
+		}
+		_, err := fmt.Fprintf(e.out, "url:    %s\ntoken:  %s\noutput: %s\n", e.cfg.URL, token, e.cfg.Output)
+		return err
+	case 2:
+		switch args[0] {
+		case "url":
+			e.cfg.URL = args[1]
+		case "token":
+			e.cfg.Token = args[1]
+		case "output":
+			e.cfg.Output = args[1]
+		default:
+			return fmt.Errorf("unknown config %s, use url, token or output", args[0])
+		}
+		if err := e.cfg.validate(); err != nil {
+			return err
+		}
+		return e.cfg.save()
+	default:
+		e.flags.Usage()
+		return flag.ErrHelp
+	}
+}
+
+func (e *environment) printSlots(projects map[int]*project, slots []*slot) error {
+	if e.cfg.Output == OutputJSON {
+		type slotOutput struct {
+			ID      int    `json:"id"`
+			Project string `json:"project"`
+			*slot
+		}
+		output := make([]slotOutput, len(slots))
+		for i, s := range slots {
+			output[i] = slotOutput{ID: s.ID, Project: projectName(projects, s.ProjectID), slot: s}
+		}
+		return writeJSON(e.out, output)
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "ID\tPROJECT\tACTIVITY\tSTART\tEND\tDURATION\tDESCRIPTION\n")
+	for _, s := range slots {
+		end := "open"
+		if s.End != nil {
+			end = s.End.Local().Format("15:04")
+		}
+		description := ""
+		if s.Description != nil {
+			description = *s.Description
+		}
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, projectName(projects, s.ProjectID), s.Activity,
+			s.Start.Local().Format("2006-01-02 15:04"), end, formatDuration(s.duration(e.now())), description)
+	}
+	return tw.Flush()
+}
+
+func projectName(projects map[int]*project, id int) string {
+	if p, ok := projects[id]; ok {
+		return p.Name
+	}
+	return fmt.Sprintf("#%d", id)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	return fmt.Sprintf("%d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeAPI serves three projects, the first one with an open slot. Like the API, it returns a page of two
+// projects unless page[limit] is -1.
+func fakeAPI(requests *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		*requests = append(*requests, req.Method+" "+req.URL.Path+" "+string(body))
+		w.Header().Set("Content-Type", mediaType)
+		switch req.Method + " " + req.URL.Path {
+		case "GET /v1/project":
+			projects := `{"type":"project","id":"1","attributes":{"name":"Website"}},` +
+				`{"type":"project","id":"2","attributes":{"name":"Backend"}}`
+			if req.URL.Query().Get("page[limit]") == "-1" {
+				projects += `,{"type":"project","id":"3","attributes":{"name":"Archive"}}`
+			}
+			_, _ = w.Write([]byte(`{"data":[` + projects + `]}`))
+		case "GET /v1/project/1/slot":
+			_, _ = w.Write([]byte(`{"data":[{"type":"project.slot","id":"7","attributes":` +
+				`{"projectId":1,"activity":"work","start":"2025-03-10T08:00:00Z","version":2}}]}`))
+		case "GET /v1/project/2/slot", "GET /v1/project/3/slot":
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		case "PATCH /v1/project/1/slot/7":
+			_, _ = w.Write([]byte(`{"data":{"type":"project.slot","id":"7","attributes":` +
+				`{"projectId":1,"activity":"work","start":"2025-03-10T08:00:00Z","end":"2025-03-10T09:30:00Z","version":3}}}`))
+		case "POST /v1/project/2/slot":
+			_, _ = w.Write([]byte(`{"data":{"type":"project.slot","id":"8","attributes":` +
+				`{"projectId":2,"activity":"break","start":"2025-03-10T09:30:00Z","version":1}}}`))
+		case "POST /v1/project/3/slot":
+			_, _ = w.Write([]byte(`{"data":{"type":"project.slot","id":"9","attributes":` +
+				`{"projectId":3,"activity":"work","start":"2025-03-10T09:30:00Z","version":1}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[{"status":"404","title":"not found"}]}`))
+		}
+	}))
+}
+
+func TestRun(t *testing.T) {
+	now := func() time.Time {
+		return time.Date(2025, 3, 10, 9, 30, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name         string
+		args         []string
+		wantCode     int
+		wantOut      string
+		wantRequests []string
+	}{{
+		name:     "GIVEN status THEN list open slots of all projects",
+		args:     []string{"status", "-o", "json"},
+		wantCode: 0,
+		wantOut: `[
+  {
+    "id": 7,
+    "project": "Website",
+    "projectId": 1,
+    "activity": "work",
+    "start": "2025-03-10T08:00:00Z",
+    "version": 2
+  }
+]
+`,
+		wantRequests: []string{"GET /v1/project ", "GET /v1/project/1/slot ", "GET /v1/project/2/slot ", "GET /v1/project/3/slot "},
+	}, {
+		name:     "GIVEN start with project name THEN create slot of project",
+		args:     []string{"start", "-o", "json", "backend", "break"},
+		wantCode: 0,
+		wantOut: `[
+  {
+    "id": 8,
+    "project": "Backend",
+    "projectId": 2,
+    "activity": "break",
+    "start": "2025-03-10T09:30:00Z",
+    "version": 1
+  }
+]
+`,
+		wantRequests: []string{
+			"GET /v1/project ",
+			`POST /v1/project/2/slot {"data":{"type":"project.slot","attributes":{"projectId":2,"activity":"break"}}}`,
+		},
+	}, {
+		name:     "GIVEN project beyond first page THEN find it",
+		args:     []string{"start", "-o", "json", "archive", "work"},
+		wantCode: 0,
+		wantOut: `[
+  {
+    "id": 9,
+    "project": "Archive",
+    "projectId": 3,
+    "activity": "work",
+    "start": "2025-03-10T09:30:00Z",
+    "version": 1
+  }
+]
+`,
+		wantRequests: []string{
+			"GET /v1/project ",
+			`POST /v1/project/3/slot {"data":{"type":"project.slot","attributes":{"projectId":3,"activity":"work"}}}`,
+		},
+	}, {
+		name:     "GIVEN stop THEN end open slot now",
+		args:     []string{"stop", "-o", "json", "Website"},
+		wantCode: 0,
+		wantOut: `[
+  {
+    "id": 7,
+    "project": "Website",
+    "projectId": 1,
+    "activity": "work",
+    "start": "2025-03-10T08:00:00Z",
+    "end": "2025-03-10T09:30:00Z",
+    "version": 3
+  }
+]
+`,
+		wantRequests: []string{
+			"GET /v1/project ",
+			"GET /v1/project/1/slot ",
+			`PATCH /v1/project/1/slot/7 {"data":{"type":"project.slot","id":"7","attributes":{"projectId":1,"activity":"work","start":"2025-03-10T08:00:00Z","end":"2025-03-10T09:30:00Z","version":2}}}`,
+		},
+	}, {
+		name:     "GIVEN report THEN sum open slot until now",
+		args:     []string{"report", "-o", "json", "-from", "2025-03-10", "-until", "2025-03-10", "1"},
+		wantCode: 0,
+		wantOut: `[
+  {
+    "project": "Website",
+    "activity": "work",
+    "minutes": 90
+  }
+]
+`,
+		wantRequests: []string{"GET /v1/project ", "GET /v1/project/1/slot "},
+	}, {
+		name:         "GIVEN unknown activity THEN fail",
+		args:         []string{"start", "Website", "sleep"},
+		wantCode:     1,
+		wantRequests: nil,
+	}, {
+		name:     "GIVEN unknown command THEN print usage",
+		args:     []string{"unknown"},
+		wantCode: 2,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			api := fakeAPI(&requests)
+			defer api.Close()
+			cfg := &Config{URL: api.URL + "/v1", Output: OutputHuman}
+			out := &bytes.Buffer{}
+
+			code := run(cfg, now, tt.args, out, io.Discard)
+
+			if code != tt.wantCode {
+				t.Errorf("run() code = %d, want %d", code, tt.wantCode)
+			}
+			if diff := cmp.Diff(tt.wantOut, out.String()); diff != "" {
+				t.Errorf("run() output mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantRequests, requests); diff != "" {
+				t.Errorf("run() requests mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const mediaType = "application/vnd.api+json"
+
+type project struct {
+	ID   int    `json:"-"`
+	Name string `json:"name,omitempty"`
+}
+
+type slot struct {
+	ID          int        `json:"-"`
+	ProjectID   int        `json:"projectId,omitempty"`
+	Activity    string     `json:"activity,omitempty"`
+	Start       *time.Time `json:"start,omitempty"`
+	End         *time.Time `json:"end,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	Version     int        `json:"version,omitempty"`
+}
+
+func (s *slot) duration(now time.Time) time.Duration {
+	if s.Start == nil {
+		return 0
+	}
+	if s.End == nil {
+		return now.Sub(*s.Start)
+	}
+	return s.End.Sub(*s.Start)
+}
+
+type resourceObject struct {
+	Type       string          `json:"type"`
+	ID         string          `json:"id,omitempty"`
+	Attributes json.RawMessage `json:"attributes,omitempty"`
+}
+
+type document struct {
+	Data   json.RawMessage   `json:"data,omitempty"`
+	Links  map[string]string `json:"links,omitempty"`
+	Errors []struct {
+		Status string `json:"status"`
+		Title  string `json:"title"`
+		Detail string `json:"detail"`
+	} `json:"errors,omitempty"`
+}
+
+// client calls the JSON:API of the server.
+type client struct {
+	base  *url.URL
+	token string
+	http  *http.Client
+}
+
+func newClient(cfg *Config) (*client, error) {
+	base, err := url.Parse(strings.TrimSuffix(cfg.URL, "/") + "/")
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %s: %w", cfg.URL, err)
+	}
+	return &client{base: base, token: cfg.Token, http: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func (c *client) do(method, ref string, header http.Header, body any) (*document, error) {
+	u, err := c.base.Parse(strings.TrimPrefix(ref, "/"))
+	if strings.HasPrefix(ref, "/") && strings.HasPrefix(ref, c.base.Path) {
+		// links of the server are absolute paths including the api prefix
+		u, err = c.base.Parse(ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", mediaType)
+	if body != nil {
+		req.Header.Set("Content-Type", mediaType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	doc := &document{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, doc); err != nil {
+			return nil, fmt.Errorf("%s %s: %s: invalid response: %w", method, u.Path, resp.Status, err)
+		}
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		if len(doc.Errors) == 0 {
+			return nil, fmt.Errorf("%s %s: %s", method, u.Path, resp.Status)
+		}
+		messages := make([]string, len(doc.Errors))
+		for i, e := range doc.Errors {
+			messages[i] = e.Title
+			if e.Detail != "" {
+				messages[i] += ": " + e.Detail
+			}
+		}
+		return nil, fmt.Errorf("%s", strings.Join(messages, ", "))
+	}
+	return doc, nil
+}
+
+// projects returns all projects, or the projects whose name contains name, in one page.
+func (c *client) projects(name string) ([]*project, error) {
+	query := url.Values{}
+	query.Set("page[limit]", "-1")
+	if name != "" {
+		query.Set("filter[name]", name)
+	}
+	doc, err := c.do(http.MethodGet, "project?"+query.Encode(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var objects []resourceObject
+	if err := json.Unmarshal(doc.Data, &objects); err != nil {
+		return nil, err
+	}
+	projects := make([]*project, len(objects))
+	for i, obj := range objects {
+		projects[i] = &project{}
+		if err := decode(obj, projects[i], &projects[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return projects, nil
+}
+
+// findProject returns the project with the id or, ignoring case, the name ref.
+func (c *client) findProject(ref string) (*project, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		projects, err := c.projects("")
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range projects {
+			if p.ID == id {
+				return p, nil
+			}
+		}
+	}
+	projects, err := c.projects(ref)
+	if err != nil {
+		return nil, err
+	}
+	var found []*project
+	for _, p := range projects {
+		if strings.EqualFold(p.Name, ref) {
+			return p, nil
+		}
+		if strings.Contains(strings.ToLower(p.Name), strings.ToLower(ref)) {
+			found = append(found, p)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("project %s not found", ref)
+	case 1:
+		return found[0], nil
+	default:
+		names := make([]string, len(found))
+		for i, p := range found {
+			names[i] = p.Name
+		}
+		return nil, fmt.Errorf("project %s is ambiguous: %s", ref, strings.Join(names, ", "))
+	}
+}
+
+// slots returns all slots of the project matching query, following the pagination links.
+func (c *client) slots(projectID int, query url.Values) ([]*slot, error) {
+	query.Set("page[after]", "")
+	query.Set("page[size]", "500")
+	next := fmt.Sprintf("project/%d/slot?%s", projectID, query.Encode())
+	var slots []*slot
+	for next != "" {
+		doc, err := c.do(http.MethodGet, next, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var objects []resourceObject
+		if err := json.Unmarshal(doc.Data, &objects); err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			s := &slot{}
+			if err := decode(obj, s, &s.ID); err != nil {
+				return nil, err
+			}
+			slots = append(slots, s)
+		}
+		next = doc.Links["next"]
+		if len(objects) == 0 {
+			next = ""
+		}
+	}
+	return slots, nil
+}
+
+func (c *client) createSlot(s *slot) (*slot, error) {
+	return c.saveSlot(http.MethodPost, fmt.Sprintf("project/%d/slot", s.ProjectID), nil, s)
+}
+
+func (c *client) updateSlot(s *slot) (*slot, error) {
+	header := http.Header{}
+	header.Set("If-Match", strconv.Quote(strconv.Itoa(s.Version)))
+	return c.saveSlot(http.MethodPatch, fmt.Sprintf("project/%d/slot/%d", s.ProjectID, s.ID), header, s)
+}
+
+func (c *client) saveSlot(method, ref string, header http.Header, s *slot) (*slot, error) {
+	attributes, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	obj := resourceObject{Type: "project.slot", Attributes: attributes}
+	if s.ID != 0 {
+		obj.ID = strconv.Itoa(s.ID)
+	}
+	doc, err := c.do(method, ref, header, map[string]any{"data": obj})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(doc.Data, &obj); err != nil {
+		return nil, err
+	}
+	saved := &slot{}
+	if err := decode(obj, saved, &saved.ID); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func decode(obj resourceObject, attributes any, id *int) error {
+	if len(obj.Attributes) > 0 {
+		if err := json.Unmarshal(obj.Attributes, attributes); err != nil {
+			return fmt.Errorf("invalid %s %s: %w", obj.Type, obj.ID, err)
+		}
+	}
+	if obj.ID != "" {
+		var err error
+		if *id, err = strconv.Atoi(obj.ID); err != nil {
+			return fmt.Errorf("invalid id of %s: %s", obj.Type, obj.ID)
+		}
+	}
+	return nil
+}
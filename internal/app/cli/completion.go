@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const bashCompletion = `_protrakgon() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "$(protrakgon __complete commands)" -- "$cur"))
+		return
+	fi
+	case ${COMP_WORDS[1]} in
+	start|stop|log|add|report)
+		if [ "$COMP_CWORD" -eq 2 ]; then
+			local IFS=$'\n'
+			COMPREPLY=($(compgen -W "$(protrakgon __complete projects)" -- "$cur"))
+		fi
+		;;
+	completion)
+		COMPREPLY=($(compgen -W "bash zsh" -- "$cur"))
+		;;
+	esac
+}
+complete -F _protrakgon protrakgon
+`
+
+const zshCompletion = `#compdef protrakgon
+_protrakgon() {
+	if (( CURRENT == 2 )); then
+		compadd -- ${(f)"$(protrakgon __complete commands)"}
+		return
+	fi
+	case $words[2] in
+	start|stop|log|add|report)
+		(( CURRENT == 3 )) && compadd -- ${(f)"$(protrakgon __complete projects)"}
+		;;
+	completion)
+		compadd bash zsh
+		;;
+	esac
+}
+compdef _protrakgon protrakgon
+`
+
+func runCompletion(e *environment, args []string) error {
+	args, err := e.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "bash":
+		_, err = fmt.Fprint(e.out, bashCompletion)
+	case "zsh":
+		_, err = fmt.Fprint(e.out, zshCompletion)
+	default:
+		err = fmt.Errorf("unknown shell %s, use bash or zsh", args[0])
+	}
+	return err
+}
+
+// runComplete prints the candidates used by the completion scripts, one per line.
+func runComplete(e *environment, args []string) error {
+	args, err := e.parse(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	var candidates []string
+	switch args[0] {
+	case "commands":
+		for name, cmd := range commands {
+			if cmd.usage != "" {
+				candidates = append(candidates, name)
+			}
+		}
+	case "projects":
+		projects, err := e.client.projects("")
+		if err != nil {
+			// completion must not print errors into the command line
+			return nil
+		}
+		for _, p := range projects {
+			candidates = append(candidates, p.Name)
+		}
+	}
+	sort.Strings(candidates)
+	_, err = fmt.Fprint(e.out, strings.Join(candidates, "\n")+"\n")
+	return err
+}
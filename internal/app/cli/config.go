@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/vloryan/go-libs/env"
+)
+
+const (
+	OutputHuman = "human"
+	OutputJSON  = "json"
+)
+
+// Config of the command line client. Values are read from the config file, then from the environment
+// variables PROTRAKGON_URL, PROTRAKGON_TOKEN and PROTRAKGON_OUTPUT and finally from the command line flags.
+type Config struct {
+	// URL of the API, e.g. http://localhost:8080/v1.
+	URL string `json:"url,omitempty"`
+	// Token is sent as bearer token, e.g. to pass an authenticating reverse proxy.
+	Token string `json:"token,omitempty"`
+	// Output is either OutputHuman or OutputJSON.
+	Output string `json:"output,omitempty"`
+}
+
+// configFile is the path of the config file, by default protrakgon/cli.json in the user's config directory.
+var configFile = func() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "protrakgon", "cli.json"), nil
+}
+
+func loadConfig() (*Config, error) {
+	cfg := &Config{URL: "http://localhost:8080/v1", Output: OutputHuman}
+	file, err := configFile()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", file, err)
+		}
+	}
+	cfg.URL = env.GetOrDefault("PROTRAKGON_URL", cfg.URL)
+	cfg.Token = env.GetOrDefault("PROTRAKGON_TOKEN", cfg.Token)
+	cfg.Output = env.GetOrDefault("PROTRAKGON_OUTPUT", cfg.Output)
+	return cfg, nil
+}
+
+func (c *Config) save() error {
+	file, err := configFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	// the file contains the token
+	return os.WriteFile(file, data, 0o600)
+}
+
+// bindFlags adds the flags overriding the config to flags.
+func (c *Config) bindFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.URL, "url", c.URL, "URL of the API")
+	flags.StringVar(&c.Token, "token", c.Token, "bearer token sent with every request")
+	flags.StringVar(&c.Output, "o", c.Output, "output format, human or json")
+}
+
+func (c *Config) validate() error {
+	if c.Output != OutputHuman && c.Output != OutputJSON {
+		return fmt.Errorf("unknown output format %s, use %s or %s", c.Output, OutputHuman, OutputJSON)
+	}
+	return nil
+}
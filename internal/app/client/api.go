@@ -1,13 +1,34 @@
 package client
 
-import "github.com/vloryan/go-libs/jsonapi"
+import (
+	"strconv"
+
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/protrakgon/internal/app/server"
+)
 
 var Clients = NewService(NewRepository())
 
+// NewInclude makes the client of a resource includable, the client is a nested object with its id.
+func NewInclude(service server.CrudService[*Client, *Filter]) *server.Include {
+	return &server.Include{
+		Relation: server.Relation{Name: "client", Type: "client"},
+		Resolve: server.ResolveByIDs[*Client, *Filter](service, func(ids []int) *Filter {
+			return &Filter{IDs: ids}
+		}),
+		SelfLink: func(item server.Resource) string {
+			return "client/" + item.GetIdentifier().ID
+		},
+	}
+}
+
 func Handlers() []jsonapi.ResourceHandler {
+	server.RegisterAtomicResource("client", server.CrudService[*Client, *Filter](Clients), func() *Client {
+		return &Client{}
+	}, func(item *Client) string {
+		return "client/" + strconv.Itoa(item.ID)
+	})
 	return []jsonapi.ResourceHandler{
-		&Handler{
-			Service: Clients,
-		},
+		NewHandler(Clients),
 	}
 }
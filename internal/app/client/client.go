@@ -3,7 +3,6 @@ package client
 import (
 	"errors"
 	"strconv"
-	"strings"
 
 	"github.com/rs/zerolog/log"
 	"github.com/vloryan/go-libs/jsonapi"
@@ -13,8 +12,9 @@ import (
 
 type Client struct {
 	ID          int     `json:"id,omitempty"`
-	Name        string  `json:"name,omitempty"`
-	Description *string `json:"description,omitempty"`
+	Name        string  `json:"name,omitempty" validate:"required,max=100"`
+	Description *string `json:"description,omitempty" validate:"max=1000"`
+	Version     int     `json:"version,omitempty"`
 }
 
 func (p *Client) SetIdentifier(id *jsonapi.ResourceIdentifierObject) {
@@ -43,10 +43,15 @@ func (p *Client) GetIdentifier() *jsonapi.ResourceIdentifierObject {
 	return id
 }
 
+func (p *Client) GetVersion() int {
+	return p.Version
+}
+
 type Filter struct {
-	ID          *int    `form:"filter[id]"`
-	Name        string  `form:"filter[name]"`
-	Description *string `form:"filter[description]"`
+	ID          *int    `form:"filter[id]" query:"id,eq"`
+	IDs         []int   `form:"-" query:"id,in"`
+	Name        string  `form:"filter[name]" query:"name,like"`
+	Description *string `form:"filter[description]" query:"description,like"`
 }
 
 type Service interface {
@@ -82,6 +87,16 @@ func (d *service) Delete(tx db.Transaction, id int) error {
 
 type Repository struct{}
 
+var clientQuery = &db.Query{
+	Table:   "client",
+	Columns: []string{"id", "name", "description", "version"},
+	SortFields: map[string]string{
+		"id":          "id",
+		"name":        "name",
+		"description": "description",
+	},
+}
+
 func NewRepository() db.CRUDRepository[*Client, *Filter] {
 	return &Repository{}
 }
@@ -100,14 +115,17 @@ func (r *Repository) Save(tx db.Transaction, item *Client) error {
 			return err
 		}
 		item.ID = int(id)
+		item.Version = 1
 		return nil
 	} else {
 		stmt := `UPDATE client 
 				    SET
 						name = :name, 
-						description = :description
+						description = :description,
+						version = version + 1
 				  WHERE 
-				        id = :id`
+				        id = :id
+				    AND version = :version`
 
 		result, err := tx.Exec(stmt, item)
 		if err != nil {
@@ -118,15 +136,16 @@ func (r *Repository) Save(tx db.Transaction, item *Client) error {
 			return err
 		}
 		if affected == 0 {
-			return errors.New("update failed: 0 rows affected")
+			return db.UpdateError(tx, "client", item.ID)
 		}
+		item.Version++
 	}
 	return nil
 }
 
 func (r *Repository) GetByID(tx db.Transaction, id int) (*Client, error) {
 	item := &Client{}
-	stmt := `SELECT id, name, description 
+	stmt := `SELECT id, name, description, version
 			   FROM client 
 			  WHERE id = :id`
 
@@ -141,39 +160,7 @@ func (r *Repository) GetByID(tx db.Transaction, id int) (*Client, error) {
 
 func (r *Repository) GetAll(tx db.Transaction, page *pagination.Page, filter *Filter) ([]*Client, error) {
 	items := make([]*Client, 0, 10)
-	stmt := `SELECT id, name, description 
-             FROM client`
-	countStmt := `SELECT COUNT(*) AS total_count 
-				    FROM client`
-
-	whereClause, whereParams := r.toWhereClause(filter)
-	selectParams := make(map[string]any)
-	for k, v := range whereParams {
-		selectParams[k] = v
-	}
-	if len(whereClause) > 0 {
-		stmt += "\n" + whereClause
-		countStmt += "\n" + whereClause
-	}
-	if page.Limit != -1 {
-		stmt += "\nLIMIT :limit"
-		selectParams["limit"] = page.Limit
-		if page.Offset != 0 {
-			stmt += "\nOFFSET :offset"
-			selectParams["offset"] = page.Offset * page.Limit
-		}
-	}
-	if len(whereClause) > 0 {
-		if err := tx.Select(page, countStmt, whereParams); err != nil {
-			return nil, err
-		}
-	} else {
-		if err := tx.Select(page, countStmt); err != nil {
-			return nil, err
-		}
-	}
-
-	if err := tx.Select(&items, stmt, selectParams); err != nil {
+	if err := clientQuery.GetAll(tx, &items, page, filter); err != nil {
 		return nil, err
 	}
 	return items, nil
@@ -192,30 +179,11 @@ func (r *Repository) Delete(tx db.Transaction, id int) error {
 	if err != nil {
 		return err
 	}
+	if affected == 0 {
+		return db.ErrNotFound
+	}
 	if affected != 1 {
 		return errors.New("delete failed: " + strconv.Itoa(int(affected)) + " rows affected")
 	}
 	return err
 }
-
-func (r *Repository) toWhereClause(filter *Filter) (string, map[string]any) {
-	clause := ""
-	parts := make([]string, 0, 10)
-	m := make(map[string]any)
-	if filter.ID != nil {
-		m["id"] = filter.ID
-		parts = append(parts, "id = :id")
-	}
-	if filter.Name != "" {
-		m["name"] = "%" + strings.ToLower(filter.Name) + "%"
-		parts = append(parts, "name LIKE :name")
-	}
-	if filter.Description != nil {
-		m["description"] = "%" + strings.ToLower(*filter.Description) + "%"
-		parts = append(parts, "description LIKE :description")
-	}
-	if len(parts) > 0 {
-		clause = "WHERE " + strings.Join(parts, " AND ")
-	}
-	return clause, m
-}
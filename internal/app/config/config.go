@@ -0,0 +1,528 @@
+// Package config loads the settings of the server from a configuration file, environment variables and
+// command line flags. Later sources override earlier ones:
+//
+//  1. defaults
+//  2. the configuration file: -config, CONFIG_FILE or protrakgon.toml in the working directory if it exists
+//  3. environment variables
+//  4. command line flags
+//
+// The configuration file is a subset of TOML, see parseTOML. Usage lists all settings with their keys,
+// variables and flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultFile is the configuration file read if neither -config nor CONFIG_FILE is set.
+const DefaultFile = "protrakgon.toml"
+
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+
+// Config holds the settings of the server.
+type Config struct {
+	// Debug enables debug logs and serves the assets from the working directory.
+	Debug  bool
+	Listen string
+	// ShutdownTimeout is the time in-flight requests get to finish when the server stops.
+	ShutdownTimeout time.Duration
+	ContextRoot     string
+	ProxyLocation   string
+	APIRoutePrefix  string
+	DBFile          string
+	TLS             TLS
+	Log             Log
+	Tracing         Tracing
+	Limits          Limits
+	CORS            CORS
+	CSRF            CSRF
+	Backup          Backup
+	AdminToken      string
+	// TimeZone is the IANA zone the weeks and days of timesheets are based on, empty is the local zone.
+	TimeZone string
+}
+
+// TLS enables HTTPS if CertFile and KeyFile are set or SelfSigned is enabled.
+type TLS struct {
+	CertFile string
+	KeyFile  string
+	// SelfSigned generates a certificate for Hosts, without files it is stored next to the database.
+	SelfSigned     bool
+	Hosts          []string
+	RedirectListen string
+	HSTSMaxAge     time.Duration
+}
+
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.SelfSigned
+}
+
+// Log configures the logger, empty values depend on Debug.
+type Log struct {
+	Level  string
+	Format string
+	// SlowQuery is the duration from which statements are logged as warning, 0 disables it.
+	SlowQuery time.Duration
+}
+
+// Tracing exports spans of requests and statements to an OpenTelemetry collector if OTLPEndpoint is set.
+type Tracing struct {
+	OTLPEndpoint string
+	ServiceName  string
+}
+
+// Limits protect the database from clients sending too many or too large requests to the API.
+type Limits struct {
+	// RequestsPerMinute of each client, 0 disables the rate limit.
+	RequestsPerMinute int
+	Burst             int
+	// TrustProxy identifies clients by the X-Forwarded-For header of a reverse proxy.
+	TrustProxy    bool
+	MaxBodySize   int64
+	MaxUploadSize int64
+}
+
+// CORS allows scripts of AllowedOrigins to call the API, it is disabled without origins.
+type CORS struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// CSRF requires a token in mutating requests authenticated by the cookie SessionCookie.
+type CSRF struct {
+	SessionCookie string
+	// Secret signs the tokens, without secret they expire on restart.
+	Secret string
+}
+
+type Backup struct {
+	Dir      string
+	Interval time.Duration
+	Keep     int
+}
+
+// LogLevel returns the configured level, without level it is debug in debug mode and info otherwise.
+func (c *Config) LogLevel() zerolog.Level {
+	if c.Log.Level == "" {
+		if c.Debug {
+			return zerolog.DebugLevel
+		}
+		return zerolog.InfoLevel
+	}
+	level, _ := zerolog.ParseLevel(c.Log.Level)
+	return level
+}
+
+// LogFormat returns the configured format, without format it is console in debug mode and json otherwise.
+func (c *Config) LogFormat() string {
+	if c.Log.Format == "" {
+		if c.Debug {
+			return LogFormatConsole
+		}
+		return LogFormatJSON
+	}
+	return c.Log.Format
+}
+
+// Location returns the time zone, without zone or with an unknown zone it is the local zone.
+func (c *Config) Location() *time.Location {
+	if c.TimeZone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+// option is a setting with its key in the configuration file, its environment variable and its flag.
+type option struct {
+	key   string
+	env   string
+	flag  string
+	def   string
+	usage string
+	set   func(c *Config, value string) error
+	bool  bool
+}
+
+var options = []*option{
+	{key: "debug", env: "DEBUG", flag: "debug", def: "false", usage: "enable debug mode", set: boolVar(func(c *Config) *bool { return &c.Debug }), bool: true},
+	{key: "server.listen", env: "LISTEN_ADDRESS", flag: "listen", def: ":8080", usage: "address the server listens on", set: stringVar(func(c *Config) *string { return &c.Listen })},
+	{key: "server.shutdown_timeout", env: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", def: "30s", usage: "time in-flight requests get to finish on SIGTERM", set: durationVar(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{key: "server.context_root", env: "CONTEXT_ROOT", flag: "context-root", def: "/", usage: "url prefix of all routes", set: stringVar(func(c *Config) *string { return &c.ContextRoot })},
+	{key: "server.proxy_location", env: "PROXY_LOCATION", flag: "proxy-location", def: "/", usage: "url prefix of a reverse proxy", set: stringVar(func(c *Config) *string { return &c.ProxyLocation })},
+	{key: "server.api_route_prefix", env: "API_ROUTE_PREFIX", flag: "api-route-prefix", def: "/v1", usage: "url prefix of the api", set: stringVar(func(c *Config) *string { return &c.APIRoutePrefix })},
+	{key: "database.file", env: "DB_FILE", flag: "db", def: "./db/protrakgon.db", usage: "sqlite database file", set: stringVar(func(c *Config) *string { return &c.DBFile })},
+	{key: "server.time_zone", env: "TIME_ZONE", flag: "time-zone", usage: "IANA time zone of timesheet weeks, e.g. Europe/Berlin, the local zone if empty", set: stringVar(func(c *Config) *string { return &c.TimeZone })},
+	{key: "tls.cert_file", env: "TLS_CERT_FILE", flag: "tls-cert", usage: "PEM certificate file, enables HTTPS", set: stringVar(func(c *Config) *string { return &c.TLS.CertFile })},
+	{key: "tls.key_file", env: "TLS_KEY_FILE", flag: "tls-key", usage: "PEM key file of the certificate", set: stringVar(func(c *Config) *string { return &c.TLS.KeyFile })},
+	{key: "tls.self_signed", env: "TLS_SELF_SIGNED", flag: "tls-self-signed", def: "false", usage: "generate a self-signed certificate, enables HTTPS", set: boolVar(func(c *Config) *bool { return &c.TLS.SelfSigned }), bool: true},
+	{key: "tls.hosts", env: "TLS_HOSTS", flag: "tls-hosts", usage: "comma separated names and addresses of the self-signed certificate, default localhost and the hostname", set: listVar(func(c *Config) *[]string { return &c.TLS.Hosts })},
+	{key: "tls.redirect_listen", env: "TLS_REDIRECT_LISTEN", flag: "tls-redirect-listen", usage: "address of a listener redirecting HTTP to HTTPS, e.g. :80", set: stringVar(func(c *Config) *string { return &c.TLS.RedirectListen })},
+	{key: "tls.hsts_max_age", env: "TLS_HSTS_MAX_AGE", flag: "tls-hsts-max-age", def: "4320h", usage: "max-age of the Strict-Transport-Security header, 0 disables it", set: durationVar(func(c *Config) *time.Duration { return &c.TLS.HSTSMaxAge })},
+	{key: "log.level", env: "LOG_LEVEL", flag: "log-level", usage: "trace, debug, info, warn or error, debug in debug mode otherwise info", set: stringVar(func(c *Config) *string { return &c.Log.Level })},
+	{key: "log.format", env: "LOG_FORMAT", flag: "log-format", usage: "json or console, console in debug mode otherwise json", set: stringVar(func(c *Config) *string { return &c.Log.Format })},
+	{key: "log.slow_query", env: "LOG_SLOW_QUERY", flag: "log-slow-query", def: "200ms", usage: "duration from which statements are logged as warning, 0 disables it", set: durationVar(func(c *Config) *time.Duration { return &c.Log.SlowQuery })},
+	{key: "tracing.otlp_endpoint", env: "OTLP_ENDPOINT", flag: "otlp-endpoint", usage: "OTLP/HTTP collector receiving the spans, e.g. http://localhost:4318, disabled if empty", set: stringVar(func(c *Config) *string { return &c.Tracing.OTLPEndpoint })},
+	{key: "tracing.service_name", env: "OTLP_SERVICE_NAME", flag: "otlp-service-name", def: "protrakgon", usage: "service name of the spans", set: stringVar(func(c *Config) *string { return &c.Tracing.ServiceName })},
+	{key: "limits.requests_per_minute", env: "RATE_LIMIT", flag: "rate-limit", def: "600", usage: "api requests per minute of each token or ip address, 0 disables the limit", set: intVar(func(c *Config) *int { return &c.Limits.RequestsPerMinute })},
+	{key: "limits.burst", env: "RATE_LIMIT_BURST", flag: "rate-limit-burst", def: "60", usage: "api requests a client may send at once", set: intVar(func(c *Config) *int { return &c.Limits.Burst })},
+	{key: "limits.trust_proxy", env: "RATE_LIMIT_TRUST_PROXY", flag: "rate-limit-trust-proxy", def: "false", usage: "identify clients by the X-Forwarded-For header of a reverse proxy", set: boolVar(func(c *Config) *bool { return &c.Limits.TrustProxy }), bool: true},
+	{key: "limits.max_body_size", env: "MAX_BODY_SIZE", flag: "max-body-size", def: "1MiB", usage: "maximum size of json request documents", set: sizeVar(func(c *Config) *int64 { return &c.Limits.MaxBodySize })},
+	{key: "limits.max_upload_size", env: "MAX_UPLOAD_SIZE", flag: "max-upload-size", def: "10MiB", usage: "maximum size of uploaded files", set: sizeVar(func(c *Config) *int64 { return &c.Limits.MaxUploadSize })},
+	{key: "cors.allowed_origins", env: "CORS_ALLOWED_ORIGINS", flag: "cors-allowed-origins", usage: "comma separated origins allowed to call the api, e.g. https://dashboard.lan, * for all, disabled if empty", set: listVar(func(c *Config) *[]string { return &c.CORS.AllowedOrigins })},
+	{key: "cors.allowed_methods", env: "CORS_ALLOWED_METHODS", flag: "cors-allowed-methods", def: "GET,POST,PUT,PATCH,DELETE", usage: "comma separated methods allowed to other origins", set: listVar(func(c *Config) *[]string { return &c.CORS.AllowedMethods })},
+	{key: "cors.allowed_headers", env: "CORS_ALLOWED_HEADERS", flag: "cors-allowed-headers", def: "Accept,Authorization,Content-Type,If-Match,If-None-Match,X-CSRF-Token,X-Request-ID", usage: "comma separated request headers allowed to other origins", set: listVar(func(c *Config) *[]string { return &c.CORS.AllowedHeaders })},
+	{key: "cors.allow_credentials", env: "CORS_ALLOW_CREDENTIALS", flag: "cors-allow-credentials", def: "false", usage: "let other origins send cookies", set: boolVar(func(c *Config) *bool { return &c.CORS.AllowCredentials }), bool: true},
+	{key: "cors.max_age", env: "CORS_MAX_AGE", flag: "cors-max-age", def: "10m", usage: "time browsers cache preflight results", set: durationVar(func(c *Config) *time.Duration { return &c.CORS.MaxAge })},
+	{key: "csrf.session_cookie", env: "CSRF_SESSION_COOKIE", flag: "csrf-session-cookie", def: "protrakgon_session", usage: "cookie of sessions whose mutating requests need a csrf token", set: stringVar(func(c *Config) *string { return &c.CSRF.SessionCookie })},
+	// like the admin token the secret has no flag
+	{key: "csrf.secret", env: "CSRF_SECRET", usage: "secret signing the csrf tokens, random if empty", set: stringVar(func(c *Config) *string { return &c.CSRF.Secret })},
+	{key: "backup.dir", env: "BACKUP_DIR", flag: "backup-dir", def: "./db/backup", usage: "directory of the backups", set: stringVar(func(c *Config) *string { return &c.Backup.Dir })},
+	{key: "backup.interval", env: "BACKUP_INTERVAL", flag: "backup-interval", def: "24h", usage: "interval of scheduled backups, 0 disables them", set: durationVar(func(c *Config) *time.Duration { return &c.Backup.Interval })},
+	{key: "backup.keep", env: "BACKUP_KEEP", flag: "backup-keep", def: "7", usage: "number of kept backups, 0 keeps all", set: intVar(func(c *Config) *int { return &c.Backup.Keep })},
+	// the admin token has no flag, the arguments of a process are visible to all users
+	{key: "admin.token", env: "ADMIN_TOKEN", usage: "bearer token of the admin endpoints, disabled if empty", set: stringVar(func(c *Config) *string { return &c.AdminToken })},
+}
+
+func stringVar(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func listVar(field func(c *Config) *[]string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+func boolVar(field func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func intVar(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		*field(c) = i
+		return nil
+	}
+}
+
+func durationVar(field func(c *Config) *time.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, e.g. 24h or 30m", value)
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+var sizeUnits = []struct {
+	suffix string
+	factor int64
+}{{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"B", 1}}
+
+// sizeVar accepts a number of bytes with an optional unit, e.g. 512KiB or 10MB.
+func sizeVar(field func(c *Config) *int64) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		number, factor := strings.TrimSpace(value), int64(1)
+		for _, unit := range sizeUnits {
+			if n, ok := strings.CutSuffix(number, unit.suffix); ok {
+				number, factor = strings.TrimSpace(n), unit.factor
+				break
+			}
+		}
+		i, err := strconv.ParseInt(number, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid size %q, e.g. 512KiB or 10MiB", value)
+		}
+		*field(c) = i * factor
+		return nil
+	}
+}
+
+// flagValue collects the flags set on the command line, they are applied after the file and the environment.
+type flagValue struct {
+	option *option
+	values map[*option]string
+}
+
+func (f *flagValue) String() string {
+	return ""
+}
+
+func (f *flagValue) Set(value string) error {
+	f.values[f.option] = value
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.option.bool
+}
+
+// Load reads the configuration from the flags of args, the environment and the configuration file and validates it.
+// The arguments following the flags are returned. lookupEnv is usually os.LookupEnv.
+func Load(args []string, lookupEnv func(key string) (string, bool)) (*Config, []string, error) {
+	flags := flag.NewFlagSet("protrakgon", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	configFile := flags.String("config", "", "")
+	flagValues := make(map[*option]string)
+	for _, o := range options {
+		if o.flag != "" {
+			flags.Var(&flagValue{option: o, values: flagValues}, o.flag, o.usage)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := &Config{}
+	for _, o := range options {
+		if o.def == "" {
+			continue
+		}
+		if err := o.set(cfg, o.def); err != nil {
+			panic(fmt.Errorf("invalid default of %s: %w", o.key, err))
+		}
+	}
+	fileValues, err := readFile(*configFile, lookupEnv)
+	if err != nil {
+		return nil, nil, err
+	}
+	var errs []error
+	for _, o := range options {
+		if value, ok := fileValues[o.key]; ok {
+			delete(fileValues, o.key)
+			if err := o.set(cfg, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", o.key, err))
+			}
+		}
+		// an empty variable clears the value of the file, the setting gets its default
+		if value, ok := lookupEnv(o.env); ok {
+			if value == "" {
+				value = o.def
+			}
+			if err := o.set(cfg, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", o.env, err))
+			}
+		}
+		if value, ok := flagValues[o]; ok {
+			if err := o.set(cfg, value); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", o.flag, err))
+			}
+		}
+	}
+	for key := range fileValues {
+		errs = append(errs, fmt.Errorf("%s: unknown setting", key))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+	cfg.complete()
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+// readFile reads the configuration file fileName, CONFIG_FILE or DefaultFile. Only DefaultFile may be missing.
+func readFile(fileName string, lookupEnv func(key string) (string, bool)) (map[string]string, error) {
+	if fileName == "" {
+		fileName, _ = lookupEnv("CONFIG_FILE")
+	}
+	optional := fileName == ""
+	if optional {
+		fileName = DefaultFile
+	}
+	file, err := os.Open(fileName)
+	if optional && errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	values, err := parseTOML(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return values, nil
+}
+
+// complete sets the defaults depending on other settings.
+func (c *Config) complete() {
+	if !c.TLS.SelfSigned {
+		return
+	}
+	if c.TLS.CertFile == "" && c.TLS.KeyFile == "" {
+		dir := filepath.Join(filepath.Dir(c.DBFile), "tls")
+		c.TLS.CertFile = filepath.Join(dir, "cert.pem")
+		c.TLS.KeyFile = filepath.Join(dir, "key.pem")
+	}
+	if len(c.TLS.Hosts) == 0 {
+		c.TLS.Hosts = []string{"localhost"}
+		if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+			c.TLS.Hosts = append(c.TLS.Hosts, hostname)
+		}
+	}
+}
+
+// Validate checks the values of the configuration, all problems are reported at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("listen address %q: %w", c.Listen, err))
+	}
+	for _, prefix := range []struct{ name, value string }{
+		{"context root", c.ContextRoot}, {"proxy location", c.ProxyLocation}, {"api route prefix", c.APIRoutePrefix},
+	} {
+		if !strings.HasPrefix(prefix.value, "/") {
+			errs = append(errs, fmt.Errorf("%s %q must start with /", prefix.name, prefix.value))
+		}
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown timeout %s must be positive", c.ShutdownTimeout))
+	}
+	if c.DBFile == "" {
+		errs = append(errs, errors.New("database file must not be empty"))
+	}
+	if c.TimeZone != "" {
+		if _, err := time.LoadLocation(c.TimeZone); err != nil {
+			errs = append(errs, fmt.Errorf("time zone %q is unknown", c.TimeZone))
+		}
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls needs a certificate and a key file"))
+	}
+	for _, fileName := range []string{c.TLS.CertFile, c.TLS.KeyFile} {
+		if fileName == "" || c.TLS.SelfSigned {
+			// self-signed certificates are generated if the files do not exist
+			continue
+		}
+		if _, err := os.Stat(fileName); err != nil {
+			errs = append(errs, fmt.Errorf("tls: %w", err))
+		}
+	}
+	if c.TLS.RedirectListen != "" {
+		if !c.TLS.Enabled() {
+			errs = append(errs, errors.New("tls redirect needs tls"))
+		} else if _, _, err := net.SplitHostPort(c.TLS.RedirectListen); err != nil {
+			errs = append(errs, fmt.Errorf("tls redirect address %q: %w", c.TLS.RedirectListen, err))
+		}
+	}
+	if c.TLS.HSTSMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("hsts max age %s must not be negative", c.TLS.HSTSMaxAge))
+	}
+	if c.Log.Level != "" {
+		if _, err := zerolog.ParseLevel(c.Log.Level); err != nil {
+			errs = append(errs, fmt.Errorf("log level %q is unknown", c.Log.Level))
+		}
+	}
+	if c.Log.Format != "" && c.Log.Format != LogFormatJSON && c.Log.Format != LogFormatConsole {
+		errs = append(errs, fmt.Errorf("log format %q must be json or console", c.Log.Format))
+	}
+	if c.Log.SlowQuery < 0 {
+		errs = append(errs, fmt.Errorf("slow query threshold %s must not be negative", c.Log.SlowQuery))
+	}
+	if c.Tracing.OTLPEndpoint != "" {
+		if u, err := url.Parse(c.Tracing.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("otlp endpoint %q must be an http or https url", c.Tracing.OTLPEndpoint))
+		}
+	}
+	if c.Limits.RequestsPerMinute < 0 {
+		errs = append(errs, fmt.Errorf("rate limit %d must not be negative", c.Limits.RequestsPerMinute))
+	}
+	if c.Limits.RequestsPerMinute > 0 && c.Limits.Burst < 1 {
+		errs = append(errs, fmt.Errorf("rate limit burst %d must be positive", c.Limits.Burst))
+	}
+	for _, size := range []struct {
+		name  string
+		value int64
+	}{{"max body size", c.Limits.MaxBodySize}, {"max upload size", c.Limits.MaxUploadSize}} {
+		if size.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s %d must be positive", size.name, size.value))
+		}
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			if c.CORS.AllowCredentials {
+				errs = append(errs, errors.New("cors origin * cannot be combined with credentials"))
+			}
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "chrome-extension" && u.Scheme != "moz-extension") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			errs = append(errs, fmt.Errorf("cors origin %q must be a scheme and host like https://dashboard.lan", origin))
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("cors max age %s must not be negative", c.CORS.MaxAge))
+	}
+	if c.Backup.Interval < 0 {
+		errs = append(errs, fmt.Errorf("backup interval %s must not be negative", c.Backup.Interval))
+	}
+	if c.Backup.Keep < 0 {
+		errs = append(errs, fmt.Errorf("backup keep %d must not be negative", c.Backup.Keep))
+	}
+	return errors.Join(errs...)
+}
+
+// Usage writes the settings with their keys in the configuration file, environment variables and flags.
+func Usage(out io.Writer) {
+	_, _ = fmt.Fprintln(out, "settings, flags override environment variables, which override the configuration file:")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "  -config\tCONFIG_FILE\t\tconfiguration file, default "+DefaultFile)
+	for _, o := range options {
+		flagName := ""
+		if o.flag != "" {
+			flagName = "-" + o.flag
+		}
+		usage := o.usage
+		if o.def != "" {
+			usage += ", default " + o.def
+		}
+		_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", flagName, o.env, o.key, usage)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "protrakgon.toml")
+	content := `# protrakgon
+debug = true
+
+[server]
+listen = "127.0.0.1:9000" # local only
+context_root = '/time'
+
+[backup]
+interval = "1h"
+keep = 3
+
+[cors]
+allowed_origins = ["https://a.lan", "https://b.lan"]
+`
+	if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defaults := func() *Config {
+		return &Config{
+			Listen:          ":8080",
+			ShutdownTimeout: 30 * time.Second,
+			ContextRoot:     "/",
+			ProxyLocation:   "/",
+			APIRoutePrefix:  "/v1",
+			DBFile:          "./db/protrakgon.db",
+			TLS:             TLS{HSTSMaxAge: 4320 * time.Hour},
+			Log:             Log{SlowQuery: 200 * time.Millisecond},
+			Tracing:         Tracing{ServiceName: "protrakgon"},
+			Limits:          Limits{RequestsPerMinute: 600, Burst: 60, MaxBodySize: 1 << 20, MaxUploadSize: 10 << 20},
+			CORS: CORS{
+				AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+				AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "If-Match", "If-None-Match", "X-CSRF-Token", "X-Request-ID"},
+				MaxAge:         10 * time.Minute,
+			},
+			CSRF:   CSRF{SessionCookie: "protrakgon_session"},
+			Backup: Backup{Dir: "./db/backup", Interval: 24 * time.Hour, Keep: 7},
+		}
+	}
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		want     func() *Config
+		wantArgs []string
+		wantErr  string
+	}{{
+		name:     "GIVEN nothing THEN use defaults",
+		args:     []string{"serve"},
+		want:     defaults,
+		wantArgs: []string{"serve"},
+	}, {
+		name: "GIVEN file THEN override defaults",
+		args: []string{"-config", configFile},
+		want: func() *Config {
+			cfg := defaults()
+			cfg.Debug = true
+			cfg.Listen = "127.0.0.1:9000"
+			cfg.ContextRoot = "/time"
+			cfg.Backup.Interval = time.Hour
+			cfg.Backup.Keep = 3
+			cfg.CORS.AllowedOrigins = []string{"https://a.lan", "https://b.lan"}
+			return cfg
+		},
+		wantArgs: []string{},
+	}, {
+		name: "GIVEN empty env THEN clear value of file",
+		args: []string{"-config", configFile},
+		env:  map[string]string{"CONTEXT_ROOT": "", "BACKUP_KEEP": "", "CORS_ALLOWED_ORIGINS": ""},
+		want: func() *Config {
+			cfg := defaults()
+			cfg.Debug = true
+			cfg.Listen = "127.0.0.1:9000"
+			cfg.Backup.Interval = time.Hour
+			return cfg
+		},
+		wantArgs: []string{},
+	}, {
+		name: "GIVEN file env and flags THEN flags override env override file",
+		args: []string{"-listen", ":9443", "-debug=false", "backup"},
+		env:  map[string]string{"CONFIG_FILE": configFile, "LISTEN_ADDRESS": ":9001", "BACKUP_KEEP": "5", "DB_FILE": "/data/p.db", "ADMIN_TOKEN": "secret"},
+		want: func() *Config {
+			cfg := defaults()
+			cfg.Listen = ":9443"
+			cfg.ContextRoot = "/time"
+			cfg.DBFile = "/data/p.db"
+			cfg.Backup.Interval = time.Hour
+			cfg.Backup.Keep = 5
+			cfg.CORS.AllowedOrigins = []string{"https://a.lan", "https://b.lan"}
+			cfg.AdminToken = "secret"
+			return cfg
+		},
+		wantArgs: []string{"backup"},
+	}, {
+		name: "GIVEN self-signed tls THEN store certificate next to database",
+		args: []string{"-db", "/data/p.db", "-tls-self-signed", "-tls-hosts", "time.lan, 192.168.1.5", "-tls-redirect-listen", ":80"},
+		want: func() *Config {
+			cfg := defaults()
+			cfg.DBFile = "/data/p.db"
+			cfg.TLS = TLS{
+				CertFile:       "/data/tls/cert.pem",
+				KeyFile:        "/data/tls/key.pem",
+				SelfSigned:     true,
+				Hosts:          []string{"time.lan", "192.168.1.5"},
+				RedirectListen: ":80",
+				HSTSMaxAge:     4320 * time.Hour,
+			}
+			return cfg
+		},
+		wantArgs: []string{},
+	}, {
+		name: "GIVEN limits THEN parse sizes",
+		args: []string{"-rate-limit", "0", "-max-body-size", "512KiB", "-max-upload-size", "20 MB"},
+		env:  map[string]string{"RATE_LIMIT_TRUST_PROXY": "true"},
+		want: func() *Config {
+			cfg := defaults()
+			cfg.Limits = Limits{Burst: 60, TrustProxy: true, MaxBodySize: 512 << 10, MaxUploadSize: 20e6}
+			return cfg
+		},
+		wantArgs: []string{},
+	}, {
+		name:    "GIVEN invalid size THEN fail",
+		args:    []string{"-max-body-size", "1 ton"},
+		wantErr: "-max-body-size: invalid size \"1 ton\"",
+	}, {
+		name:    "GIVEN any origin with credentials THEN fail",
+		args:    []string{"-cors-allowed-origins", "*,dashboard.lan", "-cors-allow-credentials"},
+		wantErr: "cors origin * cannot be combined with credentials\ncors origin \"dashboard.lan\" must be a scheme and host like https://dashboard.lan",
+	}, {
+		name:    "GIVEN unknown time zone THEN fail",
+		env:     map[string]string{"TIME_ZONE": "Europe/Atlantis"},
+		wantErr: "time zone \"Europe/Atlantis\" is unknown",
+	}, {
+		name:    "GIVEN invalid env value THEN fail",
+		env:     map[string]string{"BACKUP_KEEP": "many"},
+		wantErr: "BACKUP_KEEP: invalid number \"many\"",
+	}, {
+		name:    "GIVEN invalid values THEN report all problems",
+		args:    []string{"-listen", "8080", "-log-format", "xml", "-tls-cert", "cert.pem"},
+		wantErr: "listen address \"8080\": address 8080: missing port in address\ntls needs a certificate and a key file\ntls: stat cert.pem: no such file or directory\nlog format \"xml\" must be json or console",
+	}, {
+		name:    "GIVEN missing config file THEN fail",
+		args:    []string{"-config", filepath.Join(dir, "missing.toml")},
+		wantErr: "no such file or directory",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookupEnv := func(key string) (string, bool) {
+				value, ok := tt.env[key]
+				return value, ok
+			}
+			got, gotArgs, err := Load(tt.args, lookupEnv)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want(), got); diff != "" {
+				t.Errorf("Load() mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantArgs, gotArgs); diff != "" {
+				t.Errorf("Load() args mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr string
+	}{{
+		name:    "GIVEN tables and values THEN return dotted keys",
+		content: "a = 1_000\n[log]\nlevel = \"warn\" # comment\nformat = 'json'\n[tls]\ncert_file = \"C:\\\\certs\\\\a#b.pem\"\n",
+		want:    map[string]string{"a": "1000", "log.level": "warn", "log.format": "json", "tls.cert_file": `C:\certs\a#b.pem`},
+	}, {
+		name:    "GIVEN arrays and dotted keys THEN join items by commas",
+		content: "[cors]\nallowed_origins = [\"https://a\", 'https://b']\nallowed_methods = [ # methods\n  \"GET\",\n  \"POST\", # create\n]\ntls.hosts = []\n",
+		want:    map[string]string{"cors.allowed_origins": "https://a,https://b", "cors.allowed_methods": "GET,POST", "cors.tls.hosts": ""},
+	}, {
+		name:    "GIVEN unterminated array THEN fail",
+		content: "[cors]\nallowed_origins = [\"https://a\"\n",
+		wantErr: "line 2: allowed_origins: unterminated array",
+	}, {
+		name:    "GIVEN inline table THEN fail",
+		content: "tls = { self_signed = true }\n",
+		wantErr: "line 1: tls: inline tables are not supported",
+	}, {
+		name:    "GIVEN unquoted string THEN fail",
+		content: "[server]\nlisten = :8080\n",
+		wantErr: "line 2: listen: invalid value \":8080\", strings need quotes",
+	}, {
+		name:    "GIVEN duplicate key THEN fail",
+		content: "debug = true\ndebug = false\n",
+		wantErr: "line 2: duplicate key debug",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTOML(strings.NewReader(tt.content))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("parseTOML() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTOML() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("parseTOML() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
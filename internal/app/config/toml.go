@@ -0,0 +1,183 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	tomlTable = regexp.MustCompile(`^\[\s*([A-Za-z0-9_-]+(?:\.[A-Za-z0-9_-]+)*)\s*\]$`)
+	tomlKey   = regexp.MustCompile(`^[A-Za-z0-9_-]+(?:\.[A-Za-z0-9_-]+)*$`)
+)
+
+// errUnterminatedArray is returned for arrays continued on the next line.
+var errUnterminatedArray = errors.New("unterminated array")
+
+// parseTOML reads the subset of TOML used by configuration files: tables and dotted keys with string,
+// integer or boolean values and arrays of them, which may span lines. Inline tables, arrays of tables,
+// multi-line strings, floats and dates are rejected. The values are returned as strings by their dotted
+// keys, e.g. "server.listen", the items of arrays are joined by commas like lists of environment variables.
+func parseTOML(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	table := ""
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			match := tomlTable.FindStringSubmatch(stripComment(line))
+			if match == nil {
+				return nil, fmt.Errorf("line %d: invalid table %s", lineNo, line)
+			}
+			table = match[1] + "."
+			continue
+		}
+		key, rawValue, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !tomlKey.MatchString(key) {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		startLine := lineNo
+		rawValue = strings.TrimSpace(rawValue)
+		value, err := parseTOMLValue(rawValue)
+		for errors.Is(err, errUnterminatedArray) && scanner.Scan() {
+			lineNo++
+			rawValue += "\n" + scanner.Text()
+			value, err = parseTOMLValue(rawValue)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", startLine, key, err)
+		}
+		if _, ok := values[table+key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %s", startLine, table+key)
+		}
+		values[table+key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func parseTOMLValue(raw string) (string, error) {
+	var value, rest string
+	var err error
+	if strings.HasPrefix(raw, "[") {
+		value, rest, err = parseTOMLArray(raw)
+	} else {
+		value, rest, err = parseTOMLScalar(raw)
+	}
+	if err != nil {
+		return "", err
+	}
+	if rest = stripComment(rest); rest != "" {
+		return "", fmt.Errorf("unexpected %s after value", rest)
+	}
+	return value, nil
+}
+
+// parseTOMLArray parses the array at the start of s and returns its items joined by commas and the rest of s.
+func parseTOMLArray(s string) (string, string, error) {
+	var items []string
+	rest := skipTOMLSpace(s[1:])
+	for !strings.HasPrefix(rest, "]") {
+		if rest == "" {
+			return "", "", errUnterminatedArray
+		}
+		item, next, err := parseTOMLScalar(rest)
+		if err != nil {
+			return "", "", err
+		}
+		if strings.Contains(item, ",") {
+			return "", "", fmt.Errorf("array item %q must not contain a comma", item)
+		}
+		items = append(items, item)
+		rest = skipTOMLSpace(next)
+		if strings.HasPrefix(rest, ",") {
+			rest = skipTOMLSpace(rest[1:])
+		} else if rest != "" && !strings.HasPrefix(rest, "]") {
+			return "", "", fmt.Errorf("expected , or ] instead of %s", strings.Fields(rest)[0])
+		}
+	}
+	return strings.Join(items, ","), rest[1:], nil
+}
+
+// parseTOMLScalar parses the string, integer or boolean at the start of s and returns it and the rest of s.
+func parseTOMLScalar(s string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(s, `"""`), strings.HasPrefix(s, "'''"):
+		return "", "", errors.New("multi-line strings are not supported")
+	case strings.HasPrefix(s, `"`):
+		end := closingQuote(s)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %s", firstLine(s))
+		}
+		value, err := strconv.Unquote(s[:end+1])
+		return value, s[end+1:], err
+	case strings.HasPrefix(s, "'"):
+		end := strings.IndexAny(s[1:], "'\n")
+		if end < 0 || s[end+1] != '\'' {
+			return "", "", fmt.Errorf("unterminated string %s", firstLine(s))
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case strings.HasPrefix(s, "{"):
+		return "", "", errors.New("inline tables are not supported")
+	}
+	end := strings.IndexAny(s, ",]# \t\r\n")
+	if end < 0 {
+		end = len(s)
+	}
+	value := s[:end]
+	if value == "true" || value == "false" {
+		return value, s[end:], nil
+	}
+	if _, err := strconv.ParseInt(strings.ReplaceAll(value, "_", ""), 10, 64); err == nil {
+		return strings.ReplaceAll(value, "_", ""), s[end:], nil
+	}
+	return "", "", fmt.Errorf("invalid value %q, strings need quotes", value)
+}
+
+// skipTOMLSpace removes the leading white space, line breaks and comments of s.
+func skipTOMLSpace(s string) string {
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if !strings.HasPrefix(s, "#") {
+			return s
+		}
+		_, s, _ = strings.Cut(s, "\n")
+	}
+}
+
+// closingQuote returns the index of the quote ending the basic string at the start of s.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		case '\n':
+			return -1
+		}
+	}
+	return -1
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func stripComment(s string) string {
+	if i := strings.Index(s, "#"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
@@ -1,12 +1,93 @@
 package project
 
 import (
+	"fmt"
+	"net/http"
+	"strconv"
+
 	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/go-libs/sqlx/pagination"
 	"github.com/vloryan/protrakgon/internal/app/client"
+	"github.com/vloryan/protrakgon/internal/app/server"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
 )
 
-func Handlers() []jsonapi.ResourceHandler {
+func Handlers(options Options) []jsonapi.ResourceHandler {
+	handler := NewHandler(client.Clients, options)
+	server.RegisterError(ErrOpenSlotExists, http.StatusConflict, "open_slot_exists", "/data/attributes/end")
+	server.RegisterError(ErrSlotOverlaps, http.StatusConflict, "slot_overlaps", "/data/attributes/start")
+	server.RegisterError(ErrSlotEndsBeforeStart, http.StatusUnprocessableEntity, "slot_ends_before_start", "/data/attributes/end")
+	server.RegisterError(ErrSlotEndsOnDifferentDay, http.StatusUnprocessableEntity, "slot_ends_on_different_day", "/data/attributes/end")
+	server.RegisterError(ErrSlotHasNoEnd, http.StatusUnprocessableEntity, "slot_has_no_end", "/data/attributes/end")
+	server.RegisterError(ErrPeriodLocked, http.StatusConflict, "period_locked", "/data/attributes/start")
+	server.RegisterError(ErrSlotLocked, http.StatusConflict, "slot_locked", "")
+	server.RegisterError(ErrPeriodLockChanged, http.StatusConflict, "period_lock_changed", "")
+	server.RegisterError(ErrPeriodLockUnlocked, http.StatusConflict, "period_lock_unlocked", "/data/attributes/unlockReason")
+	server.RegisterError(ErrPeriodLockNotDeletable, http.StatusMethodNotAllowed, "period_lock_not_deletable", "")
+	server.RegisterError(ErrTimesheetExists, http.StatusConflict, "timesheet_exists", "/data/attributes/week")
+	server.RegisterError(ErrTimesheetWeekChanged, http.StatusUnprocessableEntity, "timesheet_week_changed", "/data/attributes/week")
+	server.RegisterError(ErrTimesheetTransition, http.StatusConflict, "timesheet_transition", "/data/attributes/state")
+	server.RegisterError(ErrTimesheetHasOpenSlot, http.StatusConflict, "timesheet_has_open_slot", "/data/attributes/state")
+	server.RegisterError(ErrTimesheetNotDeletable, http.StatusConflict, "timesheet_not_deletable", "")
+	server.RegisterError(ErrInvalidCalendar, http.StatusBadRequest, "invalid_calendar", "")
+	registerAtomicResources(handler, options.AdminToken)
+	server.Monitor.RegisterGauge("protrakgon_open_slots", "Number of slots without end.", func(tx db.Transaction) (float64, error) {
+		isOpen := true
+		slots, err := handler.SlotService.GetAll(tx, &pagination.Page{Limit: -1}, &SlotFilter{IsOpen: &isOpen})
+		return float64(len(slots)), err
+	})
 	return []jsonapi.ResourceHandler{
-		NewHandler(client.Clients),
+		handler,
 	}
 }
+
+// registerAtomicResources makes the resources of handler available to atomic operations and archives.
+// Slots start now unless the operation sets the start, like slots created with the slot endpoint. Period
+// locks and timesheet decisions need adminToken like at their endpoints, archives restore them after the
+// slots they lock.
+func registerAtomicResources(handler *Handler, adminToken string) {
+	server.RegisterAtomicResource("project", server.CrudService[*Project, *Filter](handler.Service), func() *Project {
+		return &Project{}
+	}, func(item *Project) string {
+		return "project/" + strconv.Itoa(item.ID)
+	}, server.Relation{Name: "client", Type: "client"})
+	server.RegisterAtomicResource("project.slot", server.CrudService[*Slot, *SlotFilter](handler.SlotService), func() *Slot {
+		slot := &Slot{}
+		handler.SlotService.Start(slot)
+		return slot
+	}, func(item *Slot) string {
+		return fmt.Sprintf("project/%d/slot/%d", item.ProjectID, item.ID)
+	}, server.Relation{Name: "project", Type: "project", Attribute: "projectId"})
+	server.RegisterAtomicResource("project.slotImportRule", server.CrudService[*SlotImportRule, *SlotImportRuleFilter](handler.SlotImportRuleService), func() *SlotImportRule {
+		return &SlotImportRule{}
+	}, func(item *SlotImportRule) string {
+		return "project/slotImportRule/" + strconv.Itoa(item.ID)
+	}, server.Relation{Name: "project", Type: "project", Attribute: "projectId"})
+	server.RegisterAtomicResource("project.timesheet", server.CrudService[*Timesheet, *TimesheetFilter](handler.TimesheetService), func() *Timesheet {
+		return &Timesheet{}
+	}, func(item *Timesheet) string {
+		return "project/timesheet/" + strconv.Itoa(item.ID)
+	})
+	server.SetAtomicOptions("project.timesheet", &server.AtomicOptions[*Timesheet]{
+		Authorize: func(req *http.Request, tx db.Transaction, item *Timesheet) error {
+			return authorizeDecision(req, tx, handler.TimesheetService, adminToken, item)
+		},
+		ImportAfter: []string{"project.slot"},
+		Restore:     handler.TimesheetService.Restore,
+	})
+	server.RegisterAtomicResource("project.periodLock", server.CrudService[*PeriodLock, *PeriodLockFilter](handler.PeriodLockService), func() *PeriodLock {
+		return &PeriodLock{}
+	}, func(item *PeriodLock) string {
+		return "project/periodLock/" + strconv.Itoa(item.ID)
+	}, server.Relation{Name: "client", Type: "client", Attribute: "clientId"})
+	server.SetAtomicOptions("project.periodLock", &server.AtomicOptions[*PeriodLock]{
+		Authorize: func(req *http.Request, _ db.Transaction, _ *PeriodLock) error {
+			if !server.IsAdmin(req, adminToken) {
+				return server.AdminRequired()
+			}
+			return nil
+		},
+		ImportAfter: []string{"project.slot"},
+		Restore:     handler.PeriodLockService.Restore,
+	})
+}
@@ -0,0 +1,227 @@
+package project
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/go-libs/testhelper"
+	"github.com/vloryan/protrakgon/internal/app/client"
+	"github.com/vloryan/protrakgon/internal/app/server"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/request"
+)
+
+// noTxConnection runs transactions without database.
+type noTxConnection struct{}
+
+func (noTxConnection) Select(_ any, _ string, _ ...any) error      { return nil }
+func (noTxConnection) Exec(_ string, _ ...any) (sql.Result, error) { return nil, nil }
+func (noTxConnection) DoTransaction(txFunc db.TxFunc) error        { return txFunc(nil) }
+func (noTxConnection) Close() error                                { return nil }
+
+// inMemResources stores resources in memory, new resources get the next id.
+type inMemResources[T server.Resource, F any] struct {
+	Items []T
+}
+
+func (s *inMemResources[T, F]) Save(_ db.Transaction, item T) error {
+	if id := item.GetIdentifier(); id.ID == "" {
+		s.Items = append(s.Items, item)
+		item.SetIdentifier(&jsonapi.ResourceIdentifierObject{Type: id.Type, ID: strconv.Itoa(len(s.Items))})
+	}
+	return nil
+}
+
+func (s *inMemResources[T, F]) GetByID(_ db.Transaction, id int) (T, error) {
+	for _, item := range s.Items {
+		if item.GetIdentifier().ID == strconv.Itoa(id) {
+			return item, nil
+		}
+	}
+	var none T
+	return none, nil
+}
+
+func (s *inMemResources[T, F]) GetAll(_ db.Transaction, _ *pagination.Page, _ F) ([]T, error) {
+	return s.Items, nil
+}
+
+func (s *inMemResources[T, F]) Delete(_ db.Transaction, _ int) error {
+	return nil
+}
+
+// atomicScenario are the repositories of the resources registered by registerAtomicResources.
+type atomicScenario struct {
+	clients    inMemResources[*client.Client, *client.Filter]
+	projects   inMemResources[*Project, *Filter]
+	rules      inMemResources[*SlotImportRule, *SlotImportRuleFilter]
+	slots      inMemSlotRepository
+	timesheets inMemTimesheetRepository
+	locks      inMemPeriodLockRepository
+}
+
+// register registers the clients and the resources of a Handler of the repositories of g.
+func (g *atomicScenario) register(adminToken string) {
+	now := func() time.Time {
+		return testhelper.FixedNow
+	}
+	timesheets := &timesheetService{repo: &g.timesheets, slotRepo: &g.slots, zone: cet, now: now}
+	locks := &periodLockService{repo: &g.locks, projects: &inMemProjectRepository{}, zone: cet, now: now}
+	server.RegisterAtomicResource("client", server.CrudService[*client.Client, *client.Filter](&g.clients), func() *client.Client {
+		return &client.Client{}
+	}, func(item *client.Client) string {
+		return "client/" + strconv.Itoa(item.ID)
+	})
+	registerAtomicResources(&Handler{
+		Service:               &g.projects,
+		SlotService:           &slotService{slotRepo: &g.slots, locks: []SlotLock{timesheets, locks}, now: now},
+		TimesheetService:      timesheets,
+		PeriodLockService:     locks,
+		SlotImportRuleService: &g.rules,
+	}, adminToken)
+}
+
+func TestRegisterAtomicResources_Slot(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantSlots []*Slot
+	}{{
+		name: "GIVEN add without start THEN start now",
+		body: `{"atomic:operations":[{"op":"add","data":{"type":"project.slot","attributes":{"activity":"work"},
+			"relationships":{"project":{"data":{"type":"project","id":"1"}}}}}]}`,
+		wantSlots: []*Slot{{ID: 1, ProjectID: 1, Activity: ActivityWork, Start: testhelper.FixedNow.Truncate(time.Minute)}},
+	}, {
+		name: "GIVEN add with start THEN keep start",
+		body: `{"atomic:operations":[{"op":"add","data":{"type":"project.slot","attributes":{"activity":"work","start":"2025-01-14T08:00:00Z"},
+			"relationships":{"project":{"data":{"type":"project","id":"1"}}}}}]}`,
+		wantSlots: []*Slot{{ID: 1, ProjectID: 1, Activity: ActivityWork, Start: time.Date(2025, 1, 14, 8, 0, 0, 0, time.UTC)}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &atomicScenario{}
+			g.register("secret")
+			req := httptest.NewRequest(http.MethodPost, "/operations", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", server.AtomicMediaType)
+			req = req.WithContext(context.WithValue(req.Context(), request.CtxKeyDatabase, db.Connection(noTxConnection{})))
+			rec := httptest.NewRecorder()
+
+			server.NewAtomicOperations().Handle(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Handle() status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			if diff := cmp.Diff(tt.wantSlots, g.slots.SavedSlots); diff != "" {
+				t.Errorf("Handle() slots mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegisterAtomicResources_authorize(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantStatus int
+	}{{
+		name: "GIVEN lock without admin token THEN reject with admin required",
+		body: `{"atomic:operations":[{"op":"add","data":{"type":"project.periodLock",
+			"attributes":{"until":"2025-01-31","reason":"payroll january"}}}]}`,
+		wantStatus: http.StatusUnauthorized,
+	}, {
+		name:  "GIVEN lock with admin token THEN add it",
+		token: "secret",
+		body: `{"atomic:operations":[{"op":"add","data":{"type":"project.periodLock",
+			"attributes":{"until":"2025-01-31","reason":"payroll january"}}}]}`,
+		wantStatus: http.StatusOK,
+	}, {
+		name: "GIVEN approval without admin token THEN reject with admin required",
+		body: `{"atomic:operations":[{"op":"update","data":{"type":"project.timesheet","id":"1",
+			"attributes":{"week":"2025-W03","state":"approved"}}}]}`,
+		wantStatus: http.StatusUnauthorized,
+	}, {
+		name: "GIVEN submission without admin token THEN update it",
+		body: `{"atomic:operations":[{"op":"update","data":{"type":"project.timesheet","id":"2",
+			"attributes":{"week":"2025-W04","state":"submitted"}}}]}`,
+		wantStatus: http.StatusOK,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &atomicScenario{timesheets: inMemTimesheetRepository{Timesheets: []*Timesheet{
+				{ID: 1, Week: "2025-W03", State: TimesheetSubmitted},
+				{ID: 2, Week: "2025-W04", State: TimesheetOpen},
+			}}}
+			g.register("secret")
+			req := httptest.NewRequest(http.MethodPost, "/operations", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", server.AtomicMediaType)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			req = req.WithContext(context.WithValue(req.Context(), request.CtxKeyDatabase, db.Connection(noTxConnection{})))
+			rec := httptest.NewRecorder()
+
+			server.NewAtomicOperations().Handle(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Handle() status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRegisterAtomicResources_archive(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2025, time.January, day, hour, 0, 0, 0, time.UTC)
+	}
+	submittedAt, decidedAt, unlockedAt := at(17, 18), at(20, 9), at(3, 12)
+	clientID := 1
+	exported := &atomicScenario{
+		clients:  inMemResources[*client.Client, *client.Filter]{Items: []*client.Client{{ID: 1, Name: "Acme"}}},
+		projects: inMemResources[*Project, *Filter]{Items: []*Project{{ID: 1, Name: "Relaunch", Client: &client.Client{ID: 1}}}},
+		slots: inMemSlotRepository{Slots: []*Slot{
+			{ID: 1, ProjectID: 1, Activity: ActivityWork, Start: at(14, 8), End: testhelper.Ptr(at(14, 10))},
+		}},
+		timesheets: inMemTimesheetRepository{Timesheets: []*Timesheet{
+			{ID: 1, Week: "2025-W03", State: TimesheetApproved, SubmittedAt: &submittedAt, DecidedAt: &decidedAt},
+		}},
+		locks: inMemPeriodLockRepository{Locks: []*PeriodLock{
+			{ID: 1, Until: "2024-12-31", Reason: "payroll", LockedAt: at(2, 9), UnlockReason: testhelper.Ptr("too early"), UnlockedAt: &unlockedAt},
+			{ID: 2, ClientID: &clientID, Until: "2025-01-31", Reason: "invoice january", LockedAt: at(31, 17)},
+		}},
+	}
+	exported.register("secret")
+	var archive bytes.Buffer
+	if _, err := server.ExportArchive(nil, &archive, 1); err != nil {
+		t.Fatalf("ExportArchive() error = %v", err)
+	}
+	imported := &atomicScenario{}
+	imported.register("secret")
+
+	_, err := server.ImportArchive(nil, bytes.NewReader(archive.Bytes()), int64(archive.Len()), 1)
+
+	if err != nil {
+		t.Fatalf("ImportArchive() error = %v", err)
+	}
+	if diff := cmp.Diff(exported.slots.Slots, imported.slots.Slots); diff != "" {
+		t.Errorf("ImportArchive() slots mismatch (-want +got):\n%s", diff)
+	}
+	ignoreTotals := cmpopts.IgnoreFields(Timesheet{}, "Start", "End", "Days", "TotalMinutes")
+	if diff := cmp.Diff(exported.timesheets.Timesheets, imported.timesheets.Timesheets, ignoreTotals); diff != "" {
+		t.Errorf("ImportArchive() timesheets mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(exported.locks.Locks, imported.locks.Locks); diff != "" {
+		t.Errorf("ImportArchive() locks mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -1,13 +1,12 @@
 package project
 
 import (
-	"errors"
+	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
 
-	"github.com/vloryan/go-libs/httpx"
 	"github.com/vloryan/go-libs/httpx/router"
 	"github.com/vloryan/go-libs/jsonapi"
 	"github.com/vloryan/protrakgon/internal/app/client"
@@ -16,330 +15,414 @@ import (
 	"github.com/vloryan/protrakgon/internal/app/server/request"
 )
 
-func NewHandler(clientService server.CrudService[*client.Client, *client.Filter]) *Handler {
+// Options configure the handlers of the projects.
+type Options struct {
+	// Zone is the time zone of the weeks of timesheets, the days of period locks and the floating times
+	// of imported calendars.
+	Zone *time.Location
+	// AdminToken authorizes changes of period locks and decisions on timesheets, without token they cannot be made.
+	AdminToken string
+}
+
+func NewHandler(clientService server.CrudService[*client.Client, *client.Filter], options Options) *Handler {
+	repo := NewRepository()
+	service := NewService(repo)
+	slotRepo := NewSlotRepository()
+	timesheetService := NewTimesheetService(NewTimesheetRepository(), slotRepo, options.Zone)
+	periodLockService := NewPeriodLockService(NewPeriodLockRepository(), repo, options.Zone)
+	slotService := NewSlotService(slotRepo, timesheetService, periodLockService)
+	slotImportRuleRepo := NewSlotImportRuleRepository()
+	clientInclude := client.NewInclude(clientService)
+	projectInclude := &server.Include{
+		Relation: server.Relation{Name: "project", Type: "project", Attribute: "projectId"},
+		Resolve: server.ResolveByIDs[*Project, *Filter](service, func(ids []int) *Filter {
+			return &Filter{IDs: ids}
+		}),
+		SelfLink: func(item server.Resource) string {
+			return "project/" + item.GetIdentifier().ID
+		},
+		Includes: []*server.Include{clientInclude},
+	}
 	return &Handler{
-		GenericHandler: jsonapi.GenericHandler[*Project]{
-			ResolveObjectWithReqFunc: func(req *http.Request, id *jsonapi.ResourceIdentifierObject) (*jsonapi.ResourceObject, *jsonapi.Error) {
-				tx := request.DB(req)
-				if id.Type == "client" {
-					iid, err := strconv.ParseInt(id.ID, 10, 64)
-					if err != nil {
-						return nil, jsonapi.NewError(http.StatusInternalServerError, "failed to parse id", err)
-					}
-					cust, err := clientService.GetByID(tx, int(iid))
-					if err != nil {
-						return nil, jsonapi.NewError(http.StatusInternalServerError, "failed to fetch client", err)
-					}
-					resObj, err := jsonapi.MarshalResourceObject(cust, nil)
-					if err != nil {
-						return nil, jsonapi.NewError(http.StatusInternalServerError, "failed to marshal client", err)
-					}
-					resObj.Links = map[string]any{"self": "client/" + id.ID}
-					return resObj, nil
-				}
-				return nil, jsonapi.NewError(http.StatusBadRequest, "unknown type "+id.Type, nil)
+		CrudHandler: &server.CrudHandler[*Project, *Filter]{
+			Service:  service,
+			Path:     "project",
+			IDParam:  ":projectID",
+			Tables:   []string{"project", "client"},
+			Includes: []*server.Include{clientInclude},
+			NewItem: func() *Project {
+				return &Project{}
+			},
+			NewFilter: func(_ *http.Request) *Filter {
+				return &Filter{}
+			},
+			New: func(_ *http.Request) *Project {
+				return &Project{Name: "New Project"}
 			},
-			DocumentUpdaters: []jsonapi.DocumentUpdater{server.SelfLinkUpdaterInstance},
 		},
-		Service: NewService(NewRepository()),
-		SlotHandler: &SlotHandler{
-			Service: NewSlotService(NewSlotRepository()),
+		Service:               service,
+		SlotService:           slotService,
+		TimesheetService:      timesheetService,
+		PeriodLockService:     periodLockService,
+		SlotImportRuleService: slotImportRuleRepo,
+		SlotHandler:           NewSlotHandler(slotService, projectInclude),
+		ActivityHandler:       &ActivityHandler{},
+		SlotImportRuleHandler: &server.CrudHandler[*SlotImportRule, *SlotImportRuleFilter]{
+			Service:  slotImportRuleRepo,
+			Path:     "slotImportRule",
+			IDParam:  ":ruleID",
+			Includes: []*server.Include{projectInclude},
+			NewItem: func() *SlotImportRule {
+				return &SlotImportRule{}
+			},
+			NewFilter: func(_ *http.Request) *SlotImportRuleFilter {
+				return &SlotImportRuleFilter{}
+			},
+			Link: func(_ *http.Request) string {
+				return "/project/slotImportRule"
+			},
+		},
+		SlotImportHandler: &SlotImportHandler{
+			Service: NewSlotImportService(slotImportRuleRepo, slotService),
+			Zone:    options.Zone,
 		},
-		ActivityHandler: &ActivityHandler{},
+		TimesheetHandler:  NewTimesheetHandler(timesheetService, options.Zone, options.AdminToken),
+		PeriodLockHandler: NewPeriodLockHandler(periodLockService, options.AdminToken),
 	}
 }
 
 type Handler struct {
-	jsonapi.GenericHandler[*Project]
-	Service         Service
-	SlotHandler     jsonapi.ResourceHandler
-	ActivityHandler jsonapi.ResourceHandler
+	*server.CrudHandler[*Project, *Filter]
+	Service               Service
+	SlotService           SlotService
+	TimesheetService      TimesheetService
+	PeriodLockService     PeriodLockService
+	SlotImportRuleService db.CRUDRepository[*SlotImportRule, *SlotImportRuleFilter]
+	SlotHandler           jsonapi.ResourceHandler
+	ActivityHandler       jsonapi.ResourceHandler
+	SlotImportRuleHandler jsonapi.ResourceHandler
+	SlotImportHandler     jsonapi.ResourceHandler
+	TimesheetHandler      jsonapi.ResourceHandler
+	PeriodLockHandler     jsonapi.ResourceHandler
 }
 
 func (h *Handler) RegisterRoutes(route router.RouteElement) {
-	projectRoute := route.SubRoute("project")
-	projectRoute.POST("", h.Handle(h.Create))
-	projectRoute.PATCH(":projectID", h.Handle(h.Update))
-	projectRoute.GET("", h.Handle(h.GetAll))
-	projectRoute.GET("new", h.Handle(h.New))
-	projectRoute.GET(":projectID", h.Handle(h.Get))
-	projectRoute.DELETE(":projectID", h.Handle(h.Delete))
+	h.CrudHandler.RegisterRoutes(route)
 
+	projectRoute := route.SubRoute("project")
 	h.SlotHandler.RegisterRoutes(projectRoute)
 	h.ActivityHandler.RegisterRoutes(projectRoute)
+	h.SlotImportRuleHandler.RegisterRoutes(projectRoute)
+	h.SlotImportHandler.RegisterRoutes(projectRoute)
+	h.TimesheetHandler.RegisterRoutes(projectRoute)
+	h.PeriodLockHandler.RegisterRoutes(projectRoute)
 }
 
-func (h *Handler) Create(req *http.Request) (data *jsonapi.DocumentData[*Project], jErr *jsonapi.Error) {
-	con := request.DB(req)
-	if err := con.DoTransaction(func(tx db.Transaction) error {
-		item := &Project{}
-		if err := httpx.ShouldBindWith(req, item, jsonapi.Binding); err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to bind body", err)
-		}
-		if err := h.Service.Save(tx, item); err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to save item", err)
-		}
+type SlotHandler struct {
+	*server.CrudHandler[*Slot, *SlotFilter]
+}
 
-		data = jsonapi.NewDocumentData[*Project](item, "/project")
-		return nil
-	}); err != nil {
-		if errors.As(err, &jErr) {
-			return nil, jErr
-		} else {
-			return nil, jsonapi.NewError(http.StatusInternalServerError, "failed to create project", err)
-		}
+// NewSlotHandler serves the slots of the project of the url, slots of other projects are not found.
+// The project of the slots is includable with project.
+func NewSlotHandler(service SlotService, project *server.Include) *SlotHandler {
+	return &SlotHandler{
+		CrudHandler: &server.CrudHandler[*Slot, *SlotFilter]{
+			Service:  service,
+			Path:     ":projectID/slot",
+			IDParam:  ":slotID",
+			Tables:   []string{"slot", "project", "client"},
+			Includes: []*server.Include{project},
+			NewItem: func() *Slot {
+				return &Slot{}
+			},
+			NewFilter: func(req *http.Request) *SlotFilter {
+				filter := &SlotFilter{}
+				if projectID := request.QueryInt(req, ":projectID", 0); projectID != 0 {
+					filter.ProjectID = &projectID
+				}
+				return filter
+			},
+			New: func(req *http.Request) *Slot {
+				return &Slot{ProjectID: request.QueryInt(req, ":projectID", 0), Start: time.Now().UTC()}
+			},
+			Link: func(req *http.Request) string {
+				return fmt.Sprintf("/project/%d/slot", request.QueryInt(req, ":projectID", 0))
+			},
+			BindParent: func(req *http.Request, slot *Slot) error {
+				projectID := request.QueryInt(req, ":projectID", 0)
+				if slot.ProjectID != projectID {
+					if slot.ProjectID != 0 {
+						return server.IDMismatch("/data/attributes/projectId")
+					}
+					slot.ProjectID = projectID
+				}
+				return nil
+			},
+			AfterLoad: func(req *http.Request, slot *Slot) error {
+				if slot.ProjectID != request.QueryInt(req, ":projectID", 0) {
+					return server.NotFound("project.slot", slot.ID)
+				}
+				return nil
+			},
+			BeforeSave: func(_ *http.Request, _ db.Transaction, slot *Slot) error {
+				if slot.Start.IsZero() {
+					service.Start(slot)
+				}
+				return nil
+			},
+		},
 	}
-	return data, nil
 }
 
-func (h *Handler) Update(req *http.Request) (data *jsonapi.DocumentData[*Project], jErr *jsonapi.Error) {
-	con := request.DB(req)
-	if err := con.DoTransaction(func(tx db.Transaction) error {
-		projectID := request.QueryInt(req, ":projectID", 0)
-		item, err := h.Service.GetByID(tx, projectID)
-		if err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to get project", err)
-		}
-		if item == nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "project with id: "+strconv.Itoa(int(projectID))+" not found", err)
-		}
+func (h *SlotHandler) RegisterRoutes(route router.RouteElement) {
+	h.CrudHandler.RegisterRoutes(route)
+	route.GET(":projectID/slot/csv", h.DownloadCSV)
+	var filter *SlotFilter
+	server.APIDoc.Describe(route, http.MethodGet, ":projectID/slot/csv", &server.Operation{
+		Summary:  "Download slots as CSV",
+		Response: &server.Content{MediaType: "text/csv", Schema: server.Schema{"type": "string"}},
+		Query:    filter,
+	})
+}
 
-		if err := httpx.ShouldBindWith(req, item, jsonapi.Binding); err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to bind body", err)
-		}
-		if item.ID != projectID {
-			if item.ID != 0 {
-				return jsonapi.NewError(http.StatusBadRequest, "projectID of url does not match with request body", nil)
-			}
-			item.ID = projectID
-		}
+func (h *SlotHandler) DownloadCSV(writer http.ResponseWriter, req *http.Request) {
+	data, err := h.GetAll(req)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		_, _ = writer.Write([]byte(err.Error()))
+	}
+	if data == nil || data.Items == nil || len(data.Items) == 0 {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writer.Header().Set("Content-Type", "text/csv")
+	writer.WriteHeader(http.StatusOK)
+	var slots []*Slot
+	for _, item := range data.Items {
+		slots = append(slots, item.Data)
+	}
+	_ = WriteAsCSV(writer, slots)
+}
 
-		if err := h.Service.Save(tx, item); err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to save slot", err)
-		}
+type ActivityHandler struct {
+	jsonapi.GenericHandler[*server.KeyValueResourceObject[any]]
+}
 
-		data = jsonapi.NewDocumentData[*Project](item, "/project")
-		return nil
-	}); err != nil {
-		if errors.As(err, &jErr) {
-			return nil, jErr
-		} else {
-			return nil, jsonapi.NewError(http.StatusInternalServerError, "failed to create slot", err)
-		}
-	}
-	return data, nil
+func (h *ActivityHandler) RegisterRoutes(route router.RouteElement) {
+	h.DocumentUpdaters = append(h.DocumentUpdaters, server.SelfLinkUpdaterInstance)
+	route.GET("activity", server.Handle(&h.GenericHandler, h.GetActivities))
+	route.GET("activity/:activityID", server.Handle(&h.GenericHandler, h.GetActivity))
+	server.APIDoc.Describe(route, http.MethodGet, "activity", &server.Operation{
+		Summary:  "List activities",
+		Response: server.CollectionDocument(activities[0]),
+	})
+	server.APIDoc.Describe(route, http.MethodGet, "activity/:activityID", &server.Operation{
+		Summary:  "Get activity",
+		Response: server.Document(activities[0]),
+	})
 }
 
-func (h *Handler) GetAll(req *http.Request) (data *jsonapi.DocumentData[*Project], jErr *jsonapi.Error) {
-	con := request.DB(req)
-	if err := con.DoTransaction(func(tx db.Transaction) error {
-		filter := &Filter{}
-		page := jsonapi.ExtractPagination(req)
+var activities = []*server.KeyValueResourceObject[any]{
+	{ID: "work", Type: "activity", Value: ActivityWork},
+	{ID: "break", Type: "activity", Value: ActivityBreak},
+}
 
-		if err := httpx.BindQuery(req, filter); err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to bind query", err)
-		}
-		items, err := h.Service.GetAll(tx, page, filter)
-		if err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to get items", err)
-		}
-		data = jsonapi.NewDocumentData[*Project](items, "/project")
-		data.Page = page
-		return nil
-	}); err != nil {
-		if errors.As(err, &jErr) {
-			return nil, jErr
-		} else {
-			return nil, jsonapi.NewError(http.StatusInternalServerError, "failed to create project", err)
-		}
-	}
-	return data, nil
+func (h *ActivityHandler) GetActivities(_ *http.Request) (*jsonapi.DocumentData[*server.KeyValueResourceObject[any]], error) {
+	return jsonapi.NewDocumentData[*server.KeyValueResourceObject[any]](activities, "project/activity"), nil
 }
 
-func (h *Handler) Get(req *http.Request) (data *jsonapi.DocumentData[*Project], jErr *jsonapi.Error) {
-	con := request.DB(req)
-	if err := con.DoTransaction(func(tx db.Transaction) error {
-		id := request.QueryInt(req, ":projectID", 0)
-		item, err := h.Service.GetByID(tx, id)
-		if err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to get items", err)
-		}
-		if item == nil {
-			return jsonapi.NewError(http.StatusBadRequest, "item not found", nil)
-		}
-		data = jsonapi.NewDocumentData[*Project](item, "/project")
-		return nil
-	}); err != nil {
-		if errors.As(err, &jErr) {
-			return nil, jErr
-		} else {
-			return nil, jsonapi.NewError(http.StatusInternalServerError, "failed to create project", err)
-		}
+func (h *ActivityHandler) GetActivity(req *http.Request) (*jsonapi.DocumentData[*server.KeyValueResourceObject[any]], error) {
+	id := request.Query(req, ":activityID")
+	switch id {
+	case "work":
+		return jsonapi.NewDocumentData[*server.KeyValueResourceObject[any]](activities[0], "project/activity"), nil
+	case "break":
+		return jsonapi.NewDocumentData[*server.KeyValueResourceObject[any]](activities[1], "project/activity"), nil
+	default:
+		return nil, server.NewError(http.StatusNotFound, "not_found", "activity "+id+" not found").
+			WithMeta("type", "activity").
+			WithMeta("id", id)
 	}
-	return data, nil
 }
 
-func (h *Handler) New(_ *http.Request) (data *jsonapi.DocumentData[*Project], jErr *jsonapi.Error) {
-	data = jsonapi.NewDocumentData[*Project](&Project{Name: "New Project"}, "/project")
-	return data, nil
+// NewTimesheetHandler serves the timesheets of the weeks in zone. Anyone may create and submit a timesheet,
+// approving or rejecting it needs the admin token.
+func NewTimesheetHandler(service TimesheetService, zone *time.Location, adminToken string) *server.CrudHandler[*Timesheet, *TimesheetFilter] {
+	return &server.CrudHandler[*Timesheet, *TimesheetFilter]{
+		Service: service,
+		Path:    "timesheet",
+		IDParam: ":timesheetID",
+		Tables:  []string{"timesheet", "slot"},
+		NewItem: func() *Timesheet {
+			return &Timesheet{}
+		},
+		NewFilter: func(_ *http.Request) *TimesheetFilter {
+			return &TimesheetFilter{}
+		},
+		New: func(_ *http.Request) *Timesheet {
+			return &Timesheet{Week: WeekOf(time.Now(), zone), State: TimesheetOpen}
+		},
+		Link: func(_ *http.Request) string {
+			return "/project/timesheet"
+		},
+		BeforeSave: func(req *http.Request, tx db.Transaction, item *Timesheet) error {
+			return authorizeDecision(req, tx, service, adminToken, item)
+		},
+	}
 }
 
-func (h *Handler) Delete(req *http.Request) (data *jsonapi.DocumentData[*Project], jErr *jsonapi.Error) {
-	con := request.DB(req)
-	if err := con.DoTransaction(func(tx db.Transaction) error {
-		id := request.QueryInt(req, ":projectID", 0)
-		err := h.Service.Delete(tx, id)
-		if err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to get items", err)
-		}
+// authorizeDecision needs the admin token to approve or reject a timesheet and to change its comment
+// without a transition, e.g. the reason of a rejection.
+func authorizeDecision(req *http.Request, tx db.Transaction, service TimesheetService, adminToken string, timesheet *Timesheet) error {
+	if server.IsAdmin(req, adminToken) {
 		return nil
-	}); err != nil {
-		if errors.As(err, &jErr) {
-			return nil, jErr
-		} else {
-			return nil, jsonapi.NewError(http.StatusInternalServerError, "failed to create project", err)
+	}
+	decision := timesheet.State == TimesheetApproved || timesheet.State == TimesheetRejected
+	var current *Timesheet
+	if timesheet.ID != 0 {
+		var err error
+		if current, err = service.GetByID(tx, timesheet.ID); err != nil {
+			return err
 		}
 	}
-	return data, nil
+	switch {
+	case current == nil || current.State != timesheet.State:
+		if decision {
+			return server.AdminRequired()
+		}
+	case current.commentText() != timesheet.commentText():
+		return server.AdminRequired()
+	}
+	return nil
 }
 
-type SlotHandler struct {
-	jsonapi.GenericHandler[*Slot]
-	Service SlotService
+// PeriodLockHandler serves the period locks, changing them needs the admin token. GET periodLockState
+// returns the last locked day of all clients and of each client with own locks, e.g. to disable editing.
+type PeriodLockHandler struct {
+	*server.CrudHandler[*PeriodLock, *PeriodLockFilter]
+	States  jsonapi.GenericHandler[*PeriodLockState]
+	Service PeriodLockService
 }
 
-func (h *SlotHandler) RegisterRoutes(route router.RouteElement) {
-	h.DocumentUpdaters = append(h.DocumentUpdaters, server.SelfLinkUpdaterInstance)
-	route.POST(":projectID/slot", h.Handle(h.Create))
-	route.PATCH(":projectID/slot/:slotID", h.Handle(h.Update))
-	route.GET(":projectID/slot", h.Handle(h.GetAll))
-	route.GET(":projectID/slot/new", h.Handle(h.New))
-	route.GET(":projectID/slot/:slotID", h.Handle(h.Get))
-	route.DELETE(":projectID/slot/:slotID", h.Handle(h.Delete))
+func NewPeriodLockHandler(service PeriodLockService, adminToken string) *PeriodLockHandler {
+	return &PeriodLockHandler{
+		CrudHandler: &server.CrudHandler[*PeriodLock, *PeriodLockFilter]{
+			Service: service,
+			Path:    "periodLock",
+			IDParam: ":lockID",
+			Tables:  []string{"period_lock"},
+			NewItem: func() *PeriodLock {
+				return &PeriodLock{}
+			},
+			NewFilter: func(_ *http.Request) *PeriodLockFilter {
+				return &PeriodLockFilter{}
+			},
+			Link: func(_ *http.Request) string {
+				return "/project/periodLock"
+			},
+			Authorize: server.RequireAdmin(adminToken),
+		},
+		Service: service,
+	}
+}
 
-	route.GET(":projectID/slot/csv", h.DownloadCSV)
+func (h *PeriodLockHandler) RegisterRoutes(route router.RouteElement) {
+	h.CrudHandler.RegisterRoutes(route)
+	h.States.DocumentUpdaters = append(h.States.DocumentUpdaters, server.SelfLinkUpdaterInstance)
+	route.GET("periodLockState", server.ETag(server.Handle(&h.States, h.GetStates)))
+	server.APIDoc.Describe(route, http.MethodGet, "periodLockState", &server.Operation{
+		Summary:  "Last locked day of all clients and of the clients with own locks",
+		Response: server.CollectionDocument(&PeriodLockState{}),
+	})
 }
 
-func (h *SlotHandler) Create(req *http.Request) (data *jsonapi.DocumentData[*Slot], jErr *jsonapi.Error) {
-	con := request.DB(req)
-	if err := con.DoTransaction(func(tx db.Transaction) error {
-		slot := &Slot{}
-		if err := httpx.ShouldBindWith(req, slot, jsonapi.Binding); err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to bind body", err)
+func (h *PeriodLockHandler) GetStates(req *http.Request) (data *jsonapi.DocumentData[*PeriodLockState], err error) {
+	if err := request.DB(req).DoTransaction(func(tx db.Transaction) error {
+		if notModified, err := server.CollectionETag(req, tx, "period_lock"); err != nil || notModified {
+			data = jsonapi.NewDocumentData[*PeriodLockState]([]*PeriodLockState{}, "/project/periodLockState")
+			return err
 		}
-		projectID := request.QueryInt(req, ":projectID", 0)
-		if slot.ProjectID != projectID {
-			if slot.ProjectID != 0 {
-				return jsonapi.NewError(http.StatusBadRequest, "projectID of url does not match with request body", nil)
-			}
-			slot.ProjectID = projectID
-		}
-		if slot.Start.IsZero() {
-			h.Service.Start(slot)
-		}
-		if err := h.Service.Save(tx, slot); err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to save slot", err)
+		states, err := h.Service.State(tx)
+		if err != nil {
+			return err
 		}
-		data = jsonapi.NewDocumentData[*Slot](slot, fmt.Sprintf("/project/%d/slot", projectID))
+		data = jsonapi.NewDocumentData[*PeriodLockState](states, "/project/periodLockState")
 		return nil
 	}); err != nil {
-		if errors.As(err, &jErr) {
-			return nil, jErr
-		} else {
-			return nil, jsonapi.NewError(http.StatusInternalServerError, "failed to create slot", err)
-		}
+		return nil, err
 	}
 	return data, nil
 }
 
-func (h *SlotHandler) Update(req *http.Request) (data *jsonapi.DocumentData[*Slot], jErr *jsonapi.Error) {
-	con := request.DB(req)
-	if err := con.DoTransaction(func(tx db.Transaction) error {
-		slotID := request.QueryInt(req, ":slotID", 0)
-		slot, err := h.Service.GetByID(tx, slotID)
-		if err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to get slot", err)
-		}
-		if slot == nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "slot with id: "+strconv.Itoa(slotID)+" not found", err)
-		}
-		projectID := request.QueryInt(req, ":projectID", 0)
-		if err := httpx.ShouldBindWith(req, slot, jsonapi.Binding); err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to bind body", err)
-		}
-		if slot.ProjectID != projectID {
-			if slot.ProjectID != 0 {
-				return jsonapi.NewError(http.StatusBadRequest, "projectID of url does not match with request body", nil)
-			}
-			slot.ProjectID = projectID
-		}
-		if slot.ID != slotID {
-			if slot.ID != 0 {
-				return jsonapi.NewError(http.StatusBadRequest, "slotID of url does not match with request body", nil)
-			}
-			slot.ID = slotID
-		}
-		if err := h.Service.Save(tx, slot); err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to save slot", err)
-		}
-		data = jsonapi.NewDocumentData[*Slot](slot, fmt.Sprintf("/project/%d/slot", projectID))
-		return nil
-	}); err != nil {
-		if errors.As(err, &jErr) {
-			return nil, jErr
-		} else {
-			return nil, jsonapi.NewError(http.StatusInternalServerError, "failed to create slot", err)
-		}
-	}
-	return data, nil
+// SlotImportHandler turns uploaded calendar files into slot suggestions and saves confirmed suggestions as slots.
+type SlotImportHandler struct {
+	jsonapi.GenericHandler[*SlotSuggestion]
+	Slots   jsonapi.GenericHandler[*Slot]
+	Service SlotImportService
+	// Zone is the time zone of dates, floating times and unknown time zones of calendars and of the filter.
+	Zone *time.Location
 }
 
-func (h *SlotHandler) GetAll(req *http.Request) (data *jsonapi.DocumentData[*Slot], jErr *jsonapi.Error) {
+func (h *SlotImportHandler) RegisterRoutes(route router.RouteElement) {
+	h.DocumentUpdaters = append(h.DocumentUpdaters, server.SelfLinkUpdaterInstance)
+	h.Slots.DocumentUpdaters = append(h.Slots.DocumentUpdaters, server.SelfLinkUpdaterInstance)
+	route.POST("slotImport", server.Handle(&h.GenericHandler, h.Suggest))
+	server.AcceptUpload(route, http.MethodPost, "slotImport")
+	route.POST("slotImport/confirm", server.Handle(&h.Slots, h.Confirm))
+	server.APIDoc.Describe(route, http.MethodPost, "slotImport", &server.Operation{
+		Summary:  "Suggest slots of an iCalendar file",
+		Request:  &server.Content{MediaType: "text/calendar", Schema: server.Schema{"type": "string"}},
+		Response: server.CollectionDocument(&SlotSuggestion{}),
+		Query: &struct {
+			From  string `form:"filter[from]" time_format:"2006-01-02"`
+			Until string `form:"filter[until]" time_format:"2006-01-02"`
+		}{},
+	})
+	server.APIDoc.Describe(route, http.MethodPost, "slotImport/confirm", &server.Operation{
+		Summary:  "Save confirmed slot suggestions as slots",
+		Request:  server.CollectionDocument(&SlotSuggestion{}),
+		Response: server.CollectionDocument(&Slot{}),
+	})
+}
+
+// Suggest accepts an iCalendar file, either as multipart form field "file" or as request body.
+// The optional query parameters filter[from] and filter[until] (YYYY-MM-DD) limit the imported events.
+func (h *SlotImportHandler) Suggest(req *http.Request) (data *jsonapi.DocumentData[*SlotSuggestion], err error) {
+	events, err := readCalendar(req, h.Zone)
+	if err != nil {
+		return nil, server.NewError(http.StatusBadRequest, "invalid_calendar", "failed to read calendar").Wrap(err)
+	}
+	events, err = filterEvents(events, request.Query(req, "filter[from]"), request.Query(req, "filter[until]"), h.Zone)
+	if err != nil {
+		return nil, server.NewError(http.StatusBadRequest, "invalid_parameter", "invalid date filter").WithParameter("filter").Wrap(err)
+	}
 	con := request.DB(req)
 	if err := con.DoTransaction(func(tx db.Transaction) error {
-		filter := &SlotFilter{}
-		projectID := request.QueryInt(req, ":projectID", 0)
-		if projectID != 0 {
-			filter.ProjectID = &projectID
-		}
-		page := jsonapi.ExtractPagination(req)
-
-		if err := httpx.BindQuery(req, filter); err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to bind query", err)
-		}
-		slots, err := h.Service.GetAll(tx, page, filter)
+		suggestions, err := h.Service.Suggest(tx, events)
 		if err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to get items", err)
+			return err
 		}
-		data = jsonapi.NewDocumentData[*Slot](slots, fmt.Sprintf("/project/%d/slot", projectID))
-		data.Page = page
+		data = jsonapi.NewDocumentData[*SlotSuggestion](suggestions, "/project/slotImport")
 		return nil
 	}); err != nil {
-		if errors.As(err, &jErr) {
-			return nil, jErr
-		} else {
-			return nil, jsonapi.NewError(http.StatusInternalServerError, "failed to create slot", err)
-		}
+		return nil, err
 	}
 	return data, nil
 }
 
-func (h *SlotHandler) Get(req *http.Request) (data *jsonapi.DocumentData[*Slot], jErr *jsonapi.Error) {
+// Confirm saves all suggestions of the request body in one transaction. If one suggestion is rejected, none is saved.
+func (h *SlotImportHandler) Confirm(req *http.Request) (data *jsonapi.DocumentData[*Slot], err error) {
+	suggestions, err := readSuggestions(req)
+	if err != nil {
+		return nil, server.InvalidBody(err)
+	}
 	con := request.DB(req)
 	if err := con.DoTransaction(func(tx db.Transaction) error {
-		projectID := request.QueryInt(req, ":projectID", 0)
-		if projectID == 0 {
-			return jsonapi.NewError(http.StatusBadRequest, "invalid projectID", nil)
-		}
-		id := request.QueryInt(req, ":slotID", 0)
-		item, err := h.Service.GetByID(tx, id)
+		slots, err := h.Service.Confirm(tx, suggestions)
 		if err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to get items", err)
-		}
-		if item == nil {
-			return jsonapi.NewError(http.StatusNotFound, "item not found", nil)
+			return err
 		}
-		data = jsonapi.NewDocumentData[*Slot](item, fmt.Sprintf("/project/%d/slot", projectID))
+		data = jsonapi.NewDocumentData[*Slot](slots, "/project/slot")
 		for _, item := range data.Items {
 			if item.Links == nil {
 				item.Links = make(map[string]any)
@@ -348,92 +431,72 @@ func (h *SlotHandler) Get(req *http.Request) (data *jsonapi.DocumentData[*Slot],
 		}
 		return nil
 	}); err != nil {
-		if errors.As(err, &jErr) {
-			return nil, jErr
-		} else {
-			return nil, jsonapi.NewError(http.StatusInternalServerError, "failed to create slot", err)
-		}
-	}
-	return data, nil
-}
-
-func (h *SlotHandler) New(req *http.Request) (data *jsonapi.DocumentData[*Slot], jErr *jsonapi.Error) {
-	projectID := request.QueryInt(req, ":projectID", 0)
-	data = jsonapi.NewDocumentData[*Slot](&Slot{ProjectID: projectID, Start: time.Now().UTC()}, fmt.Sprintf("/project/%d/slot", projectID))
-	for _, item := range data.Items {
-		if item.Links == nil {
-			item.Links = make(map[string]any)
-		}
-		item.Links["self"] = fmt.Sprintf("project/%d/slot", item.Data.ProjectID)
+		return nil, err
 	}
 	return data, nil
 }
 
-func (h *SlotHandler) Delete(req *http.Request) (data *jsonapi.DocumentData[*Slot], jErr *jsonapi.Error) {
-	con := request.DB(req)
-	if err := con.DoTransaction(func(tx db.Transaction) error {
-		id := request.QueryInt(req, ":slotID", 0)
-		err := h.Service.Delete(tx, id)
+func readCalendar(req *http.Request, zone *time.Location) ([]*CalendarEvent, error) {
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := req.FormFile("file")
 		if err != nil {
-			return jsonapi.NewError(http.StatusInternalServerError, "failed to get items", err)
-		}
-		return nil
-	}); err != nil {
-		if errors.As(err, &jErr) {
-			return nil, jErr
-		} else {
-			return nil, jsonapi.NewError(http.StatusInternalServerError, "failed to create slot", err)
+			return nil, err
 		}
+		defer func() { _ = file.Close() }()
+		return ParseICS(file, zone)
 	}
-	return data, nil
+	return ParseICS(req.Body, zone)
 }
 
-func (h *SlotHandler) DownloadCSV(writer http.ResponseWriter, req *http.Request) {
-	data, err := h.GetAll(req)
-	if err != nil {
-		writer.WriteHeader(http.StatusInternalServerError)
-		_, _ = writer.Write([]byte(err.Error()))
+func filterEvents(events []*CalendarEvent, from, until string, zone *time.Location) ([]*CalendarEvent, error) {
+	var fromDate, untilDate time.Time
+	var err error
+	if from != "" {
+		if fromDate, err = time.ParseInLocation(time.DateOnly, from, zone); err != nil {
+			return nil, err
+		}
 	}
-	if data == nil || data.Items == nil || len(data.Items) == 0 {
-		writer.WriteHeader(http.StatusNotFound)
-		return
+	if until != "" {
+		if untilDate, err = time.ParseInLocation(time.DateOnly, until, zone); err != nil {
+			return nil, err
+		}
+		untilDate = untilDate.AddDate(0, 0, 1)
 	}
-	writer.Header().Set("Content-Type", "text/csv")
-	writer.WriteHeader(http.StatusOK)
-	var slots []*Slot
-	for _, item := range data.Items {
-		slots = append(slots, item.Data)
+	filtered := make([]*CalendarEvent, 0, len(events))
+	for _, event := range events {
+		if !fromDate.IsZero() && event.Start.Before(fromDate) {
+			continue
+		}
+		if !untilDate.IsZero() && !event.Start.Before(untilDate) {
+			continue
+		}
+		filtered = append(filtered, event)
 	}
-	_ = WriteAsCSV(writer, slots)
-}
-
-type ActivityHandler struct {
-	jsonapi.GenericHandler[*server.KeyValueResourceObject[any]]
-}
-
-func (h *ActivityHandler) RegisterRoutes(route router.RouteElement) {
-	h.DocumentUpdaters = append(h.DocumentUpdaters, server.SelfLinkUpdaterInstance)
-	route.GET("activity", h.Handle(h.GetActivities))
-	route.GET("activity/:activityID", h.Handle(h.GetActivity))
-}
-
-var activities = []*server.KeyValueResourceObject[any]{
-	{ID: "work", Type: "activity", Value: ActivityWork},
-	{ID: "break", Type: "activity", Value: ActivityBreak},
-}
-
-func (h *ActivityHandler) GetActivities(_ *http.Request) (*jsonapi.DocumentData[*server.KeyValueResourceObject[any]], *jsonapi.Error) {
-	return jsonapi.NewDocumentData[*server.KeyValueResourceObject[any]](activities, "project/activity"), nil
+	return filtered, nil
 }
 
-func (h *ActivityHandler) GetActivity(req *http.Request) (*jsonapi.DocumentData[*server.KeyValueResourceObject[any]], *jsonapi.Error) {
-	id := request.Query(req, ":activityID")
-	switch id {
-	case "work":
-		return jsonapi.NewDocumentData[*server.KeyValueResourceObject[any]](activities[0], "project/activity"), nil
-	case "break":
-		return jsonapi.NewDocumentData[*server.KeyValueResourceObject[any]](activities[1], "project/activity"), nil
-	default:
-		return nil, jsonapi.NewError(http.StatusBadRequest, "invalid activity ID", nil)
+func readSuggestions(req *http.Request) ([]*SlotSuggestion, error) {
+	doc := struct {
+		Data []struct {
+			Type       string          `json:"type"`
+			ID         string          `json:"id"`
+			Attributes json.RawMessage `json:"attributes"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(req.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	suggestions := make([]*SlotSuggestion, 0, len(doc.Data))
+	for i, obj := range doc.Data {
+		if obj.Type != "project.slotSuggestion" {
+			return nil, fmt.Errorf("data[%d]: unexpected type %q", i, obj.Type)
+		}
+		suggestion := &SlotSuggestion{}
+		if err := json.Unmarshal(obj.Attributes, suggestion); err != nil {
+			return nil, fmt.Errorf("data[%d]: %w", i, err)
+		}
+		suggestion.UID = obj.ID
+		suggestions = append(suggestions, suggestion)
 	}
+	return suggestions, nil
 }
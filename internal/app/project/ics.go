@@ -0,0 +1,194 @@
+package project
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCalendar = errors.New("invalid calendar")
+
+type CalendarEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Organizer   string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+}
+
+// ParseICS reads all VEVENT components of an iCalendar (RFC 5545) stream. Dates, floating times and
+// times of unknown TZIDs are in zone. Recurrence rules are not expanded, only the first occurrence of
+// an event is returned.
+func ParseICS(reader io.Reader, zone *time.Location) ([]*CalendarEvent, error) {
+	lines, err := unfoldICSLines(reader)
+	if err != nil {
+		return nil, err
+	}
+	var events []*CalendarEvent
+	var current *CalendarEvent
+	var duration time.Duration
+	inCalendar := false
+	for _, line := range lines {
+		name, params, value, ok := splitICSLine(line)
+		if !ok {
+			continue
+		}
+		switch name {
+		case "BEGIN":
+			switch strings.ToUpper(value) {
+			case "VCALENDAR":
+				inCalendar = true
+			case "VEVENT":
+				if !inCalendar {
+					return nil, ErrInvalidCalendar
+				}
+				current = &CalendarEvent{}
+				duration = 0
+			}
+			continue
+		case "END":
+			if strings.ToUpper(value) == "VEVENT" && current != nil {
+				if current.End.IsZero() {
+					current.End = current.Start.Add(duration)
+				}
+				if !current.Start.IsZero() {
+					events = append(events, current)
+				}
+				current = nil
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		switch name {
+		case "UID":
+			current.UID = value
+		case "SUMMARY":
+			current.Summary = unescapeICSText(value)
+		case "DESCRIPTION":
+			current.Description = unescapeICSText(value)
+		case "ORGANIZER":
+			current.Organizer = strings.TrimPrefix(strings.TrimPrefix(value, "mailto:"), "MAILTO:")
+		case "DTSTART":
+			current.Start, current.AllDay, err = parseICSTime(value, params, zone)
+			if err != nil {
+				return nil, err
+			}
+		case "DTEND":
+			current.End, _, err = parseICSTime(value, params, zone)
+			if err != nil {
+				return nil, err
+			}
+		case "DURATION":
+			duration, err = parseICSDuration(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !inCalendar {
+		return nil, ErrInvalidCalendar
+	}
+	return events, nil
+}
+
+// unfoldICSLines joins continuation lines (starting with a space or tab) with their predecessor.
+func unfoldICSLines(reader io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func splitICSLine(line string) (string, map[string]string, string, bool) {
+	sep := strings.Index(line, ":")
+	if sep < 0 {
+		return "", nil, "", false
+	}
+	head, value := line[:sep], line[sep+1:]
+	parts := strings.Split(head, ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, param := range parts[1:] {
+		k, v, found := strings.Cut(param, "=")
+		if found {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value, true
+}
+
+func parseICSTime(value string, params map[string]string, zone *time.Location) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, zone)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	loc := zone
+	if tzID, ok := params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzID); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// parseICSDuration supports the subset of RFC 5545 durations used for events, e.g. PT1H30M or P1D.
+func parseICSDuration(value string) (time.Duration, error) {
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimLeft(value, "+-")
+	if !strings.HasPrefix(value, "P") {
+		return 0, ErrInvalidCalendar
+	}
+	var d time.Duration
+	var number int
+	inTime := false
+	for _, r := range value[1:] {
+		switch {
+		case r >= '0' && r <= '9':
+			number = number*10 + int(r-'0')
+			continue
+		case r == 'T':
+			inTime = true
+		case r == 'W':
+			d += time.Duration(number) * 7 * 24 * time.Hour
+		case r == 'D':
+			d += time.Duration(number) * 24 * time.Hour
+		case r == 'H' && inTime:
+			d += time.Duration(number) * time.Hour
+		case r == 'M' && inTime:
+			d += time.Duration(number) * time.Minute
+		case r == 'S' && inTime:
+			d += time.Duration(number) * time.Second
+		default:
+			return 0, ErrInvalidCalendar
+		}
+		number = 0
+	}
+	if negative {
+		d = -d
+	}
+	return d, nil
+}
+
+func unescapeICSText(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
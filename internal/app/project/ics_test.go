@@ -0,0 +1,127 @@
+package project
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/go-libs/testhelper"
+)
+
+func TestParseICS(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	tests := []struct {
+		name    string
+		ics     string
+		want    []*CalendarEvent
+		wantErr bool
+	}{{
+		name: "GIVEN event with UTC times THEN parse event",
+		ics: `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:1@example.com
+SUMMARY:Daily Standup
+ORGANIZER;CN=Jane:mailto:jane@example.com
+DTSTART:20250115T090000Z
+DTEND:20250115T091500Z
+END:VEVENT
+END:VCALENDAR
+`,
+		want: []*CalendarEvent{{
+			UID:       "1@example.com",
+			Summary:   "Daily Standup",
+			Organizer: "jane@example.com",
+			Start:     time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC),
+			End:       time.Date(2025, 1, 15, 9, 15, 0, 0, time.UTC),
+		}},
+	}, {
+		name: "GIVEN event with TZID, duration and folded description THEN parse event",
+		ics: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:2\r\nDESCRIPTION:first\\, line\r\n  continued\r\n" +
+			"DTSTART;TZID=Europe/Berlin:20250115T100000\r\nDURATION:PT1H30M\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		want: []*CalendarEvent{{
+			UID:         "2",
+			Description: "first, line continued",
+			Start:       time.Date(2025, 1, 15, 10, 0, 0, 0, berlin),
+			End:         time.Date(2025, 1, 15, 11, 30, 0, 0, berlin),
+		}},
+	}, {
+		name: "GIVEN all day event THEN mark as all day",
+		ics: `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:3
+DTSTART;VALUE=DATE:20250115
+DTEND;VALUE=DATE:20250116
+END:VEVENT
+END:VCALENDAR
+`,
+		want: []*CalendarEvent{{
+			UID:    "3",
+			Start:  time.Date(2025, 1, 15, 0, 0, 0, 0, cet),
+			End:    time.Date(2025, 1, 16, 0, 0, 0, 0, cet),
+			AllDay: true,
+		}},
+	}, {
+		name: "GIVEN floating time and unknown TZID THEN use zone",
+		ics: `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:4
+DTSTART:20250115T100000
+DTEND;TZID=Mars/Olympus:20250115T110000
+END:VEVENT
+END:VCALENDAR
+`,
+		want: []*CalendarEvent{{
+			UID:   "4",
+			Start: time.Date(2025, 1, 15, 10, 0, 0, 0, cet),
+			End:   time.Date(2025, 1, 15, 11, 0, 0, 0, cet),
+		}},
+	}, {
+		name:    "GIVEN no calendar THEN throw error",
+		ics:     "BEGIN:VEVENT\nEND:VEVENT\n",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseICS(strings.NewReader(tt.ics), cet)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseICS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("ParseICS() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSlotImportRule_Matches(t *testing.T) {
+	event := &CalendarEvent{Summary: "ACME Weekly", Organizer: "boss@acme.com"}
+	tests := []struct {
+		name string
+		rule *SlotImportRule
+		want bool
+	}{{
+		name: "GIVEN keyword in summary THEN match case insensitive",
+		rule: &SlotImportRule{Keyword: testhelper.Ptr("acme")},
+		want: true,
+	}, {
+		name: "GIVEN organizer THEN match",
+		rule: &SlotImportRule{Organizer: testhelper.Ptr("BOSS@acme.com")},
+		want: true,
+	}, {
+		name: "GIVEN unrelated keyword THEN no match",
+		rule: &SlotImportRule{Keyword: testhelper.Ptr("globex")},
+		want: false,
+	}, {
+		name: "GIVEN empty rule THEN no match",
+		rule: &SlotImportRule{},
+		want: false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Matches(event); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/validate"
+)
+
+// PeriodLock closes the slots starting on or before Until in the time zone of the instance, e.g. after
+// invoicing or payroll. Without client it locks the slots of all clients. Locks are not deleted, a lock
+// is lifted by setting UnlockReason, which records the time of unlocking.
+type PeriodLock struct {
+	ID       int  `json:"id,omitempty"`
+	ClientID *int `json:"clientId,omitempty" db:"client_id"`
+	// Until is the last locked day, e.g. 2025-01-31.
+	Until        string     `json:"until" db:"locked_until" validate:"required"`
+	Reason       string     `json:"reason" validate:"required,max=1000"`
+	LockedAt     time.Time  `json:"lockedAt,omitempty" db:"locked_at"`
+	UnlockReason *string    `json:"unlockReason,omitempty" db:"unlock_reason" validate:"max=1000"`
+	UnlockedAt   *time.Time `json:"unlockedAt,omitempty" db:"unlocked_at"`
+	Version      int        `json:"version,omitempty"`
+}
+
+func (l *PeriodLock) SetIdentifier(id *jsonapi.ResourceIdentifierObject) {
+	if id == nil || id.Type != "project.periodLock" {
+		log.Error().Msgf("PeriodLock identifier object is invalid")
+		return
+	}
+	if len(id.ID) == 0 {
+		return
+	}
+	idInt, err := strconv.ParseInt(id.ID, 10, 64)
+	if err != nil {
+		log.Err(err).Msg("PeriodLock identifier is not a valid identifier")
+		return
+	}
+	l.ID = int(idInt)
+}
+
+func (l *PeriodLock) GetIdentifier() *jsonapi.ResourceIdentifierObject {
+	id := &jsonapi.ResourceIdentifierObject{
+		Type: "project.periodLock",
+	}
+	if l.ID != 0 {
+		id.ID = strconv.Itoa(l.ID)
+	}
+	return id
+}
+
+func (l *PeriodLock) GetVersion() int {
+	return l.Version
+}
+
+// Validate checks the date and requires a reason for unlocking.
+func (l *PeriodLock) Validate() error {
+	var errs validate.Errors
+	if _, err := time.Parse(time.DateOnly, l.Until); err != nil {
+		errs = append(errs, validate.Attribute("until", "date", "must be a date like 2025-01-31"))
+	}
+	if l.UnlockReason != nil && *l.UnlockReason == "" {
+		errs = append(errs, validate.Attribute("unlockReason", "required", "must not be empty if the period is unlocked"))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+type PeriodLockFilter struct {
+	ClientID *int  `form:"filter[clientId]" query:"client_id,eq"`
+	Active   *bool `form:"filter[active]" query:"unlocked_at,isnull"`
+}
+
+// PeriodLockState is the last locked day of all clients, identified by "global", or of a client. The
+// day of a client is the later one of its own locks and the global locks.
+type PeriodLockState struct {
+	ID          string `json:"-"`
+	ClientID    *int   `json:"clientId,omitempty"`
+	LockedUntil string `json:"lockedUntil"`
+}
+
+const globalPeriodLockState = "global"
+
+func (s *PeriodLockState) SetIdentifier(id *jsonapi.ResourceIdentifierObject) {
+	if id == nil || id.Type != "project.periodLockState" {
+		log.Error().Msgf("PeriodLockState identifier object is invalid")
+		return
+	}
+	s.ID = id.ID
+}
+
+func (s *PeriodLockState) GetIdentifier() *jsonapi.ResourceIdentifierObject {
+	return &jsonapi.ResourceIdentifierObject{Type: "project.periodLockState", ID: s.ID}
+}
+
+var (
+	ErrPeriodLocked           = fmt.Errorf("period of %w", ErrSlotLocked)
+	ErrPeriodLockChanged      = errors.New("period lock cannot be changed, only unlocked")
+	ErrPeriodLockNotDeletable = errors.New("period lock cannot be deleted, only unlocked")
+	ErrPeriodLockUnlocked     = errors.New("period lock is already unlocked")
+)
+
+type PeriodLockService interface {
+	Save(tx db.Transaction, lock *PeriodLock) error
+	GetAll(tx db.Transaction, page *pagination.Page, filter *PeriodLockFilter) ([]*PeriodLock, error)
+	GetByID(tx db.Transaction, id int) (*PeriodLock, error)
+	Delete(tx db.Transaction, id int) error
+	State(tx db.Transaction) ([]*PeriodLockState, error)
+	Restore(tx db.Transaction, lock *PeriodLock) error
+	SlotLock
+}
+
+// NewPeriodLockService reads the clients of the slots from projects, the days of the slots are based on zone.
+func NewPeriodLockService(repo db.CRUDRepository[*PeriodLock, *PeriodLockFilter], projects db.CRUDRepository[*Project, *Filter], zone *time.Location) PeriodLockService {
+	return &periodLockService{repo: repo, projects: projects, zone: zone, now: time.Now}
+}
+
+type periodLockService struct {
+	repo     db.CRUDRepository[*PeriodLock, *PeriodLockFilter]
+	projects db.CRUDRepository[*Project, *Filter]
+	zone     *time.Location
+	now      func() time.Time
+}
+
+// Save creates a lock or unlocks an existing one. The period of a lock cannot be changed, a wrong lock
+// is unlocked and replaced by a new one, so the history of the locks stays complete.
+func (s *periodLockService) Save(tx db.Transaction, lock *PeriodLock) error {
+	now := s.now().UTC().Truncate(time.Second)
+	if lock.ID == 0 {
+		lock.LockedAt = now
+		lock.UnlockReason, lock.UnlockedAt = nil, nil
+		return s.repo.Save(tx, lock)
+	}
+	current, err := s.repo.GetByID(tx, lock.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return db.ErrNotFound
+	}
+	if current.UnlockedAt != nil {
+		return ErrPeriodLockUnlocked
+	}
+	if !equalClient(lock.ClientID, current.ClientID) || lock.Until != current.Until || lock.Reason != current.Reason {
+		return ErrPeriodLockChanged
+	}
+	if lock.UnlockReason == nil {
+		return ErrPeriodLockChanged
+	}
+	lock.LockedAt = current.LockedAt
+	lock.UnlockedAt = &now
+	return s.repo.Save(tx, lock)
+}
+
+func equalClient(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (s *periodLockService) GetAll(tx db.Transaction, page *pagination.Page, filter *PeriodLockFilter) ([]*PeriodLock, error) {
+	return s.repo.GetAll(tx, page, filter)
+}
+
+func (s *periodLockService) GetByID(tx db.Transaction, id int) (*PeriodLock, error) {
+	return s.repo.GetByID(tx, id)
+}
+
+func (s *periodLockService) Delete(_ db.Transaction, _ int) error {
+	return ErrPeriodLockNotDeletable
+}
+
+// Restore creates an imported lock with the times it was locked and unlocked.
+func (s *periodLockService) Restore(tx db.Transaction, lock *PeriodLock) error {
+	return s.repo.Save(tx, lock)
+}
+
+// State returns the global state first, followed by the states of the clients with own locks ordered by client.
+func (s *periodLockService) State(tx db.Transaction) ([]*PeriodLockState, error) {
+	global, clients, err := s.lockedUntil(tx)
+	if err != nil {
+		return nil, err
+	}
+	states := []*PeriodLockState{{ID: globalPeriodLockState, LockedUntil: global}}
+	clientIDs := make([]int, 0, len(clients))
+	for clientID := range clients {
+		clientIDs = append(clientIDs, clientID)
+	}
+	sort.Ints(clientIDs)
+	for _, clientID := range clientIDs {
+		states = append(states, &PeriodLockState{
+			ID:          strconv.Itoa(clientID),
+			ClientID:    &clientID,
+			LockedUntil: max(global, clients[clientID]),
+		})
+	}
+	return states, nil
+}
+
+// CheckSlot rejects changes of slots starting on or before the last locked day of their client.
+func (s *periodLockService) CheckSlot(tx db.Transaction, slot *Slot) error {
+	global, clients, err := s.lockedUntil(tx)
+	if err != nil {
+		return err
+	}
+	day := slot.Start.In(s.zone).Format(time.DateOnly)
+	if day <= global {
+		return fmt.Errorf("%w until %s", ErrPeriodLocked, global)
+	}
+	if len(clients) == 0 {
+		return nil
+	}
+	project, err := s.projects.GetByID(tx, slot.ProjectID)
+	if err != nil || project == nil || project.Client == nil {
+		return err
+	}
+	if until, ok := clients[project.Client.ID]; ok && day <= until {
+		return fmt.Errorf("%w until %s of client %d", ErrPeriodLocked, until, project.Client.ID)
+	}
+	return nil
+}
+
+// lockedUntil returns the last locked day of the active global locks and of the active locks per client.
+// The days are ISO dates, so they compare as strings, an empty day locks nothing.
+func (s *periodLockService) lockedUntil(tx db.Transaction) (string, map[int]string, error) {
+	active := true
+	locks, err := s.repo.GetAll(tx, &pagination.Page{Limit: -1}, &PeriodLockFilter{Active: &active})
+	if err != nil {
+		return "", nil, err
+	}
+	var global string
+	clients := make(map[int]string)
+	for _, lock := range locks {
+		if lock.ClientID == nil {
+			global = max(global, lock.Until)
+			continue
+		}
+		clients[*lock.ClientID] = max(clients[*lock.ClientID], lock.Until)
+	}
+	return global, clients, nil
+}
+
+type PeriodLockRepository struct{}
+
+var periodLockQuery = &db.Query{
+	Table:   "period_lock",
+	Columns: []string{"id", "client_id", "locked_until", "reason", "locked_at", "unlock_reason", "unlocked_at", "version"},
+	SortFields: map[string]string{
+		"id":         "id",
+		"clientId":   "client_id",
+		"until":      "locked_until",
+		"lockedAt":   "locked_at",
+		"unlockedAt": "unlocked_at",
+	},
+}
+
+func NewPeriodLockRepository() db.CRUDRepository[*PeriodLock, *PeriodLockFilter] {
+	return &PeriodLockRepository{}
+}
+
+func (r *PeriodLockRepository) Save(tx db.Transaction, item *PeriodLock) error {
+	if item.ID == 0 {
+		stmt := `INSERT INTO period_lock (client_id, locked_until, reason, locked_at, unlock_reason, unlocked_at)
+				  VALUES (:clientId, :until, :reason, :lockedAt, :unlockReason, :unlockedAt)`
+
+		result, err := tx.Exec(stmt, item)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		item.ID = int(id)
+		item.Version = 1
+		return nil
+	}
+	stmt := `UPDATE period_lock
+			    SET
+			        unlock_reason = :unlockReason,
+			        unlocked_at   = :unlockedAt,
+			        version       = version + 1
+			  WHERE
+			        id = :id
+			    AND version = :version`
+
+	result, err := tx.Exec(stmt, item)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return db.UpdateError(tx, "period_lock", item.ID)
+	}
+	item.Version++
+	return nil
+}
+
+func (r *PeriodLockRepository) GetByID(tx db.Transaction, id int) (*PeriodLock, error) {
+	item := &PeriodLock{}
+	stmt := `SELECT id, client_id, locked_until, reason, locked_at, unlock_reason, unlocked_at, version
+			   FROM period_lock
+			  WHERE id = :id`
+
+	if err := tx.Select(item, stmt, map[string]any{"id": id}); err != nil {
+		return nil, err
+	}
+	if item.ID == 0 {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (r *PeriodLockRepository) GetAll(tx db.Transaction, page *pagination.Page, filter *PeriodLockFilter) ([]*PeriodLock, error) {
+	items := make([]*PeriodLock, 0, 10)
+	if err := periodLockQuery.GetAll(tx, &items, page, filter); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Delete is not supported, locks are unlocked instead.
+func (r *PeriodLockRepository) Delete(_ db.Transaction, _ int) error {
+	return ErrPeriodLockNotDeletable
+}
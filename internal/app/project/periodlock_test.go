@@ -0,0 +1,216 @@
+package project
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/go-libs/testhelper"
+	"github.com/vloryan/protrakgon/internal/app/client"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+type inMemPeriodLockRepository struct {
+	Locks []*PeriodLock
+}
+
+func (r *inMemPeriodLockRepository) Save(_ db.Transaction, item *PeriodLock) error {
+	if item.ID == 0 {
+		item.ID = len(r.Locks) + 1
+		r.Locks = append(r.Locks, item)
+		return nil
+	}
+	for i, lock := range r.Locks {
+		if lock.ID == item.ID {
+			r.Locks[i] = item
+		}
+	}
+	return nil
+}
+
+func (r *inMemPeriodLockRepository) GetByID(_ db.Transaction, id int) (*PeriodLock, error) {
+	for _, lock := range r.Locks {
+		if lock.ID == id {
+			copied := *lock
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *inMemPeriodLockRepository) GetAll(_ db.Transaction, _ *pagination.Page, filter *PeriodLockFilter) ([]*PeriodLock, error) {
+	var locks []*PeriodLock
+	for _, lock := range r.Locks {
+		if filter != nil && filter.Active != nil && (lock.UnlockedAt == nil) != *filter.Active {
+			continue
+		}
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
+
+func (r *inMemPeriodLockRepository) Delete(_ db.Transaction, _ int) error {
+	return ErrPeriodLockNotDeletable
+}
+
+// inMemProjectRepository knows the projects 1 of client 1 and 2 of client 2.
+type inMemProjectRepository struct{}
+
+func (r *inMemProjectRepository) Save(_ db.Transaction, _ *Project) error {
+	return nil
+}
+
+func (r *inMemProjectRepository) GetByID(_ db.Transaction, id int) (*Project, error) {
+	return &Project{ID: id, Client: &client.Client{ID: id}}, nil
+}
+
+func (r *inMemProjectRepository) GetAll(_ db.Transaction, _ *pagination.Page, _ *Filter) ([]*Project, error) {
+	return nil, nil
+}
+
+func (r *inMemProjectRepository) Delete(_ db.Transaction, _ int) error {
+	return nil
+}
+
+func buildPeriodLockScenario(locks []*PeriodLock) (*periodLockService, *inMemPeriodLockRepository) {
+	repo := &inMemPeriodLockRepository{Locks: locks}
+	return &periodLockService{
+		repo:     repo,
+		projects: &inMemProjectRepository{},
+		zone:     cet,
+		now: func() time.Time {
+			return testhelper.FixedNow
+		},
+	}, repo
+}
+
+func TestPeriodLockService_CheckSlot(t *testing.T) {
+	unlockedAt := testhelper.FixedNow
+	locks := []*PeriodLock{
+		{ID: 1, Until: "2024-12-31", Reason: "payroll"},
+		{ID: 2, ClientID: testhelper.Ptr(1), Until: "2025-01-10", Reason: "invoiced"},
+		{ID: 3, Until: "2025-01-31", Reason: "by mistake", UnlockReason: testhelper.Ptr("wrong month"), UnlockedAt: &unlockedAt},
+	}
+	tests := []struct {
+		name      string
+		projectID int
+		start     time.Time
+		wantErr   error
+	}{{
+		name:      "GIVEN slot in globally locked period THEN throw ErrPeriodLocked",
+		projectID: 2,
+		start:     time.Date(2024, time.December, 31, 12, 0, 0, 0, time.UTC),
+		wantErr:   ErrPeriodLocked,
+	}, {
+		name:      "GIVEN slot after global lock in the zone THEN accept",
+		projectID: 2,
+		start:     time.Date(2024, time.December, 31, 23, 30, 0, 0, time.UTC),
+	}, {
+		name:      "GIVEN slot in locked period of client THEN throw ErrPeriodLocked",
+		projectID: 1,
+		start:     time.Date(2025, time.January, 10, 8, 0, 0, 0, time.UTC),
+		wantErr:   ErrPeriodLocked,
+	}, {
+		name:      "GIVEN slot of other client THEN accept",
+		projectID: 2,
+		start:     time.Date(2025, time.January, 10, 8, 0, 0, 0, time.UTC),
+	}, {
+		name:      "GIVEN slot in unlocked period THEN accept",
+		projectID: 1,
+		start:     time.Date(2025, time.January, 20, 8, 0, 0, 0, time.UTC),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := buildPeriodLockScenario(locks)
+			err := s.CheckSlot(nil, &Slot{ProjectID: tt.projectID, Start: tt.start})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckSlot() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && !errors.Is(err, ErrSlotLocked) {
+				t.Errorf("CheckSlot() error = %v, want ErrSlotLocked", err)
+			}
+		})
+	}
+}
+
+func TestPeriodLockService_Save(t *testing.T) {
+	now := testhelper.FixedNow
+	lockedAt := now.Add(-24 * time.Hour)
+	active := func() []*PeriodLock {
+		return []*PeriodLock{{ID: 1, Until: "2024-12-31", Reason: "payroll", LockedAt: lockedAt}}
+	}
+	tests := []struct {
+		name    string
+		locks   []*PeriodLock
+		lock    *PeriodLock
+		want    *PeriodLock
+		wantErr error
+	}{{
+		name: "GIVEN new lock THEN record lock time",
+		lock: &PeriodLock{Until: "2024-12-31", Reason: "payroll", UnlockedAt: &now},
+		want: &PeriodLock{ID: 1, Until: "2024-12-31", Reason: "payroll", LockedAt: now},
+	}, {
+		name:  "GIVEN unlock reason THEN record unlock time",
+		locks: active(),
+		lock:  &PeriodLock{ID: 1, Until: "2024-12-31", Reason: "payroll", UnlockReason: testhelper.Ptr("correction")},
+		want: &PeriodLock{ID: 1, Until: "2024-12-31", Reason: "payroll", LockedAt: lockedAt,
+			UnlockReason: testhelper.Ptr("correction"), UnlockedAt: &now},
+	}, {
+		name:    "GIVEN changed period THEN throw ErrPeriodLockChanged",
+		locks:   active(),
+		lock:    &PeriodLock{ID: 1, Until: "2025-01-31", Reason: "payroll", UnlockReason: testhelper.Ptr("correction")},
+		wantErr: ErrPeriodLockChanged,
+	}, {
+		name:    "GIVEN no unlock reason THEN throw ErrPeriodLockChanged",
+		locks:   active(),
+		lock:    &PeriodLock{ID: 1, ClientID: testhelper.Ptr(1), Until: "2024-12-31", Reason: "payroll"},
+		wantErr: ErrPeriodLockChanged,
+	}, {
+		name: "GIVEN unlocked lock THEN throw ErrPeriodLockUnlocked",
+		locks: []*PeriodLock{{ID: 1, Until: "2024-12-31", Reason: "payroll", LockedAt: lockedAt,
+			UnlockReason: testhelper.Ptr("correction"), UnlockedAt: &lockedAt}},
+		lock:    &PeriodLock{ID: 1, Until: "2024-12-31", Reason: "payroll", UnlockReason: testhelper.Ptr("again")},
+		wantErr: ErrPeriodLockUnlocked,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := buildPeriodLockScenario(tt.locks)
+			err := s.Save(nil, tt.lock)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Save() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				got, _ := repo.GetByID(nil, tt.want.ID)
+				if diff := cmp.Diff(tt.want, got); diff != "" {
+					t.Errorf("Save() mismatch in repo (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestPeriodLockService_State(t *testing.T) {
+	s, _ := buildPeriodLockScenario([]*PeriodLock{
+		{ID: 1, Until: "2024-12-31", Reason: "payroll"},
+		{ID: 2, ClientID: testhelper.Ptr(2), Until: "2024-11-30", Reason: "invoiced"},
+		{ID: 3, ClientID: testhelper.Ptr(1), Until: "2025-01-10", Reason: "invoiced"},
+		{ID: 4, ClientID: testhelper.Ptr(1), Until: "2025-01-31", Reason: "by mistake",
+			UnlockReason: testhelper.Ptr("wrong month"), UnlockedAt: testhelper.Ptr(testhelper.FixedNow)},
+	})
+	want := []*PeriodLockState{
+		{ID: "global", LockedUntil: "2024-12-31"},
+		{ID: "1", ClientID: testhelper.Ptr(1), LockedUntil: "2025-01-10"},
+		{ID: "2", ClientID: testhelper.Ptr(2), LockedUntil: "2024-12-31"},
+	}
+
+	got, err := s.State(nil)
+
+	if err != nil {
+		t.Fatalf("State() error = %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("State() mismatch (-want +got):\n%s", diff)
+	}
+}
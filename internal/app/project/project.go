@@ -3,7 +3,6 @@ package project
 import (
 	"errors"
 	"strconv"
-	"strings"
 
 	"github.com/rs/zerolog/log"
 	"github.com/vloryan/go-libs/jsonapi"
@@ -14,9 +13,10 @@ import (
 
 type Project struct {
 	ID          int            `json:"id,omitempty"`
-	Name        string         `json:"name,omitempty"`
-	Client      *client.Client `json:"client,omitempty"`
-	Description *string        `json:"description,omitempty"`
+	Name        string         `json:"name,omitempty" validate:"required,max=100"`
+	Client      *client.Client `json:"client,omitempty" validate:"required"`
+	Description *string        `json:"description,omitempty" validate:"max=1000"`
+	Version     int            `json:"version,omitempty"`
 }
 
 func (p *Project) SetIdentifier(id *jsonapi.ResourceIdentifierObject) {
@@ -45,11 +45,16 @@ func (p *Project) GetIdentifier() *jsonapi.ResourceIdentifierObject {
 	return id
 }
 
+func (p *Project) GetVersion() int {
+	return p.Version
+}
+
 type Filter struct {
-	ID          *int    `form:"filter[id]"`
-	Name        string  `form:"filter[name]"`
-	ClientID    *int    `form:"filter[clientId]"`
-	Description *string `form:"filter[description]"`
+	ID          *int    `form:"filter[id]" query:"id,eq"`
+	IDs         []int   `form:"-" query:"id,in"`
+	Name        string  `form:"filter[name]" query:"name,like"`
+	ClientID    *int    `form:"filter[clientId]" query:"client_id,eq"`
+	Description *string `form:"filter[description]" query:"description,like"`
 }
 
 type Service interface {
@@ -85,6 +90,17 @@ func (d *service) Delete(tx db.Transaction, id int) error {
 
 type Repository struct{}
 
+var projectQuery = &db.Query{
+	Table:   "project",
+	Columns: []string{"id", "name", "client_id AS `client.id`", "description", "version"},
+	SortFields: map[string]string{
+		"id":          "id",
+		"name":        "name",
+		"clientId":    "client_id",
+		"description": "description",
+	},
+}
+
 func NewRepository() db.CRUDRepository[*Project, *Filter] {
 	return &Repository{}
 }
@@ -103,15 +119,18 @@ func (r *Repository) Save(tx db.Transaction, item *Project) error {
 			return err
 		}
 		item.ID = int(id)
+		item.Version = 1
 		return nil
 	} else {
 		stmt := `UPDATE project 
 				    SET
 						name 		= :name, 
 						client_id = :client.id,
-						description = :description
+						description = :description,
+						version     = version + 1
 				  WHERE 
-				        id = :id`
+				        id = :id
+				    AND version = :version`
 
 		result, err := tx.Exec(stmt, item)
 		if err != nil {
@@ -122,15 +141,16 @@ func (r *Repository) Save(tx db.Transaction, item *Project) error {
 			return err
 		}
 		if affected == 0 {
-			return errors.New("update failed: 0 rows affected")
+			return db.UpdateError(tx, "project", item.ID)
 		}
+		item.Version++
 	}
 	return nil
 }
 
 func (r *Repository) GetByID(tx db.Transaction, projectID int) (*Project, error) {
 	project := &Project{}
-	stmt := `SELECT id, name, client_id AS ` + "`client.id`" + `, description
+	stmt := `SELECT id, name, client_id AS ` + "`client.id`" + `, description, version
 			   FROM project 
 			  WHERE id = :id`
 
@@ -145,40 +165,7 @@ func (r *Repository) GetByID(tx db.Transaction, projectID int) (*Project, error)
 
 func (r *Repository) GetAll(tx db.Transaction, page *pagination.Page, filter *Filter) ([]*Project, error) {
 	items := make([]*Project, 0, 10)
-	stmt := `SELECT id, name, client_id AS ` + "`client.id`" + `, description 
-             FROM project`
-	countStmt := `SELECT COUNT(*) AS total_count 
-				    FROM project`
-
-	whereClause, whereParams := r.toWhereClause(filter)
-	selectParams := make(map[string]any)
-	for k, v := range whereParams {
-		selectParams[k] = v
-	}
-	if len(whereClause) > 0 {
-		stmt += "\n" + whereClause
-		countStmt += "\n" + whereClause
-	}
-
-	if page.Limit != -1 {
-		stmt += "\nLIMIT :limit"
-		selectParams["limit"] = page.Limit
-		if page.Offset != 0 {
-			stmt += "\nOFFSET :offset"
-			selectParams["offset"] = page.Offset * page.Limit
-		}
-	}
-	if len(whereClause) > 0 {
-		if err := tx.Select(page, countStmt, whereParams); err != nil {
-			return nil, err
-		}
-	} else {
-		if err := tx.Select(page, countStmt); err != nil {
-			return nil, err
-		}
-	}
-
-	if err := tx.Select(&items, stmt, selectParams); err != nil {
+	if err := projectQuery.GetAll(tx, &items, page, filter); err != nil {
 		return nil, err
 	}
 	return items, nil
@@ -197,34 +184,11 @@ func (r *Repository) Delete(tx db.Transaction, id int) error {
 	if err != nil {
 		return err
 	}
+	if affected == 0 {
+		return db.ErrNotFound
+	}
 	if affected != 1 {
 		return errors.New("delete failed: " + strconv.Itoa(int(affected)) + " rows affected")
 	}
 	return err
 }
-
-func (r *Repository) toWhereClause(filter *Filter) (string, map[string]any) {
-	clause := ""
-	parts := make([]string, 0, 10)
-	m := make(map[string]any)
-	if filter.ID != nil {
-		m["id"] = filter.ID
-		parts = append(parts, "id = :id")
-	}
-	if filter.Name != "" {
-		m["name"] = "%" + strings.ToLower(filter.Name) + "%"
-		parts = append(parts, "name LIKE :name")
-	}
-	if filter.ClientID != nil {
-		m["clientId"] = *filter.ClientID
-		parts = append(parts, "clientId = :clientId")
-	}
-	if filter.Description != nil {
-		m["description"] = "%" + strings.ToLower(*filter.Description) + "%"
-		parts = append(parts, "description LIKE :description")
-	}
-	if len(parts) > 0 {
-		clause = "WHERE " + strings.Join(parts, " AND ")
-	}
-	return clause, m
-}
@@ -2,25 +2,23 @@ package project
 
 import (
 	"errors"
-	"reflect"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/vloryan/go-libs/jsonapi"
-	"github.com/vloryan/go-libs/reflectx"
 	"github.com/vloryan/go-libs/sqlx/pagination"
 	"github.com/vloryan/protrakgon/internal/app/server/db"
 )
 
 type Slot struct {
 	ID          int        `json:"id,omitempty"`
-	ProjectID   int        `json:"projectId,omitempty"`
+	ProjectID   int        `json:"projectId,omitempty" validate:"required"`
 	Activity    Activity   `json:"activity"`
 	Start       time.Time  `json:"start,omitempty" db:"started_at"`
 	End         *time.Time `json:"end,omitempty" db:"ended_at"`
-	Description *string    `json:"description,omitempty"`
+	Description *string    `json:"description,omitempty" validate:"max=1000"`
+	Version     int        `json:"version,omitempty"`
 }
 
 func (p *Slot) SetIdentifier(id *jsonapi.ResourceIdentifierObject) {
@@ -49,15 +47,19 @@ func (p *Slot) GetIdentifier() *jsonapi.ResourceIdentifierObject {
 	return id
 }
 
+func (p *Slot) GetVersion() int {
+	return p.Version
+}
+
 type SlotFilter struct {
-	ProjectID       *int            `form:"filter[projectID]"`
-	Activity        *Activity       `form:"filter[activity]"`
-	From            *time.Time      `form:"filter[from]" time_format:"2006-01-02" time_utc:"true"`
+	ProjectID       *int            `form:"filter[projectID]" query:"project_id,eq"`
+	Activity        *Activity       `form:"filter[activity]" query:"activity,eq"`
+	From            *time.Time      `form:"filter[from]" time_format:"2006-01-02" time_utc:"true" query:"started_at,eq,date,cmp=FromComparator"`
 	FromComparator  CompareOperator `form:"filter[fromComparator]"`
-	Until           *time.Time      `form:"filter[until]" time_format:"2006-01-02" time_utc:"true"`
+	Until           *time.Time      `form:"filter[until]" time_format:"2006-01-02" time_utc:"true" query:"ended_at,eq,cmp=UntilComparator"`
 	UntilComparator CompareOperator `form:"filter[untilComparator]"`
-	IsOpen          *bool           `form:"filter[isOpen]"`
-	Description     *string         `form:"filter[description]"`
+	IsOpen          *bool           `form:"filter[isOpen]" query:"ended_at,isnull"`
+	Description     *string         `form:"filter[description]" query:"description,like"`
 }
 
 type CompareOperator int
@@ -71,27 +73,62 @@ const (
 	CompareOperatorGreaterThanOrEqual
 )
 
+func (c CompareOperator) Operator() db.Operator {
+	switch c {
+	case CompareOperatorNotEqual:
+		return db.OpNotEqual
+	case CompareOperatorLessThan:
+		return db.OpLessThan
+	case CompareOperatorLessThanOrEqual:
+		return db.OpLessThanOrEqual
+	case CompareOperatorGreaterThan:
+		return db.OpGreaterThan
+	case CompareOperatorGreaterThanOrEqual:
+		return db.OpGreaterThanOrEqual
+	default:
+		return db.OpEqual
+	}
+}
+
 var (
 	ErrOpenSlotExists         = errors.New("open slot exists")
 	ErrSlotEndsBeforeStart    = errors.New("slot ends before start")
 	ErrSlotEndsOnDifferentDay = errors.New("slot ends on different day")
+	ErrSlotOverlaps           = errors.New("slot overlaps existing slot")
+	ErrSlotLocked             = errors.New("slot is locked")
 )
 
+// SlotLock vetoes changes of slots, e.g. of signed-off periods. CheckSlot returns an error wrapping
+// ErrSlotLocked if the slot must not be created, changed or deleted.
+type SlotLock interface {
+	CheckSlot(tx db.Transaction, slot *Slot) error
+}
+
 type SlotService interface {
 	Start(slot *Slot)
+	Validate(tx db.Transaction, slot *Slot) error
 	Save(tx db.Transaction, slot *Slot) error
 	GetAll(tx db.Transaction, page *pagination.Page, filter *SlotFilter) ([]*Slot, error)
+	GetPage(tx db.Transaction, page *db.CursorPage, filter *SlotFilter) ([]*Slot, error)
 	GetByID(tx db.Transaction, id int) (*Slot, error)
 	GetOpenSlot(tx db.Transaction, projectID int) (*Slot, error)
 	Delete(tx db.Transaction, id int) error
 }
 
-func NewSlotService(repo db.CRUDRepository[*Slot, *SlotFilter]) SlotService {
-	return &slotService{slotRepo: repo, now: time.Now}
+type SlotRepo interface {
+	db.CRUDRepository[*Slot, *SlotFilter]
+	db.KeysetRepository[*Slot, *SlotFilter]
+}
+
+// NewSlotService checks each slot saved or deleted against locks, a slot moved to another period is
+// checked before and after the change.
+func NewSlotService(repo SlotRepo, locks ...SlotLock) SlotService {
+	return &slotService{slotRepo: repo, locks: locks, now: time.Now}
 }
 
 type slotService struct {
-	slotRepo db.CRUDRepository[*Slot, *SlotFilter]
+	slotRepo SlotRepo
+	locks    []SlotLock
 	now      func() time.Time
 }
 
@@ -100,6 +137,43 @@ func (s *slotService) Start(slot *Slot) {
 }
 
 func (s *slotService) Save(tx db.Transaction, slot *Slot) error {
+	if err := s.Validate(tx, slot); err != nil {
+		return err
+	}
+	if slot.ID != 0 {
+		if err := s.checkLocks(tx, slot.ID); err != nil {
+			return err
+		}
+	}
+	if err := s.checkSlot(tx, slot); err != nil {
+		return err
+	}
+	return s.slotRepo.Save(tx, slot)
+}
+
+// checkLocks checks the stored slot with the id against the locks.
+func (s *slotService) checkLocks(tx db.Transaction, id int) error {
+	if len(s.locks) == 0 {
+		return nil
+	}
+	stored, err := s.slotRepo.GetByID(tx, id)
+	if err != nil || stored == nil {
+		return err
+	}
+	return s.checkSlot(tx, stored)
+}
+
+func (s *slotService) checkSlot(tx db.Transaction, slot *Slot) error {
+	for _, lock := range s.locks {
+		if err := lock.CheckSlot(tx, slot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate normalizes start and end of the slot and checks it against the rules applied on Save.
+func (s *slotService) Validate(tx db.Transaction, slot *Slot) error {
 	slot.Start = slot.Start.UTC().Truncate(time.Minute)
 	if slot.End == nil {
 		openSlot, err := s.GetOpenSlot(tx, slot.ProjectID)
@@ -119,13 +193,17 @@ func (s *slotService) Save(tx db.Transaction, slot *Slot) error {
 			return ErrSlotEndsOnDifferentDay
 		}
 	}
-	return s.slotRepo.Save(tx, slot)
+	return nil
 }
 
 func (s *slotService) GetAll(tx db.Transaction, page *pagination.Page, filter *SlotFilter) ([]*Slot, error) {
 	return s.slotRepo.GetAll(tx, page, filter)
 }
 
+func (s *slotService) GetPage(tx db.Transaction, page *db.CursorPage, filter *SlotFilter) ([]*Slot, error) {
+	return s.slotRepo.GetPage(tx, page, filter)
+}
+
 func (s *slotService) GetByID(tx db.Transaction, id int) (*Slot, error) {
 	return s.slotRepo.GetByID(tx, id)
 }
@@ -146,12 +224,39 @@ func (s *slotService) GetOpenSlot(tx db.Transaction, projectID int) (*Slot, erro
 }
 
 func (s *slotService) Delete(tx db.Transaction, id int) error {
+	if err := s.checkLocks(tx, id); err != nil {
+		return err
+	}
 	return s.slotRepo.Delete(tx, id)
 }
 
 type SlotRepository struct{}
 
-func NewSlotRepository() db.CRUDRepository[*Slot, *SlotFilter] {
+var slotQuery = &db.Query{
+	Table:   "slot",
+	Columns: []string{"id", "project_id", "activity", "started_at", "ended_at", "description", "version"},
+	SortFields: map[string]string{
+		"id":          "id",
+		"projectId":   "project_id",
+		"activity":    "activity",
+		"start":       "started_at",
+		"end":         "ended_at",
+		"description": "description",
+	},
+}
+
+// slotKeyset orders slots by start, the id makes the order unique for slots starting at the same time.
+var slotKeyset = &db.Keyset[*Slot]{
+	Columns: []string{"started_at", "id"},
+	Values: func(slot *Slot) []any {
+		return []any{slot.Start, slot.ID}
+	},
+	Targets: func() []any {
+		return []any{&time.Time{}, new(int)}
+	},
+}
+
+func NewSlotRepository() SlotRepo {
 	return &SlotRepository{}
 }
 
@@ -169,6 +274,7 @@ func (r *SlotRepository) Save(tx db.Transaction, slot *Slot) error {
 			return err
 		}
 		slot.ID = int(id)
+		slot.Version = 1
 	} else {
 		stmt := `UPDATE slot 
 				    SET
@@ -176,9 +282,11 @@ func (r *SlotRepository) Save(tx db.Transaction, slot *Slot) error {
 						activity    = :activity,
 						started_at  = :start,
 						ended_at    = :end,
-				        description = :description
+				        description = :description,
+				        version     = version + 1
 				  WHERE 
-				        id = :id`
+				        id = :id
+				    AND version = :version`
 
 		result, err := tx.Exec(stmt, slot)
 		if err != nil {
@@ -189,8 +297,9 @@ func (r *SlotRepository) Save(tx db.Transaction, slot *Slot) error {
 			return err
 		}
 		if affected == 0 {
-			return errors.New("update failed: 0 rows affected")
+			return db.UpdateError(tx, "slot", slot.ID)
 		}
+		slot.Version++
 	}
 
 	return nil
@@ -198,7 +307,7 @@ func (r *SlotRepository) Save(tx db.Transaction, slot *Slot) error {
 
 func (r *SlotRepository) GetByID(tx db.Transaction, id int) (*Slot, error) {
 	slot := &Slot{}
-	stmt := `SELECT id, project_id, activity, started_at, ended_at, description
+	stmt := `SELECT id, project_id, activity, started_at, ended_at, description, version
 			   FROM slot 
 			  WHERE id = :id`
 
@@ -212,74 +321,15 @@ func (r *SlotRepository) GetByID(tx db.Transaction, id int) (*Slot, error) {
 }
 
 func (r *SlotRepository) GetAll(tx db.Transaction, page *pagination.Page, filter *SlotFilter) ([]*Slot, error) {
-	projects := make([]*Slot, 0, 10)
-	stmt := `SELECT id, project_id, activity, started_at, ended_at, description
-               FROM slot`
-	countStmt := `SELECT COUNT(*) AS total_count 
-				    FROM slot`
-
-	var orderArgs []string
-	s := Slot{}
-	t := reflect.TypeOf(s)
-
-	for _, sort := range page.Sort {
-		var dir string
-		var fieldName string
-		if strings.HasPrefix(sort, "-") {
-			fieldName = sort[1:]
-			dir = " DESC"
-		} else {
-			fieldName = sort
-		}
-		field, ok := t.FieldByNameFunc(func(s string) bool {
-			return strings.EqualFold(s, fieldName)
-		})
-		if !ok {
-			return nil, errors.New("field " + fieldName + " not found")
-		}
-		dbTag := reflectx.Tag(field, "db")
-		dbField := dbTag.Value
-		if dbField == "" {
-			dbField = fieldName
-		}
-		orderArgs = append(orderArgs, dbField+dir)
-	}
-	whereClause, whereParams := r.toWhereClause(filter)
-	selectParams := make(map[string]any)
-	for k, v := range whereParams {
-		selectParams[k] = v
-	}
-	if len(whereClause) > 0 {
-		stmt += "\n" + whereClause
-		countStmt += "\n" + whereClause
-	}
-
-	if len(orderArgs) > 0 {
-		stmt += "\nORDER BY " + strings.Join(orderArgs, ", ")
-	}
-
-	if page.Limit != -1 {
-		stmt += "\nLIMIT :limit"
-		selectParams["limit"] = page.Limit
-		if page.Offset != 0 {
-			stmt += "\nOFFSET :offset"
-			selectParams["offset"] = page.Offset * page.Limit
-		}
-	}
-	if len(whereParams) > 0 {
-		if err := tx.Select(page, countStmt, whereParams); err != nil {
-			return nil, err
-		}
-	} else {
-		if err := tx.Select(page, countStmt); err != nil {
-			return nil, err
-		}
-	}
-
-	if err := tx.Select(&projects, stmt, selectParams); err != nil {
+	slots := make([]*Slot, 0, 10)
+	if err := slotQuery.GetAll(tx, &slots, page, filter); err != nil {
 		return nil, err
 	}
-	return projects, nil
+	return slots, nil
+}
+
+func (r *SlotRepository) GetPage(tx db.Transaction, page *db.CursorPage, filter *SlotFilter) ([]*Slot, error) {
+	return db.GetPage(tx, slotQuery, slotKeyset, page, filter)
 }
 
 func (r *SlotRepository) Delete(tx db.Transaction, id int) error {
@@ -295,72 +345,11 @@ func (r *SlotRepository) Delete(tx db.Transaction, id int) error {
 	if err != nil {
 		return err
 	}
+	if affected == 0 {
+		return db.ErrNotFound
+	}
 	if affected != 1 {
 		return errors.New("delete failed: " + strconv.Itoa(int(affected)) + " rows affected")
 	}
 	return err
 }
-
-func (r *SlotRepository) toWhereClause(filter *SlotFilter) (string, map[string]any) {
-	clause := ""
-	parts := make([]string, 0, 10)
-	m := make(map[string]any)
-	if filter.ProjectID != nil {
-		m["projectId"] = filter.ProjectID
-		parts = append(parts, "project_id = :projectId")
-	}
-	if filter.Activity != nil {
-		m["activity"] = *filter.Activity
-		parts = append(parts, "activity = :activity")
-	}
-	if filter.From != nil {
-		m["startTime"] = *filter.From
-		switch filter.FromComparator {
-		case CompareOperatorEqual:
-			parts = append(parts, "date(started_at) = date(:startTime)")
-		case CompareOperatorNotEqual:
-			parts = append(parts, "date(started_at) <> date(:startTime)")
-		case CompareOperatorLessThan:
-			parts = append(parts, "date(started_at) < date(:startTime)")
-		case CompareOperatorLessThanOrEqual:
-			parts = append(parts, "date(started_at) <= date(:startTime)")
-		case CompareOperatorGreaterThan:
-			parts = append(parts, "date(started_at) > date(:startTime)")
-		case CompareOperatorGreaterThanOrEqual:
-			parts = append(parts, "date(started_at) >= date(:startTime)")
-		}
-	}
-	if filter.Until != nil {
-		m["endTime"] = *filter.Until
-		switch filter.UntilComparator {
-		case CompareOperatorEqual:
-			parts = append(parts, "ended_at = :endTime")
-		case CompareOperatorNotEqual:
-			parts = append(parts, "ended_at <> :endTime")
-		case CompareOperatorLessThan:
-			parts = append(parts, "ended_at < :endTime")
-		case CompareOperatorLessThanOrEqual:
-			parts = append(parts, "ended_at <= :endTime")
-		case CompareOperatorGreaterThan:
-			parts = append(parts, "ended_at > :endTime")
-		case CompareOperatorGreaterThanOrEqual:
-			parts = append(parts, "ended_at >= :endTime")
-		}
-
-	}
-	if filter.IsOpen != nil {
-		if *filter.IsOpen {
-			parts = append(parts, "ended_at IS NULL")
-		} else {
-			parts = append(parts, "ended_at IS NOT NULL")
-		}
-	}
-	if filter.Description != nil {
-		m["description"] = "%" + strings.ToLower(*filter.Description) + "%"
-		parts = append(parts, "description LIKE :description")
-	}
-	if len(parts) > 0 {
-		clause = "WHERE " + strings.Join(parts, " AND ")
-	}
-	return clause, m
-}
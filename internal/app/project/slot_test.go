@@ -46,10 +46,18 @@ func (r *inMemSlotRepository) Save(_ db.Transaction, item *Slot) error {
 }
 
 func (r *inMemSlotRepository) GetByID(_ db.Transaction, id int) (*Slot, error) {
-	return r.Slots[id], nil
+	for _, slot := range r.Slots {
+		if slot.ID == id {
+			return slot, nil
+		}
+	}
+	return nil, nil
 }
 
 func (r *inMemSlotRepository) GetAll(_ db.Transaction, _ *pagination.Page, filter *SlotFilter) ([]*Slot, error) {
+	if filter == nil {
+		return r.Slots, nil
+	}
 	var matchingSlot []*Slot
 	for _, slot := range r.Slots {
 		if filter.ProjectID != nil && slot.ProjectID != *filter.ProjectID {
@@ -75,6 +83,10 @@ func (r *inMemSlotRepository) GetAll(_ db.Transaction, _ *pagination.Page, filte
 	return matchingSlot, nil
 }
 
+func (r *inMemSlotRepository) GetPage(tx db.Transaction, page *db.CursorPage, filter *SlotFilter) ([]*Slot, error) {
+	return r.GetAll(tx, &pagination.Page{Limit: page.Size}, filter)
+}
+
 func (r *inMemSlotRepository) Delete(_ db.Transaction, id int) error {
 	r.Slots = slices.Delete(r.Slots, id, id)
 	return nil
@@ -93,13 +105,24 @@ func (r *inMemSlotRepository) match(a time.Time, c CompareOperator, b time.Time)
 	case CompareOperatorLessThan:
 		return a.Before(b)
 	case CompareOperatorLessThanOrEqual:
-		return a.Equal(b) || a.After(b)
+		return a.Equal(b) || a.Before(b)
 	}
 	return false
 }
 
 type scenario struct {
 	slots []*Slot
+	locks []SlotLock
+}
+
+// lockBefore locks all slots starting before the time.
+type lockBefore time.Time
+
+func (l lockBefore) CheckSlot(_ db.Transaction, slot *Slot) error {
+	if slot.Start.Before(time.Time(l)) {
+		return ErrSlotLocked
+	}
+	return nil
 }
 
 func buildScenario(g scenario) (SlotService, *inMemSlotRepository) {
@@ -108,6 +131,7 @@ func buildScenario(g scenario) (SlotService, *inMemSlotRepository) {
 	}
 	return &slotService{
 		slotRepo: repo,
+		locks:    g.locks,
 		now: func() time.Time {
 			return testhelper.FixedNow
 		},
@@ -268,6 +292,41 @@ func TestDefaultService_Save(t *testing.T) {
 			End:       testhelper.Ptr(testhelper.FixedNow.Add(48 * time.Hour)),
 		},
 		wantErr: ErrSlotEndsOnDifferentDay,
+	}, {
+		name:  "GIVEN slot in locked period THEN throw ErrSlotLocked",
+		given: scenario{locks: []SlotLock{lockBefore(testhelper.FixedNow)}},
+		slot: &Slot{
+			ProjectID: defaultProjectID,
+			Activity:  ActivityWork,
+			Start:     testhelper.FixedNow.Add(-2 * time.Hour),
+			End:       testhelper.Ptr(testhelper.FixedNow.Add(-time.Hour)),
+		},
+		wantErr: ErrSlotLocked,
+	}, {
+		name:  "GIVEN slot moved out of locked period THEN throw ErrSlotLocked",
+		given: scenario{slots: []*Slot{defaultClosedSlot}, locks: []SlotLock{lockBefore(testhelper.FixedNow.Add(-2 * time.Hour))}},
+		slot: &Slot{
+			ID:        defaultClosedSlot.ID,
+			ProjectID: defaultProjectID,
+			Activity:  ActivityWork,
+			Start:     testhelper.FixedNow.Add(-time.Hour),
+			End:       testhelper.Ptr(testhelper.FixedNow),
+		},
+		wantErr: ErrSlotLocked,
+	}, {
+		name:  "GIVEN slot after locked period THEN save slot",
+		given: scenario{locks: []SlotLock{lockBefore(testhelper.FixedNow.Add(-2 * time.Hour))}},
+		slot: &Slot{
+			ProjectID: defaultProjectID,
+			Activity:  ActivityWork,
+			Start:     testhelper.FixedNow.Add(-time.Hour),
+		},
+		want: []*Slot{{
+			ID:        1,
+			ProjectID: defaultProjectID,
+			Activity:  ActivityWork,
+			Start:     testhelper.FixedNow.Truncate(time.Minute).Add(-time.Hour),
+		}},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
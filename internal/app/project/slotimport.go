@@ -0,0 +1,350 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/validate"
+)
+
+// SlotImportRule maps calendar events to a project. An event matches if its summary or
+// description contains the keyword or if it was organized by the organizer.
+type SlotImportRule struct {
+	ID        int      `json:"id,omitempty"`
+	ProjectID int      `json:"projectId,omitempty" validate:"required"`
+	Activity  Activity `json:"activity"`
+	Keyword   *string  `json:"keyword,omitempty" validate:"max=200"`
+	Organizer *string  `json:"organizer,omitempty" validate:"max=200"`
+}
+
+func (r *SlotImportRule) SetIdentifier(id *jsonapi.ResourceIdentifierObject) {
+	if id == nil || id.Type != "project.slotImportRule" {
+		log.Error().Msgf("SlotImportRule identifier object is invalid")
+		return
+	}
+	if len(id.ID) == 0 {
+		return
+	}
+	idInt, err := strconv.ParseInt(id.ID, 10, 64)
+	if err != nil {
+		log.Err(err).Msg("SlotImportRule identifier is not a valid identifier")
+		return
+	}
+	r.ID = int(idInt)
+}
+
+func (r *SlotImportRule) GetIdentifier() *jsonapi.ResourceIdentifierObject {
+	id := &jsonapi.ResourceIdentifierObject{
+		Type: "project.slotImportRule",
+	}
+	if r.ID != 0 {
+		id.ID = strconv.Itoa(r.ID)
+	}
+	return id
+}
+
+// Validate requires a keyword or an organizer, a rule without both never matches.
+func (r *SlotImportRule) Validate() error {
+	if (r.Keyword == nil || *r.Keyword == "") && (r.Organizer == nil || *r.Organizer == "") {
+		return validate.Errors{
+			validate.Attribute("keyword", "required", "must not be empty if organizer is empty"),
+			validate.Attribute("organizer", "required", "must not be empty if keyword is empty"),
+		}
+	}
+	return nil
+}
+
+func (r *SlotImportRule) Matches(event *CalendarEvent) bool {
+	if r.Keyword != nil && *r.Keyword != "" {
+		keyword := strings.ToLower(*r.Keyword)
+		if strings.Contains(strings.ToLower(event.Summary), keyword) ||
+			strings.Contains(strings.ToLower(event.Description), keyword) {
+			return true
+		}
+	}
+	if r.Organizer != nil && *r.Organizer != "" {
+		if strings.EqualFold(event.Organizer, *r.Organizer) {
+			return true
+		}
+	}
+	return false
+}
+
+type SlotImportRuleFilter struct {
+	ProjectID *int `form:"filter[projectId]" query:"project_id,eq"`
+}
+
+var ErrSlotHasNoEnd = errors.New("slot has no end")
+
+// SlotSuggestion is a draft slot created from a calendar event. It is not persisted until confirmed.
+type SlotSuggestion struct {
+	UID         string     `json:"uid,omitempty"`
+	ProjectID   int        `json:"projectId,omitempty"`
+	Activity    Activity   `json:"activity"`
+	Start       time.Time  `json:"start,omitempty"`
+	End         *time.Time `json:"end,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	Organizer   string     `json:"organizer,omitempty"`
+	Conflicts   []int      `json:"conflicts,omitempty"`
+	Problem     string     `json:"problem,omitempty"`
+}
+
+func (s *SlotSuggestion) SetIdentifier(id *jsonapi.ResourceIdentifierObject) {
+	if id == nil || id.Type != "project.slotSuggestion" {
+		log.Error().Msgf("SlotSuggestion identifier object is invalid")
+		return
+	}
+	s.UID = id.ID
+}
+
+func (s *SlotSuggestion) GetIdentifier() *jsonapi.ResourceIdentifierObject {
+	return &jsonapi.ResourceIdentifierObject{
+		Type: "project.slotSuggestion",
+		ID:   s.UID,
+	}
+}
+
+func (s *SlotSuggestion) ToSlot() *Slot {
+	return &Slot{
+		ProjectID:   s.ProjectID,
+		Activity:    s.Activity,
+		Start:       s.Start,
+		End:         s.End,
+		Description: s.Description,
+	}
+}
+
+type SlotImportService interface {
+	Suggest(tx db.Transaction, events []*CalendarEvent) ([]*SlotSuggestion, error)
+	Confirm(tx db.Transaction, suggestions []*SlotSuggestion) ([]*Slot, error)
+}
+
+func NewSlotImportService(ruleRepo db.CRUDRepository[*SlotImportRule, *SlotImportRuleFilter], slotService SlotService) SlotImportService {
+	return &slotImportService{ruleRepo: ruleRepo, slotService: slotService}
+}
+
+type slotImportService struct {
+	ruleRepo    db.CRUDRepository[*SlotImportRule, *SlotImportRuleFilter]
+	slotService SlotService
+}
+
+// Suggest creates a suggestion for each event that does not last all day. The first matching rule sets
+// the project and activity, a suggestion without project or failing validation has a Problem.
+func (s *slotImportService) Suggest(tx db.Transaction, events []*CalendarEvent) ([]*SlotSuggestion, error) {
+	rules, err := s.ruleRepo.GetAll(tx, &pagination.Page{Limit: -1}, &SlotImportRuleFilter{})
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make([]*SlotSuggestion, 0, len(events))
+	for _, event := range events {
+		if event.AllDay {
+			continue
+		}
+		suggestion := &SlotSuggestion{
+			UID:       event.UID,
+			Activity:  ActivityWork,
+			Start:     event.Start,
+			End:       &event.End,
+			Organizer: event.Organizer,
+		}
+		if event.Summary != "" {
+			suggestion.Description = &event.Summary
+		}
+		for _, rule := range rules {
+			if rule.Matches(event) {
+				suggestion.ProjectID = rule.ProjectID
+				suggestion.Activity = rule.Activity
+				break
+			}
+		}
+		slot := suggestion.ToSlot()
+		if err := s.slotService.Validate(tx, slot); err != nil {
+			suggestion.Problem = err.Error()
+		} else if slot.ProjectID == 0 {
+			suggestion.Problem = "no rule matches the event, the project is missing"
+		}
+		suggestion.Start = slot.Start
+		suggestion.End = slot.End
+		conflicts, err := s.findOverlapping(tx, slot)
+		if err != nil {
+			return nil, err
+		}
+		for _, conflict := range conflicts {
+			suggestion.Conflicts = append(suggestion.Conflicts, conflict.ID)
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
+
+// Confirm saves all suggestions as slots. It fails on the first suggestion that does not pass
+// validation or overlaps with an existing slot, so the caller should roll back the transaction.
+// Field errors point to the suggestion in the request document, e.g. /data/1/attributes/projectId.
+func (s *slotImportService) Confirm(tx db.Transaction, suggestions []*SlotSuggestion) ([]*Slot, error) {
+	slots := make([]*Slot, 0, len(suggestions))
+	for i, suggestion := range suggestions {
+		slot := suggestion.ToSlot()
+		if slot.End == nil {
+			return nil, fmt.Errorf("suggestion %d: %w", i, ErrSlotHasNoEnd)
+		}
+		if err := validate.Struct(slot); err != nil {
+			var fieldErrs validate.Errors
+			if errors.As(err, &fieldErrs) {
+				for _, fieldErr := range fieldErrs {
+					fieldErr.Pointer = "/data/" + strconv.Itoa(i) + strings.TrimPrefix(fieldErr.Pointer, "/data")
+				}
+			}
+			return nil, err
+		}
+		if err := s.slotService.Validate(tx, slot); err != nil {
+			return nil, fmt.Errorf("suggestion %d: %w", i, err)
+		}
+		conflicts, err := s.findOverlapping(tx, slot)
+		if err != nil {
+			return nil, err
+		}
+		if len(conflicts) > 0 {
+			return nil, fmt.Errorf("suggestion %d: %w (id: %d)", i, ErrSlotOverlaps, conflicts[0].ID)
+		}
+		if err := s.slotService.Save(tx, slot); err != nil {
+			return nil, fmt.Errorf("suggestion %d: %w", i, err)
+		}
+		slots = append(slots, slot)
+	}
+	return slots, nil
+}
+
+func (s *slotImportService) findOverlapping(tx db.Transaction, slot *Slot) ([]*Slot, error) {
+	day := slot.Start.UTC().Truncate(24 * time.Hour)
+	sameDay, err := s.slotService.GetAll(tx, &pagination.Page{Limit: -1}, &SlotFilter{
+		From:           &day,
+		FromComparator: CompareOperatorEqual,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var overlapping []*Slot
+	for _, existing := range sameDay {
+		if slotsOverlap(existing, slot) {
+			overlapping = append(overlapping, existing)
+		}
+	}
+	return overlapping, nil
+}
+
+func slotsOverlap(a, b *Slot) bool {
+	if a.End != nil && !a.End.After(b.Start) {
+		return false
+	}
+	if b.End != nil && !b.End.After(a.Start) {
+		return false
+	}
+	return true
+}
+
+type SlotImportRuleRepository struct{}
+
+var slotImportRuleQuery = &db.Query{
+	Table:   "slot_import_rule",
+	Columns: []string{"id", "project_id", "activity", "keyword", "organizer"},
+	SortFields: map[string]string{
+		"id":        "id",
+		"projectId": "project_id",
+	},
+	DefaultSort: []string{"id"},
+}
+
+func NewSlotImportRuleRepository() db.CRUDRepository[*SlotImportRule, *SlotImportRuleFilter] {
+	return &SlotImportRuleRepository{}
+}
+
+func (r *SlotImportRuleRepository) Save(tx db.Transaction, item *SlotImportRule) error {
+	if item.ID == 0 {
+		stmt := `INSERT INTO slot_import_rule (project_id, activity, keyword, organizer)
+				  VALUES (:projectId, :activity, :keyword, :organizer)`
+
+		result, err := tx.Exec(stmt, item)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		item.ID = int(id)
+	} else {
+		stmt := `UPDATE slot_import_rule
+				    SET
+						project_id = :projectId,
+						activity   = :activity,
+						keyword    = :keyword,
+						organizer  = :organizer
+				  WHERE
+				        id = :id`
+
+		result, err := tx.Exec(stmt, item)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return db.ErrNotFound
+		}
+	}
+	return nil
+}
+
+func (r *SlotImportRuleRepository) GetByID(tx db.Transaction, id int) (*SlotImportRule, error) {
+	item := &SlotImportRule{}
+	stmt := `SELECT id, project_id, activity, keyword, organizer
+			   FROM slot_import_rule
+			  WHERE id = :id`
+
+	if err := tx.Select(item, stmt, map[string]any{"id": id}); err != nil {
+		return nil, err
+	}
+	if item.ID == 0 {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (r *SlotImportRuleRepository) GetAll(tx db.Transaction, page *pagination.Page, filter *SlotImportRuleFilter) ([]*SlotImportRule, error) {
+	items := make([]*SlotImportRule, 0, 10)
+	if err := slotImportRuleQuery.GetAll(tx, &items, page, filter); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *SlotImportRuleRepository) Delete(tx db.Transaction, id int) error {
+	stmt := `DELETE
+               FROM slot_import_rule
+              WHERE id = :id`
+
+	result, err := tx.Exec(stmt, map[string]interface{}{"id": id})
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return db.ErrNotFound
+	}
+	if affected != 1 {
+		return errors.New("delete failed: " + strconv.Itoa(int(affected)) + " rows affected")
+	}
+	return err
+}
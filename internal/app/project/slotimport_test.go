@@ -0,0 +1,202 @@
+package project
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/go-libs/testhelper"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/request"
+	"github.com/vloryan/protrakgon/internal/app/server/validate"
+)
+
+// sameDaySlotRepository returns the slots starting on the day of filter[from] like the date comparison of
+// SlotRepository.
+type sameDaySlotRepository struct {
+	*inMemSlotRepository
+}
+
+func (r sameDaySlotRepository) GetAll(_ db.Transaction, _ *pagination.Page, filter *SlotFilter) ([]*Slot, error) {
+	var slots []*Slot
+	for _, slot := range r.Slots {
+		if slot.Start.UTC().Truncate(24 * time.Hour).Equal(*filter.From) {
+			slots = append(slots, slot)
+		}
+	}
+	return slots, nil
+}
+
+func buildSlotImportScenario(rules []*SlotImportRule, slots []*Slot) (SlotImportService, *inMemSlotRepository) {
+	repo := &inMemSlotRepository{Slots: slots}
+	slotService := &slotService{slotRepo: sameDaySlotRepository{repo}, now: func() time.Time {
+		return testhelper.FixedNow
+	}}
+	ruleRepo := &inMemResources[*SlotImportRule, *SlotImportRuleFilter]{Items: rules}
+	return NewSlotImportService(ruleRepo, slotService), repo
+}
+
+// fixedDayAt returns the time of the day of testhelper.FixedNow in UTC.
+func fixedDayAt(hour, minute int) time.Time {
+	return time.Date(2025, time.January, 15, hour, minute, 0, 0, time.UTC)
+}
+
+func TestSlotImportService_Suggest(t *testing.T) {
+	rules := []*SlotImportRule{
+		{ID: 1, ProjectID: 2, Activity: ActivityWork, Keyword: testhelper.Ptr("standup")},
+		{ID: 2, ProjectID: 3, Activity: ActivityBreak, Keyword: testhelper.Ptr("stand")},
+		{ID: 3, ProjectID: 4, Activity: ActivityWork, Organizer: testhelper.Ptr("jane@example.com")},
+	}
+	tests := []struct {
+		name   string
+		slots  []*Slot
+		events []*CalendarEvent
+		want   []*SlotSuggestion
+	}{{
+		name:   "GIVEN event matching keywords of two rules THEN suggest project of first rule",
+		events: []*CalendarEvent{{UID: "1", Summary: "Daily Standup", Start: fixedDayAt(9, 0), End: fixedDayAt(9, 15)}},
+		want: []*SlotSuggestion{{UID: "1", ProjectID: 2, Activity: ActivityWork, Start: fixedDayAt(9, 0), End: testhelper.Ptr(fixedDayAt(9, 15)),
+			Description: testhelper.Ptr("Daily Standup")}},
+	}, {
+		name:   "GIVEN event of organizer THEN suggest project of rule",
+		events: []*CalendarEvent{{UID: "1", Organizer: "Jane@Example.com", Start: fixedDayAt(11, 0), End: fixedDayAt(12, 0)}},
+		want: []*SlotSuggestion{{UID: "1", ProjectID: 4, Activity: ActivityWork, Start: fixedDayAt(11, 0), End: testhelper.Ptr(fixedDayAt(12, 0)),
+			Organizer: "Jane@Example.com"}},
+	}, {
+		name:   "GIVEN event matching no rule THEN report missing project",
+		events: []*CalendarEvent{{UID: "1", Summary: "Lunch", Start: fixedDayAt(12, 0), End: fixedDayAt(13, 0)}},
+		want: []*SlotSuggestion{{UID: "1", Activity: ActivityWork, Start: fixedDayAt(12, 0), End: testhelper.Ptr(fixedDayAt(13, 0)),
+			Description: testhelper.Ptr("Lunch"), Problem: "no rule matches the event, the project is missing"}},
+	}, {
+		name:   "GIVEN event overlapping slots THEN list conflicts",
+		slots:  []*Slot{defaultClosedSlot, {ID: 2, ProjectID: 1, Activity: ActivityWork, Start: fixedDayAt(8, 30), End: testhelper.Ptr(fixedDayAt(9, 5))}},
+		events: []*CalendarEvent{{UID: "1", Summary: "Standup", Start: fixedDayAt(9, 0), End: fixedDayAt(9, 15)}},
+		want: []*SlotSuggestion{{UID: "1", ProjectID: 2, Activity: ActivityWork, Start: fixedDayAt(9, 0), End: testhelper.Ptr(fixedDayAt(9, 15)),
+			Description: testhelper.Ptr("Standup"), Conflicts: []int{2}}},
+	}, {
+		name:   "GIVEN event ending before start THEN report problem",
+		events: []*CalendarEvent{{UID: "1", Summary: "Standup", Start: fixedDayAt(9, 15), End: fixedDayAt(9, 0)}},
+		want: []*SlotSuggestion{{UID: "1", ProjectID: 2, Activity: ActivityWork, Start: fixedDayAt(9, 15), End: testhelper.Ptr(fixedDayAt(9, 0)),
+			Description: testhelper.Ptr("Standup"), Problem: ErrSlotEndsBeforeStart.Error()}},
+	}, {
+		name:   "GIVEN all day event THEN skip it",
+		events: []*CalendarEvent{{UID: "1", Summary: "Standup", Start: fixedDayAt(0, 0), End: fixedDayAt(0, 0).AddDate(0, 0, 1), AllDay: true}},
+		want:   []*SlotSuggestion{},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := buildSlotImportScenario(rules, tt.slots)
+
+			got, err := s.Suggest(nil, tt.events)
+
+			if err != nil {
+				t.Fatalf("Suggest() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Suggest() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSlotImportService_Confirm(t *testing.T) {
+	existing := &Slot{ID: 1, ProjectID: 1, Activity: ActivityWork, Start: fixedDayAt(8, 0), End: testhelper.Ptr(fixedDayAt(9, 0))}
+	tests := []struct {
+		name        string
+		suggestions []*SlotSuggestion
+		want        []*Slot
+		wantErr     error
+		wantPointer string
+	}{{
+		name: "GIVEN suggestions THEN save slots",
+		suggestions: []*SlotSuggestion{
+			{ProjectID: 2, Activity: ActivityWork, Start: fixedDayAt(9, 0), End: testhelper.Ptr(fixedDayAt(9, 15)), Description: testhelper.Ptr("Standup")},
+			{ProjectID: 2, Activity: ActivityBreak, Start: fixedDayAt(12, 0), End: testhelper.Ptr(fixedDayAt(13, 0))},
+		},
+		want: []*Slot{
+			{ID: 2, ProjectID: 2, Activity: ActivityWork, Start: fixedDayAt(9, 0), End: testhelper.Ptr(fixedDayAt(9, 15)), Description: testhelper.Ptr("Standup")},
+			{ID: 3, ProjectID: 2, Activity: ActivityBreak, Start: fixedDayAt(12, 0), End: testhelper.Ptr(fixedDayAt(13, 0))},
+		},
+	}, {
+		name: "GIVEN suggestion without project THEN return field error of suggestion",
+		suggestions: []*SlotSuggestion{
+			{ProjectID: 2, Activity: ActivityWork, Start: fixedDayAt(9, 0), End: testhelper.Ptr(fixedDayAt(9, 15))},
+			{Activity: ActivityWork, Start: fixedDayAt(12, 0), End: testhelper.Ptr(fixedDayAt(13, 0))},
+		},
+		wantPointer: "/data/1/attributes/projectId",
+	}, {
+		name:        "GIVEN suggestion overlapping slot THEN throw ErrSlotOverlaps",
+		suggestions: []*SlotSuggestion{{ProjectID: 2, Activity: ActivityWork, Start: fixedDayAt(8, 30), End: testhelper.Ptr(fixedDayAt(9, 30))}},
+		wantErr:     ErrSlotOverlaps,
+	}, {
+		name:        "GIVEN suggestion without end THEN throw ErrSlotHasNoEnd",
+		suggestions: []*SlotSuggestion{{ProjectID: 2, Activity: ActivityWork, Start: fixedDayAt(9, 0)}},
+		wantErr:     ErrSlotHasNoEnd,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := buildSlotImportScenario(nil, []*Slot{existing})
+
+			got, err := s.Confirm(nil, tt.suggestions)
+
+			if tt.wantPointer != "" {
+				var fieldErrs validate.Errors
+				if !errors.As(err, &fieldErrs) || len(fieldErrs) != 1 || fieldErrs[0].Pointer != tt.wantPointer {
+					t.Fatalf("Confirm() error = %v, want pointer %s", err, tt.wantPointer)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Confirm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); tt.wantErr == nil && diff != "" {
+				t.Errorf("Confirm() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// rollbackConnection drops the slots saved by a failing transaction.
+type rollbackConnection struct {
+	repo *inMemSlotRepository
+}
+
+func (rollbackConnection) Select(_ any, _ string, _ ...any) error      { return nil }
+func (rollbackConnection) Exec(_ string, _ ...any) (sql.Result, error) { return nil, nil }
+func (rollbackConnection) Close() error                                { return nil }
+
+func (c rollbackConnection) DoTransaction(txFunc db.TxFunc) error {
+	saved := len(c.repo.Slots)
+	err := txFunc(nil)
+	if err != nil {
+		c.repo.Slots = c.repo.Slots[:saved]
+	}
+	return err
+}
+
+func TestSlotImportHandler_Confirm(t *testing.T) {
+	s, repo := buildSlotImportScenario(nil, nil)
+	h := &SlotImportHandler{Service: s, Zone: cet}
+	body := `{"data":[
+		{"type":"project.slotSuggestion","id":"1","attributes":{"projectId":2,"activity":"work","start":"2025-01-15T09:00:00Z","end":"2025-01-15T10:00:00Z"}},
+		{"type":"project.slotSuggestion","id":"2","attributes":{"projectId":3,"activity":"work","start":"2025-01-15T09:30:00Z","end":"2025-01-15T10:30:00Z"}}
+	]}`
+	req := httptest.NewRequest(http.MethodPost, "/project/slotImport/confirm", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), request.CtxKeyDatabase, db.Connection(rollbackConnection{repo: repo})))
+
+	_, err := h.Confirm(req)
+
+	if !errors.Is(err, ErrSlotOverlaps) {
+		t.Fatalf("Confirm() error = %v, wantErr %v", err, ErrSlotOverlaps)
+	}
+	if len(repo.Slots) != 0 {
+		t.Errorf("Confirm() saved %d slots of failing suggestions, want none", len(repo.Slots))
+	}
+}
@@ -0,0 +1,533 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/validate"
+)
+
+type TimesheetState string
+
+const (
+	TimesheetOpen      TimesheetState = "open"
+	TimesheetSubmitted TimesheetState = "submitted"
+	TimesheetApproved  TimesheetState = "approved"
+	TimesheetRejected  TimesheetState = "rejected"
+)
+
+// timesheetTransitions are the states a timesheet may change to from its state. A rejected timesheet
+// is corrected and submitted again, an approved timesheet is final.
+var timesheetTransitions = map[TimesheetState][]TimesheetState{
+	TimesheetOpen:      {TimesheetSubmitted},
+	TimesheetSubmitted: {TimesheetApproved, TimesheetRejected},
+	TimesheetRejected:  {TimesheetSubmitted},
+}
+
+// Timesheet is the sign-off of the slots of a week from Monday to Sunday in the time zone of the instance.
+// A week has one timesheet, it covers all slots as they belong to the owner of the instance.
+// Days and TotalMinutes are computed from the slots of the week, they count work but no breaks.
+// The slots of a submitted or approved timesheet cannot be changed.
+type Timesheet struct {
+	ID int `json:"id,omitempty"`
+	// Week is the ISO week, e.g. 2025-W10.
+	Week         string          `json:"week" validate:"required"`
+	State        TimesheetState  `json:"state,omitempty"`
+	Comment      *string         `json:"comment,omitempty" validate:"max=1000"`
+	SubmittedAt  *time.Time      `json:"submittedAt,omitempty" db:"submitted_at"`
+	DecidedAt    *time.Time      `json:"decidedAt,omitempty" db:"decided_at"`
+	Version      int             `json:"version,omitempty"`
+	Start        string          `json:"start,omitempty" db:"-"`
+	End          string          `json:"end,omitempty" db:"-"`
+	Days         []*TimesheetDay `json:"days,omitempty" db:"-"`
+	TotalMinutes int             `json:"totalMinutes" db:"-"`
+}
+
+// TimesheetDay is the work of a day of a timesheet.
+type TimesheetDay struct {
+	Date     string              `json:"date"`
+	Minutes  int                 `json:"minutes"`
+	Projects []*TimesheetProject `json:"projects"`
+}
+
+// TimesheetProject is the work of a day on a project.
+type TimesheetProject struct {
+	ProjectID int `json:"projectId"`
+	Minutes   int `json:"minutes"`
+}
+
+func (t *Timesheet) SetIdentifier(id *jsonapi.ResourceIdentifierObject) {
+	if id == nil || id.Type != "project.timesheet" {
+		log.Error().Msgf("Timesheet identifier object is invalid")
+		return
+	}
+	if len(id.ID) == 0 {
+		return
+	}
+	idInt, err := strconv.ParseInt(id.ID, 10, 64)
+	if err != nil {
+		log.Err(err).Msg("Timesheet identifier is not a valid identifier")
+		return
+	}
+	t.ID = int(idInt)
+}
+
+func (t *Timesheet) GetIdentifier() *jsonapi.ResourceIdentifierObject {
+	id := &jsonapi.ResourceIdentifierObject{
+		Type: "project.timesheet",
+	}
+	if t.ID != 0 {
+		id.ID = strconv.Itoa(t.ID)
+	}
+	return id
+}
+
+func (t *Timesheet) GetVersion() int {
+	return t.Version
+}
+
+// Validate checks the week and requires a comment for rejections.
+func (t *Timesheet) Validate() error {
+	var errs validate.Errors
+	if _, err := ParseWeek(t.Week, time.UTC); err != nil {
+		errs = append(errs, validate.Attribute("week", "week", "must be an ISO week like 2025-W10"))
+	}
+	if t.State == TimesheetRejected && (t.Comment == nil || *t.Comment == "") {
+		errs = append(errs, validate.Attribute("comment", "required", "must not be empty if the timesheet is rejected"))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// locks reports whether the slots of the timesheet cannot be changed.
+func (t *Timesheet) locks() bool {
+	return t.State == TimesheetSubmitted || t.State == TimesheetApproved
+}
+
+// commentText returns the comment, empty if there is none.
+func (t *Timesheet) commentText() string {
+	if t.Comment == nil {
+		return ""
+	}
+	return *t.Comment
+}
+
+type TimesheetFilter struct {
+	Week  *string         `form:"filter[week]" query:"week,eq"`
+	State *TimesheetState `form:"filter[state]" query:"state,eq"`
+}
+
+var (
+	ErrTimesheetExists       = errors.New("timesheet of week exists")
+	ErrTimesheetWeekChanged  = errors.New("week of timesheet cannot be changed")
+	ErrTimesheetTransition   = errors.New("timesheet state cannot change")
+	ErrTimesheetHasOpenSlot  = errors.New("timesheet has slot without end")
+	ErrTimesheetNotDeletable = errors.New("submitted or approved timesheet cannot be deleted")
+	errInvalidWeek           = errors.New("invalid week")
+)
+
+// ParseWeek returns the start of the ISO week, e.g. 2025-W10, which is Monday 00:00 in zone.
+func ParseWeek(week string, zone *time.Location) (time.Time, error) {
+	var year, number int
+	if _, err := fmt.Sscanf(week, "%4d-W%2d", &year, &number); err != nil || len(week) != 8 {
+		return time.Time{}, fmt.Errorf("%w %q", errInvalidWeek, week)
+	}
+	// January 4th is always in the first week
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, zone)
+	start := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7+(number-1)*7)
+	if y, w := start.ISOWeek(); y != year || w != number {
+		return time.Time{}, fmt.Errorf("%w %q", errInvalidWeek, week)
+	}
+	return start, nil
+}
+
+// WeekOf returns the ISO week of t in zone.
+func WeekOf(t time.Time, zone *time.Location) string {
+	year, week := t.In(zone).ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+type TimesheetService interface {
+	Save(tx db.Transaction, timesheet *Timesheet) error
+	GetAll(tx db.Transaction, page *pagination.Page, filter *TimesheetFilter) ([]*Timesheet, error)
+	GetByID(tx db.Transaction, id int) (*Timesheet, error)
+	Delete(tx db.Transaction, id int) error
+	Restore(tx db.Transaction, timesheet *Timesheet) error
+	SlotLock
+}
+
+type TimesheetRepo interface {
+	db.CRUDRepository[*Timesheet, *TimesheetFilter]
+	GetByWeek(tx db.Transaction, week string) (*Timesheet, error)
+}
+
+// NewTimesheetService reads the slots of the weeks from slotRepo, the weeks start on Monday in zone.
+func NewTimesheetService(repo TimesheetRepo, slotRepo SlotRepo, zone *time.Location) TimesheetService {
+	return &timesheetService{repo: repo, slotRepo: slotRepo, zone: zone, now: time.Now}
+}
+
+type timesheetService struct {
+	repo     TimesheetRepo
+	slotRepo SlotRepo
+	zone     *time.Location
+	now      func() time.Time
+}
+
+// Save creates an open timesheet or changes the state of an existing one, an approved timesheet cannot
+// be changed anymore. Submitting needs all slots of the week to be ended, the times of the transitions
+// are recorded.
+func (s *timesheetService) Save(tx db.Transaction, timesheet *Timesheet) error {
+	if timesheet.ID == 0 {
+		if timesheet.State != "" && timesheet.State != TimesheetOpen {
+			return fmt.Errorf("%w: new timesheet must be open", ErrTimesheetTransition)
+		}
+		existing, err := s.repo.GetByWeek(tx, timesheet.Week)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return ErrTimesheetExists
+		}
+		timesheet.State = TimesheetOpen
+		timesheet.SubmittedAt, timesheet.DecidedAt = nil, nil
+		if err := s.repo.Save(tx, timesheet); err != nil {
+			return err
+		}
+		return s.computeTotals(tx, timesheet)
+	}
+	current, err := s.repo.GetByID(tx, timesheet.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return db.ErrNotFound
+	}
+	if timesheet.Week != current.Week {
+		return ErrTimesheetWeekChanged
+	}
+	if current.State == TimesheetApproved {
+		return fmt.Errorf("%w: approved timesheet is final", ErrTimesheetTransition)
+	}
+	timesheet.SubmittedAt, timesheet.DecidedAt = current.SubmittedAt, current.DecidedAt
+	if timesheet.State != current.State {
+		if !canTransition(current.State, timesheet.State) {
+			return fmt.Errorf("%w from %s to %s", ErrTimesheetTransition, current.State, timesheet.State)
+		}
+		now := s.now().UTC().Truncate(time.Second)
+		switch timesheet.State {
+		case TimesheetSubmitted:
+			if err := s.checkSlotsEnded(tx, timesheet); err != nil {
+				return err
+			}
+			timesheet.SubmittedAt, timesheet.DecidedAt = &now, nil
+		case TimesheetApproved, TimesheetRejected:
+			timesheet.DecidedAt = &now
+		}
+	}
+	if err := s.repo.Save(tx, timesheet); err != nil {
+		return err
+	}
+	return s.computeTotals(tx, timesheet)
+}
+
+func canTransition(from, to TimesheetState) bool {
+	for _, state := range timesheetTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *timesheetService) GetAll(tx db.Transaction, page *pagination.Page, filter *TimesheetFilter) ([]*Timesheet, error) {
+	timesheets, err := s.repo.GetAll(tx, page, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(timesheets) == 0 {
+		return timesheets, nil
+	}
+	// the slots of all weeks of the page are loaded at once, ISO weeks sort like their start
+	first, last := timesheets[0].Week, timesheets[0].Week
+	for _, timesheet := range timesheets[1:] {
+		first, last = min(first, timesheet.Week), max(last, timesheet.Week)
+	}
+	start, err := ParseWeek(first, s.zone)
+	if err != nil {
+		return nil, err
+	}
+	end, err := ParseWeek(last, s.zone)
+	if err != nil {
+		return nil, err
+	}
+	slots, err := slotsBetween(tx, s.slotRepo, start, end.AddDate(0, 0, 7))
+	if err != nil {
+		return nil, err
+	}
+	for _, timesheet := range timesheets {
+		if err := s.setTotals(timesheet, slots); err != nil {
+			return nil, err
+		}
+	}
+	return timesheets, nil
+}
+
+func (s *timesheetService) GetByID(tx db.Transaction, id int) (*Timesheet, error) {
+	timesheet, err := s.repo.GetByID(tx, id)
+	if err != nil || timesheet == nil {
+		return nil, err
+	}
+	return timesheet, s.computeTotals(tx, timesheet)
+}
+
+// Delete removes an open or rejected timesheet, the slots of the week stay.
+func (s *timesheetService) Delete(tx db.Transaction, id int) error {
+	timesheet, err := s.repo.GetByID(tx, id)
+	if err != nil {
+		return err
+	}
+	if timesheet == nil {
+		return db.ErrNotFound
+	}
+	if timesheet.locks() {
+		return ErrTimesheetNotDeletable
+	}
+	return s.repo.Delete(tx, id)
+}
+
+// Restore creates an imported timesheet in its state, the slots of its week have to be imported before.
+func (s *timesheetService) Restore(tx db.Transaction, timesheet *Timesheet) error {
+	if err := s.repo.Save(tx, timesheet); err != nil {
+		return err
+	}
+	return s.computeTotals(tx, timesheet)
+}
+
+// CheckSlot rejects changes of slots in the week of a submitted or approved timesheet.
+func (s *timesheetService) CheckSlot(tx db.Transaction, slot *Slot) error {
+	week := WeekOf(slot.Start, s.zone)
+	timesheet, err := s.repo.GetByWeek(tx, week)
+	if err != nil {
+		return err
+	}
+	if timesheet != nil && timesheet.locks() {
+		return fmt.Errorf("%w: timesheet %s is %s", ErrSlotLocked, week, timesheet.State)
+	}
+	return nil
+}
+
+func (s *timesheetService) checkSlotsEnded(tx db.Transaction, timesheet *Timesheet) error {
+	start, err := ParseWeek(timesheet.Week, s.zone)
+	if err != nil {
+		return err
+	}
+	isOpen := true
+	openSlots, err := s.slotRepo.GetAll(tx, &pagination.Page{Limit: -1}, &SlotFilter{IsOpen: &isOpen})
+	if err != nil {
+		return err
+	}
+	end := start.AddDate(0, 0, 7)
+	for _, slot := range openSlots {
+		if !slot.Start.Before(start) && slot.Start.Before(end) {
+			return fmt.Errorf("%w: slot %d", ErrTimesheetHasOpenSlot, slot.ID)
+		}
+	}
+	return nil
+}
+
+// computeTotals sets the dates and the work per day and project of the week of timesheet.
+func (s *timesheetService) computeTotals(tx db.Transaction, timesheet *Timesheet) error {
+	start, err := ParseWeek(timesheet.Week, s.zone)
+	if err != nil {
+		return err
+	}
+	slots, err := slotsBetween(tx, s.slotRepo, start, start.AddDate(0, 0, 7))
+	if err != nil {
+		return err
+	}
+	return s.setTotals(timesheet, slots)
+}
+
+// setTotals is computeTotals with the slots already loaded, slots of other weeks are skipped.
+func (s *timesheetService) setTotals(timesheet *Timesheet, slots []*Slot) error {
+	start, err := ParseWeek(timesheet.Week, s.zone)
+	if err != nil {
+		return err
+	}
+	end := start.AddDate(0, 0, 7)
+	timesheet.Start = start.Format(time.DateOnly)
+	timesheet.End = end.AddDate(0, 0, -1).Format(time.DateOnly)
+	days := make([]*TimesheetDay, 7)
+	byDate := make(map[string]*TimesheetDay, 7)
+	for i := range days {
+		days[i] = &TimesheetDay{Date: start.AddDate(0, 0, i).Format(time.DateOnly), Projects: []*TimesheetProject{}}
+		byDate[days[i].Date] = days[i]
+	}
+	timesheet.TotalMinutes = 0
+	for _, slot := range slots {
+		if slot.Activity != ActivityWork || slot.End == nil || slot.Start.Before(start) || !slot.Start.Before(end) {
+			continue
+		}
+		minutes := int(slot.End.Sub(slot.Start).Minutes())
+		day := byDate[slot.Start.In(s.zone).Format(time.DateOnly)]
+		day.Minutes += minutes
+		day.addProject(slot.ProjectID, minutes)
+		timesheet.TotalMinutes += minutes
+	}
+	timesheet.Days = days
+	return nil
+}
+
+func (d *TimesheetDay) addProject(projectID, minutes int) {
+	for _, project := range d.Projects {
+		if project.ProjectID == projectID {
+			project.Minutes += minutes
+			return
+		}
+	}
+	d.Projects = append(d.Projects, &TimesheetProject{ProjectID: projectID, Minutes: minutes})
+	sort.Slice(d.Projects, func(i, j int) bool {
+		return d.Projects[i].ProjectID < d.Projects[j].ProjectID
+	})
+}
+
+// slotsBetween returns the ended slots starting in [start, end). The filter of the repository compares
+// dates in UTC, so it selects a day more on both sides and the slots are cut to the range here.
+func slotsBetween(tx db.Transaction, repo SlotRepo, start, end time.Time) ([]*Slot, error) {
+	from := start.UTC().AddDate(0, 0, -1)
+	until := end.UTC().AddDate(0, 0, 1)
+	slots, err := repo.GetAll(tx, &pagination.Page{Limit: -1}, &SlotFilter{
+		From:            &from,
+		FromComparator:  CompareOperatorGreaterThanOrEqual,
+		Until:           &until,
+		UntilComparator: CompareOperatorLessThanOrEqual,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Slot, 0, len(slots))
+	for _, slot := range slots {
+		if !slot.Start.Before(start) && slot.Start.Before(end) {
+			result = append(result, slot)
+		}
+	}
+	return result, nil
+}
+
+type TimesheetRepository struct{}
+
+var timesheetQuery = &db.Query{
+	Table:   "timesheet",
+	Columns: []string{"id", "week", "state", "comment", "submitted_at", "decided_at", "version"},
+	SortFields: map[string]string{
+		"id":    "id",
+		"week":  "week",
+		"state": "state",
+	},
+}
+
+func NewTimesheetRepository() TimesheetRepo {
+	return &TimesheetRepository{}
+}
+
+func (r *TimesheetRepository) Save(tx db.Transaction, item *Timesheet) error {
+	if item.ID == 0 {
+		stmt := `INSERT INTO timesheet (week, state, comment, submitted_at, decided_at)
+				  VALUES (:week, :state, :comment, :submittedAt, :decidedAt)`
+
+		result, err := tx.Exec(stmt, item)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		item.ID = int(id)
+		item.Version = 1
+		return nil
+	}
+	stmt := `UPDATE timesheet
+			    SET
+			        state        = :state,
+			        comment      = :comment,
+			        submitted_at = :submittedAt,
+			        decided_at   = :decidedAt,
+			        version      = version + 1
+			  WHERE
+			        id = :id
+			    AND version = :version`
+
+	result, err := tx.Exec(stmt, item)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return db.UpdateError(tx, "timesheet", item.ID)
+	}
+	item.Version++
+	return nil
+}
+
+func (r *TimesheetRepository) GetByID(tx db.Transaction, id int) (*Timesheet, error) {
+	return r.getOne(tx, "id = :id", map[string]any{"id": id})
+}
+
+func (r *TimesheetRepository) GetByWeek(tx db.Transaction, week string) (*Timesheet, error) {
+	return r.getOne(tx, "week = :week", map[string]any{"week": week})
+}
+
+func (r *TimesheetRepository) getOne(tx db.Transaction, where string, params map[string]any) (*Timesheet, error) {
+	item := &Timesheet{}
+	stmt := `SELECT id, week, state, comment, submitted_at, decided_at, version
+			   FROM timesheet
+			  WHERE ` + where
+
+	if err := tx.Select(item, stmt, params); err != nil {
+		return nil, err
+	}
+	if item.ID == 0 {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (r *TimesheetRepository) GetAll(tx db.Transaction, page *pagination.Page, filter *TimesheetFilter) ([]*Timesheet, error) {
+	items := make([]*Timesheet, 0, 10)
+	if err := timesheetQuery.GetAll(tx, &items, page, filter); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *TimesheetRepository) Delete(tx db.Transaction, id int) error {
+	stmt := `DELETE
+               FROM timesheet
+              WHERE id = :id`
+
+	result, err := tx.Exec(stmt, map[string]any{"id": id})
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
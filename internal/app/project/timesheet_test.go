@@ -0,0 +1,439 @@
+package project
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/go-libs/testhelper"
+	"github.com/vloryan/protrakgon/internal/app/server"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+var cet = time.FixedZone("CET", 3600)
+
+type inMemTimesheetRepository struct {
+	Timesheets []*Timesheet
+}
+
+func (r *inMemTimesheetRepository) Save(_ db.Transaction, item *Timesheet) error {
+	if item.ID == 0 {
+		item.ID = len(r.Timesheets) + 1
+		r.Timesheets = append(r.Timesheets, item)
+		return nil
+	}
+	for i, timesheet := range r.Timesheets {
+		if timesheet.ID == item.ID {
+			r.Timesheets[i] = item
+		}
+	}
+	return nil
+}
+
+func (r *inMemTimesheetRepository) GetByID(_ db.Transaction, id int) (*Timesheet, error) {
+	for _, timesheet := range r.Timesheets {
+		if timesheet.ID == id {
+			copied := *timesheet
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *inMemTimesheetRepository) GetByWeek(_ db.Transaction, week string) (*Timesheet, error) {
+	for _, timesheet := range r.Timesheets {
+		if timesheet.Week == week {
+			copied := *timesheet
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *inMemTimesheetRepository) GetAll(_ db.Transaction, _ *pagination.Page, _ *TimesheetFilter) ([]*Timesheet, error) {
+	return r.Timesheets, nil
+}
+
+func (r *inMemTimesheetRepository) Delete(_ db.Transaction, id int) error {
+	for i, timesheet := range r.Timesheets {
+		if timesheet.ID == id {
+			r.Timesheets = append(r.Timesheets[:i], r.Timesheets[i+1:]...)
+			return nil
+		}
+	}
+	return db.ErrNotFound
+}
+
+func buildTimesheetScenario(timesheets []*Timesheet, slots []*Slot) *timesheetService {
+	return &timesheetService{
+		repo:     &inMemTimesheetRepository{Timesheets: timesheets},
+		slotRepo: &inMemSlotRepository{Slots: slots},
+		zone:     cet,
+		now: func() time.Time {
+			return testhelper.FixedNow
+		},
+	}
+}
+
+func TestParseWeek(t *testing.T) {
+	tests := []struct {
+		name    string
+		week    string
+		want    time.Time
+		wantErr bool
+	}{{
+		name: "GIVEN week THEN return its monday",
+		week: "2025-W10",
+		want: time.Date(2025, time.March, 3, 0, 0, 0, 0, cet),
+	}, {
+		name: "GIVEN first week starting in previous year THEN return its monday",
+		week: "2025-W01",
+		want: time.Date(2024, time.December, 30, 0, 0, 0, 0, cet),
+	}, {
+		name: "GIVEN week 53 of long year THEN return its monday",
+		week: "2020-W53",
+		want: time.Date(2020, time.December, 28, 0, 0, 0, 0, cet),
+	}, {
+		name:    "GIVEN week 53 of short year THEN fail",
+		week:    "2021-W53",
+		wantErr: true,
+	}, {
+		name:    "GIVEN date THEN fail",
+		week:    "2025-03-03",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWeek(tt.week, cet)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWeek() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseWeek() = %v, want %v", got, tt.want)
+			}
+			if !tt.wantErr && WeekOf(got, cet) != tt.week {
+				t.Errorf("WeekOf() = %s, want %s", WeekOf(got, cet), tt.week)
+			}
+		})
+	}
+}
+
+func TestTimesheetService_Save(t *testing.T) {
+	now := testhelper.FixedNow
+	openSlotInWeek := &Slot{ID: 1, ProjectID: defaultProjectID, Activity: ActivityWork, Start: now}
+	tests := []struct {
+		name       string
+		timesheets []*Timesheet
+		slots      []*Slot
+		timesheet  *Timesheet
+		want       *Timesheet
+		wantErr    error
+	}{{
+		name:      "GIVEN new timesheet THEN save it open",
+		timesheet: &Timesheet{Week: "2025-W03"},
+		want:      &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetOpen, Start: "2025-01-13", End: "2025-01-19"},
+	}, {
+		name:       "GIVEN timesheet of existing week THEN throw ErrTimesheetExists",
+		timesheets: []*Timesheet{{ID: 1, Week: "2025-W03", State: TimesheetOpen}},
+		timesheet:  &Timesheet{Week: "2025-W03"},
+		wantErr:    ErrTimesheetExists,
+	}, {
+		name:      "GIVEN new submitted timesheet THEN throw ErrTimesheetTransition",
+		timesheet: &Timesheet{Week: "2025-W03", State: TimesheetSubmitted},
+		wantErr:   ErrTimesheetTransition,
+	}, {
+		name:       "GIVEN submission THEN record time",
+		timesheets: []*Timesheet{{ID: 1, Week: "2025-W03", State: TimesheetOpen}},
+		timesheet:  &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetSubmitted},
+		want: &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetSubmitted, SubmittedAt: &now,
+			Start: "2025-01-13", End: "2025-01-19"},
+	}, {
+		name:       "GIVEN submission with open slot in week THEN throw ErrTimesheetHasOpenSlot",
+		timesheets: []*Timesheet{{ID: 1, Week: "2025-W03", State: TimesheetOpen}},
+		slots:      []*Slot{openSlotInWeek},
+		timesheet:  &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetSubmitted},
+		wantErr:    ErrTimesheetHasOpenSlot,
+	}, {
+		name:       "GIVEN submission with open slot in other week THEN submit",
+		timesheets: []*Timesheet{{ID: 1, Week: "2025-W02", State: TimesheetOpen}},
+		slots:      []*Slot{openSlotInWeek},
+		timesheet:  &Timesheet{ID: 1, Week: "2025-W02", State: TimesheetSubmitted},
+		want: &Timesheet{ID: 1, Week: "2025-W02", State: TimesheetSubmitted, SubmittedAt: &now,
+			Start: "2025-01-06", End: "2025-01-12"},
+	}, {
+		name:       "GIVEN approval of open timesheet THEN throw ErrTimesheetTransition",
+		timesheets: []*Timesheet{{ID: 1, Week: "2025-W03", State: TimesheetOpen}},
+		timesheet:  &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetApproved},
+		wantErr:    ErrTimesheetTransition,
+	}, {
+		name:       "GIVEN rejection THEN record decision and keep submission",
+		timesheets: []*Timesheet{{ID: 1, Week: "2025-W03", State: TimesheetSubmitted, SubmittedAt: testhelper.Ptr(now.Add(-time.Hour))}},
+		timesheet:  &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetRejected, Comment: testhelper.Ptr("friday is missing")},
+		want: &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetRejected, Comment: testhelper.Ptr("friday is missing"),
+			SubmittedAt: testhelper.Ptr(now.Add(-time.Hour)), DecidedAt: &now, Start: "2025-01-13", End: "2025-01-19"},
+	}, {
+		name:       "GIVEN change of approved timesheet THEN throw ErrTimesheetTransition",
+		timesheets: []*Timesheet{{ID: 1, Week: "2025-W03", State: TimesheetApproved}},
+		timesheet:  &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetOpen},
+		wantErr:    ErrTimesheetTransition,
+	}, {
+		name:       "GIVEN comment of approved timesheet THEN throw ErrTimesheetTransition",
+		timesheets: []*Timesheet{{ID: 1, Week: "2025-W03", State: TimesheetApproved}},
+		timesheet:  &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetApproved, Comment: testhelper.Ptr("changed")},
+		wantErr:    ErrTimesheetTransition,
+	}, {
+		name:       "GIVEN changed week THEN throw ErrTimesheetWeekChanged",
+		timesheets: []*Timesheet{{ID: 1, Week: "2025-W03", State: TimesheetOpen}},
+		timesheet:  &Timesheet{ID: 1, Week: "2025-W04", State: TimesheetOpen},
+		wantErr:    ErrTimesheetWeekChanged,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := buildTimesheetScenario(tt.timesheets, tt.slots)
+			err := s.Save(nil, tt.timesheet)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Save() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				if diff := cmp.Diff(tt.want, tt.timesheet, cmpopts.IgnoreFields(Timesheet{}, "Days")); diff != "" {
+					t.Errorf("Save() mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestTimesheetService_GetByID(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, time.January, day, hour, minute, 0, 0, time.UTC)
+	}
+	s := buildTimesheetScenario([]*Timesheet{{ID: 1, Week: "2025-W03", State: TimesheetOpen}}, []*Slot{
+		{ID: 1, ProjectID: 1, Activity: ActivityWork, Start: at(13, 8, 0), End: testhelper.Ptr(at(13, 10, 0))},
+		// monday 00:30 in the zone of the timesheet
+		{ID: 2, ProjectID: 2, Activity: ActivityWork, Start: at(12, 23, 30), End: testhelper.Ptr(at(12, 23, 59))},
+		{ID: 3, ProjectID: 1, Activity: ActivityWork, Start: at(13, 12, 0), End: testhelper.Ptr(at(13, 12, 30))},
+		{ID: 4, ProjectID: 1, Activity: ActivityBreak, Start: at(14, 12, 0), End: testhelper.Ptr(at(14, 13, 0))},
+		{ID: 5, ProjectID: 1, Activity: ActivityWork, Start: at(15, 9, 0)},
+		// monday of the next week in the zone of the timesheet
+		{ID: 6, ProjectID: 1, Activity: ActivityWork, Start: at(19, 23, 15), End: testhelper.Ptr(at(19, 23, 45))},
+		{ID: 7, ProjectID: 1, Activity: ActivityWork, Start: at(19, 8, 0), End: testhelper.Ptr(at(19, 9, 0))},
+	})
+	empty := func(date string) *TimesheetDay {
+		return &TimesheetDay{Date: date, Projects: []*TimesheetProject{}}
+	}
+	want := &Timesheet{
+		ID:    1,
+		Week:  "2025-W03",
+		State: TimesheetOpen,
+		Start: "2025-01-13",
+		End:   "2025-01-19",
+		Days: []*TimesheetDay{
+			{Date: "2025-01-13", Minutes: 179, Projects: []*TimesheetProject{{ProjectID: 1, Minutes: 150}, {ProjectID: 2, Minutes: 29}}},
+			empty("2025-01-14"),
+			empty("2025-01-15"),
+			empty("2025-01-16"),
+			empty("2025-01-17"),
+			empty("2025-01-18"),
+			{Date: "2025-01-19", Minutes: 60, Projects: []*TimesheetProject{{ProjectID: 1, Minutes: 60}}},
+		},
+		TotalMinutes: 239,
+	}
+
+	got, err := s.GetByID(nil, 1)
+
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetByID() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// countingSlotRepository counts the queries of the slots.
+type countingSlotRepository struct {
+	*inMemSlotRepository
+	Queries int
+}
+
+func (r *countingSlotRepository) GetAll(tx db.Transaction, page *pagination.Page, filter *SlotFilter) ([]*Slot, error) {
+	r.Queries++
+	return r.inMemSlotRepository.GetAll(tx, page, filter)
+}
+
+func TestTimesheetService_GetAll(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2025, time.January, day, hour, 0, 0, 0, time.UTC)
+	}
+	s := buildTimesheetScenario([]*Timesheet{
+		{ID: 1, Week: "2025-W04", State: TimesheetOpen},
+		{ID: 2, Week: "2025-W02", State: TimesheetApproved},
+	}, nil)
+	slotRepo := &countingSlotRepository{inMemSlotRepository: &inMemSlotRepository{Slots: []*Slot{
+		{ID: 1, ProjectID: 1, Activity: ActivityWork, Start: at(6, 8), End: testhelper.Ptr(at(6, 10))},
+		// week 3 is between the weeks of the page
+		{ID: 2, ProjectID: 1, Activity: ActivityWork, Start: at(14, 8), End: testhelper.Ptr(at(14, 9))},
+		{ID: 3, ProjectID: 2, Activity: ActivityWork, Start: at(20, 8), End: testhelper.Ptr(at(20, 11))},
+	}}}
+	s.slotRepo = slotRepo
+	type totals struct {
+		Week         string
+		TotalMinutes int
+	}
+	want := []totals{{Week: "2025-W04", TotalMinutes: 180}, {Week: "2025-W02", TotalMinutes: 120}}
+
+	timesheets, err := s.GetAll(nil, &pagination.Page{}, &TimesheetFilter{})
+
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	var got []totals
+	for _, timesheet := range timesheets {
+		got = append(got, totals{Week: timesheet.Week, TotalMinutes: timesheet.TotalMinutes})
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetAll() mismatch (-want +got):\n%s", diff)
+	}
+	if slotRepo.Queries != 1 {
+		t.Errorf("GetAll() queried slots %d times, want once", slotRepo.Queries)
+	}
+}
+
+func TestNewTimesheetHandler_BeforeSave(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		state      TimesheetState
+		timesheet  *Timesheet
+		wantStatus int
+	}{{
+		name:      "GIVEN submission without token THEN accept",
+		state:     TimesheetOpen,
+		timesheet: &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetSubmitted},
+	}, {
+		name:       "GIVEN approval without token THEN reject with admin required",
+		state:      TimesheetSubmitted,
+		timesheet:  &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetApproved},
+		wantStatus: http.StatusUnauthorized,
+	}, {
+		name:       "GIVEN rejection with other token THEN reject with admin required",
+		token:      "guess",
+		state:      TimesheetSubmitted,
+		timesheet:  &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetRejected, Comment: testhelper.Ptr("friday is missing")},
+		wantStatus: http.StatusUnauthorized,
+	}, {
+		name:      "GIVEN approval with admin token THEN accept",
+		token:     "secret",
+		state:     TimesheetSubmitted,
+		timesheet: &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetApproved},
+	}, {
+		name:       "GIVEN comment of rejected timesheet without token THEN reject with admin required",
+		state:      TimesheetRejected,
+		timesheet:  &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetRejected, Comment: testhelper.Ptr("fixed friday")},
+		wantStatus: http.StatusUnauthorized,
+	}, {
+		name:       "GIVEN comment of open timesheet without token THEN reject with admin required",
+		state:      TimesheetOpen,
+		timesheet:  &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetOpen, Comment: testhelper.Ptr("note")},
+		wantStatus: http.StatusUnauthorized,
+	}, {
+		name:      "GIVEN comment of rejected timesheet with admin token THEN accept",
+		token:     "secret",
+		state:     TimesheetRejected,
+		timesheet: &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetRejected, Comment: testhelper.Ptr("fixed friday")},
+	}, {
+		name:      "GIVEN submission with comment without token THEN accept",
+		state:     TimesheetRejected,
+		timesheet: &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetSubmitted, Comment: testhelper.Ptr("fixed friday")},
+	}, {
+		name:      "GIVEN unchanged rejected timesheet without token THEN accept",
+		state:     TimesheetRejected,
+		timesheet: &Timesheet{ID: 1, Week: "2025-W03", State: TimesheetRejected},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := buildTimesheetScenario([]*Timesheet{{ID: 1, Week: "2025-W03", State: tt.state}}, nil)
+			h := NewTimesheetHandler(s, cet, "secret")
+			req := httptest.NewRequest(http.MethodPatch, "/project/timesheet/1", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			err := h.BeforeSave(req, nil, tt.timesheet)
+
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Errorf("BeforeSave() error = %v", err)
+				}
+				return
+			}
+			var apiErr *server.Error
+			if !errors.As(err, &apiErr) || apiErr.Status != tt.wantStatus {
+				t.Errorf("BeforeSave() error = %v, want status %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestTimesheetService_CheckSlot(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   TimesheetState
+		start   time.Time
+		wantErr error
+	}{{
+		name:    "GIVEN slot in submitted week THEN throw ErrSlotLocked",
+		state:   TimesheetSubmitted,
+		start:   time.Date(2025, time.January, 12, 23, 30, 0, 0, time.UTC),
+		wantErr: ErrSlotLocked,
+	}, {
+		name:    "GIVEN slot in approved week THEN throw ErrSlotLocked",
+		state:   TimesheetApproved,
+		start:   time.Date(2025, time.January, 15, 9, 0, 0, 0, time.UTC),
+		wantErr: ErrSlotLocked,
+	}, {
+		name:  "GIVEN slot in next week of the zone THEN accept",
+		state: TimesheetApproved,
+		start: time.Date(2025, time.January, 19, 23, 30, 0, 0, time.UTC),
+	}, {
+		name:  "GIVEN slot in rejected week THEN accept",
+		state: TimesheetRejected,
+		start: time.Date(2025, time.January, 15, 9, 0, 0, 0, time.UTC),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := buildTimesheetScenario([]*Timesheet{{ID: 1, Week: "2025-W03", State: tt.state}}, nil)
+			err := s.CheckSlot(nil, &Slot{ProjectID: defaultProjectID, Start: tt.start})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckSlot() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTimesheetService_Delete(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   TimesheetState
+		wantErr error
+	}{{
+		name:  "GIVEN rejected timesheet THEN delete it",
+		state: TimesheetRejected,
+	}, {
+		name:    "GIVEN approved timesheet THEN throw ErrTimesheetNotDeletable",
+		state:   TimesheetApproved,
+		wantErr: ErrTimesheetNotDeletable,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := buildTimesheetScenario([]*Timesheet{{ID: 1, Week: "2025-W03", State: tt.state}}, nil)
+			if err := s.Delete(nil, 1); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Delete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
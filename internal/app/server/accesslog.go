@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/request"
+)
+
+// RequestIDHeader carries the id of a request. A valid id of the client is kept, otherwise one is generated.
+// The id is returned in the response and logged with every message of the request.
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// WithSlowQueryThreshold logs statements taking at least threshold as warning, 0 disables it.
+func (svr *Server) WithSlowQueryThreshold(threshold time.Duration) *Server {
+	svr.slowQuery = threshold
+	return svr
+}
+
+// WithTracer exports the spans of requests and their statements with tracer.
+func (svr *Server) WithTracer(tracer *Tracer) *Server {
+	svr.tracer = tracer
+	return svr
+}
+
+// accessLog assigns the request id, attaches a logger with the id to the context of the request, starts
+// its span and logs the request when it is done.
+func (svr *Server) accessLog(next http.Handler) http.Handler {
+	quiet := map[string]bool{}
+	for _, p := range []string{"healthz", "readyz", "metrics"} {
+		quiet[path.Join(svr.ContextRoot, p)] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		id := req.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		logger := log.With().Str("requestId", id).Logger()
+		ctx := logger.WithContext(req.Context())
+		var span *Span
+		if svr.tracer != nil {
+			span = svr.tracer.StartRequest(req)
+			span.Attributes["http.request.id"] = id
+			ctx = context.WithValue(ctx, spanContextKey{}, span)
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, req.WithContext(ctx))
+
+		duration := time.Since(start)
+		if span != nil {
+			span.Attributes["http.response.status_code"] = rec.Status()
+			span.Failed = rec.Status() >= http.StatusInternalServerError
+			svr.tracer.Finish(span, start.Add(duration))
+		}
+		level := zerolog.InfoLevel
+		if quiet[req.URL.Path] {
+			level = zerolog.DebugLevel
+		}
+		logger.WithLevel(level).
+			Str("method", req.Method).
+			Str("path", req.URL.Path).
+			Int("status", rec.Status()).
+			Int("size", rec.size).
+			Dur("duration", duration).
+			Str("remote", req.RemoteAddr).
+			Str("userAgent", req.UserAgent()).
+			Msg("request")
+	})
+}
+
+// withDatabase adds the database to the context of the request. Its statements are logged with the logger
+// of the request, recorded as metrics and, with tracing, exported as spans of the request.
+func (svr *Server) withDatabase(database *db.StatementLogger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		requestDB := database.WithLogger(zerolog.Ctx(ctx))
+		if span := SpanFromContext(ctx); span != nil {
+			requestDB = requestDB.WithObserver(func(operation, query string, duration time.Duration) {
+				Monitor.ObserveQuery(operation, query, duration)
+				end := time.Now()
+				child := svr.tracer.StartChild(span, "db."+operation, spanKindClient, end.Add(-duration))
+				child.Attributes["db.system"] = "sqlite"
+				child.Attributes["db.statement"] = query
+				svr.tracer.Finish(child, end)
+			})
+		}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(ctx, request.CtxKeyDatabase, db.Connection(requestDB))))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
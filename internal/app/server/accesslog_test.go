@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/request"
+)
+
+func TestServer_accessLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		wantID    bool
+	}{{
+		name:      "GIVEN request id THEN keep it",
+		requestID: "client-42",
+		wantID:    true,
+	}, {
+		name:      "GIVEN invalid request id THEN generate one",
+		requestID: "a b",
+	}, {
+		name: "GIVEN no request id THEN generate one",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			defaultLogger := log.Logger
+			log.Logger = zerolog.New(&out)
+			defer func() { log.Logger = defaultLogger }()
+			srv := New()
+			handler := srv.accessLog(srv.withDatabase(db.WithStatementLogger(noTxConnection{}, nil), http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				_ = request.DB(req).Select(nil, "SELECT 1")
+				w.WriteHeader(http.StatusNoContent)
+			})))
+			req := httptest.NewRequest(http.MethodGet, "/v1/client", nil)
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(RequestIDHeader)
+			if tt.wantID && id != tt.requestID {
+				t.Errorf("accessLog() id = %s, want %s", id, tt.requestID)
+			}
+			if !tt.wantID && (id == tt.requestID || len(id) != 32) {
+				t.Errorf("accessLog() id = %s, want generated id", id)
+			}
+			var messages []string
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				entry := struct {
+					Message   string `json:"message"`
+					RequestID string `json:"requestId"`
+				}{}
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("accessLog() invalid log %s", line)
+				}
+				if entry.RequestID != id {
+					t.Errorf("accessLog() %s logged with id %s, want %s", entry.Message, entry.RequestID, id)
+				}
+				messages = append(messages, entry.Message)
+			}
+			if diff := cmp.Diff([]string{"Select", "request"}, messages); diff != "" {
+				t.Errorf("accessLog() messages mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// collector records the spans exported to it.
+type collector struct {
+	mu    sync.Mutex
+	spans []map[string]any
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	doc := struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []map[string]any `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}{}
+	_ = json.Unmarshal(body, &doc)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, resourceSpans := range doc.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			c.spans = append(c.spans, scopeSpans.Spans...)
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	c := &collector{}
+	otlp := httptest.NewServer(c)
+	defer otlp.Close()
+	srv := New().WithTracer(NewTracer(otlp.URL, "test"))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		srv.tracer.Run(ctx)
+		close(done)
+	}()
+	handler := srv.accessLog(srv.withDatabase(db.WithStatementLogger(noTxConnection{}, nil),
+		Monitor.Instrument("/v1/client/:clientID", func(_ http.ResponseWriter, req *http.Request) {
+			_ = request.DB(req).Select(nil, "SELECT * FROM client")
+		})))
+	req := httptest.NewRequest(http.MethodGet, "/v1/client/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	cancel()
+	<-done
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.spans) != 2 {
+		t.Fatalf("Run() exported %d spans, want 2", len(c.spans))
+	}
+	statement, server := c.spans[0], c.spans[1]
+	got := []any{server["name"], server["traceId"], server["parentSpanId"], statement["name"], statement["traceId"], statement["parentSpanId"]}
+	want := []any{"GET /v1/client/:clientID", "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", "db.select", "4bf92f3577b34da6a3ce929d0e0e4736", server["spanId"]}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Run() spans mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// AdminRequired reports a change only the admin may make, e.g. a decision, of a client without admin token.
+func AdminRequired() *Error {
+	return NewError(http.StatusUnauthorized, "admin_required", "needs the admin token")
+}
+
+// RequireAdmin returns a middleware rejecting requests without token as bearer token. Without token
+// the wrapped handlers answer 404, as if the admin endpoints did not exist.
+func RequireAdmin(token string) func(next http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			if token == "" {
+				writeErrorDocument(w, http.StatusNotFound, "admin endpoints are disabled", nil)
+				return
+			}
+			if !IsAdmin(req, token) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				writeErrorDocument(w, http.StatusUnauthorized, "invalid admin token", nil)
+				return
+			}
+			next(w, req)
+		}
+	}
+}
+
+// IsAdmin reports whether req sends token as bearer token, an empty token is never sent.
+func IsAdmin(req *http.Request, token string) bool {
+	bearer, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+const (
+	// ArchiveFormat identifies archives written by ExportArchive.
+	ArchiveFormat = "protrakgon"
+	// ArchiveFormatVersion is increased whenever the layout of archives changes incompatibly.
+	ArchiveFormatVersion = 1
+
+	archiveManifest = "manifest.json"
+)
+
+var (
+	// ErrInvalidArchive is returned for archives which cannot be imported by this version.
+	ErrInvalidArchive = errors.New("invalid archive")
+	// ErrDatabaseNotEmpty is returned if an archive is imported into a database with resources.
+	ErrDatabaseNotEmpty = errors.New("database is not empty")
+)
+
+// ArchiveManifest describes the content of an archive. SchemaVersion is the migration version of the
+// exported database, Resources lists the files in the order they have to be imported.
+type ArchiveManifest struct {
+	Format        string          `json:"format"`
+	FormatVersion int             `json:"formatVersion"`
+	SchemaVersion uint            `json:"schemaVersion"`
+	Created       time.Time       `json:"created"`
+	Resources     []*ArchiveEntry `json:"resources"`
+}
+
+// ArchiveEntry is the file of one resource type in an archive.
+type ArchiveEntry struct {
+	Type  string `json:"type"`
+	File  string `json:"file"`
+	Count int    `json:"count"`
+}
+
+// archiveDocument is the content of an archive file, the resource objects use their former id as lid
+// and reference related resources by lid, so they can be imported as add operations.
+type archiveDocument struct {
+	Data []*atomicData `json:"data"`
+}
+
+// ExportArchive writes all resources registered with RegisterAtomicResource as zip archive to w.
+// Every resource type is written as JSON document to its own file, manifest.json lists the files.
+func ExportArchive(tx db.Transaction, w io.Writer, schemaVersion uint) (*ArchiveManifest, error) {
+	manifest := &ArchiveManifest{
+		Format:        ArchiveFormat,
+		FormatVersion: ArchiveFormatVersion,
+		SchemaVersion: schemaVersion,
+		Created:       time.Now().UTC().Truncate(time.Second),
+	}
+	archive := zip.NewWriter(w)
+	for _, resourceType := range archiveTypes() {
+		items, err := atomicResources[resourceType].getAll(tx)
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", resourceType, err)
+		}
+		doc := &archiveDocument{Data: make([]*atomicData, 0, len(items))}
+		for _, item := range items {
+			data, err := archiveObject(resourceType, item)
+			if err != nil {
+				return nil, fmt.Errorf("export %s %s: %w", resourceType, item.GetIdentifier().ID, err)
+			}
+			doc.Data = append(doc.Data, data)
+		}
+		entry := &ArchiveEntry{Type: resourceType, File: resourceType + ".json", Count: len(doc.Data)}
+		if err := writeArchiveFile(archive, entry.File, doc); err != nil {
+			return nil, err
+		}
+		manifest.Resources = append(manifest.Resources, entry)
+	}
+	if err := writeArchiveFile(archive, archiveManifest, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, archive.Close()
+}
+
+// ImportArchive adds all resources of the archive r to the empty database of tx. The resources get new ids,
+// relationships are remapped to them. Archives of a newer format or schema version are rejected.
+func ImportArchive(tx db.Transaction, r io.ReaderAt, size int64, schemaVersion uint) (*ArchiveManifest, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	manifest := &ArchiveManifest{}
+	if err := readArchiveFile(archive, archiveManifest, manifest); err != nil {
+		return nil, err
+	}
+	switch {
+	case manifest.Format != ArchiveFormat:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidArchive, manifest.Format)
+	case manifest.FormatVersion > ArchiveFormatVersion:
+		return nil, fmt.Errorf("%w: format version %d is newer than the supported version %d", ErrInvalidArchive, manifest.FormatVersion, ArchiveFormatVersion)
+	case manifest.SchemaVersion > schemaVersion:
+		return nil, fmt.Errorf("%w: schema version %d is newer than the supported version %d", ErrInvalidArchive, manifest.SchemaVersion, schemaVersion)
+	}
+	for resourceType, resource := range atomicResources {
+		items, err := resource.getAll(tx)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) > 0 {
+			return nil, fmt.Errorf("%w: %s has %d resources", ErrDatabaseNotEmpty, resourceType, len(items))
+		}
+	}
+	operations := &AtomicOperations{restore: true}
+	lids := make(map[string]string)
+	for _, entry := range manifest.Resources {
+		doc := &archiveDocument{}
+		if err := readArchiveFile(archive, entry.File, doc); err != nil {
+			return nil, err
+		}
+		if len(doc.Data) != entry.Count {
+			return nil, fmt.Errorf("%w: %s has %d resources, manifest lists %d", ErrInvalidArchive, entry.File, len(doc.Data), entry.Count)
+		}
+		for _, data := range doc.Data {
+			if data.Type != entry.Type {
+				return nil, fmt.Errorf("%w: %s contains type %s", ErrInvalidArchive, entry.File, data.Type)
+			}
+			if _, err := operations.execute(nil, tx, &atomicOperation{Op: "add", Data: data}, lids); err != nil {
+				return nil, fmt.Errorf("import %s %s: %w", data.Type, data.Lid, err)
+			}
+		}
+	}
+	return manifest, nil
+}
+
+// archiveTypes returns the registered resource types, related types before the types referencing them
+// and the types to import after.
+func archiveTypes() []string {
+	names := make([]string, 0, len(atomicResources))
+	for name := range atomicResources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var ordered []string
+	visited := make(map[string]bool, len(names))
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		related := slices.Clone(atomicResources[name].importAfter)
+		for _, relation := range atomicResources[name].relations {
+			related = append(related, relation.Type)
+		}
+		sort.Strings(related)
+		for _, r := range related {
+			if _, ok := atomicResources[r]; ok {
+				visit(r)
+			}
+		}
+		ordered = append(ordered, name)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return ordered
+}
+
+// archiveObject converts item to a resource object of an add operation. The id becomes the lid and the
+// relations of resourceType become relationships referencing the lid of the related resource.
+func archiveObject(resourceType string, item Resource) (*atomicData, error) {
+	b, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	attributes := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &attributes); err != nil {
+		return nil, err
+	}
+	delete(attributes, "id")
+	data := &atomicData{Type: resourceType, Lid: item.GetIdentifier().ID, Attributes: attributes}
+	for name, relation := range atomicResources[resourceType].relations {
+		attribute := relation.Attribute
+		if attribute == "" {
+			attribute = name
+		}
+		value, ok := attributes[attribute]
+		if !ok {
+			continue
+		}
+		delete(attributes, attribute)
+		id, err := relatedID(value)
+		if err != nil {
+			return nil, fmt.Errorf("relationship %s: %w", name, err)
+		}
+		if data.Relationships == nil {
+			data.Relationships = make(map[string]atomicRelationship)
+		}
+		if id == "" {
+			data.Relationships[name] = atomicRelationship{}
+			continue
+		}
+		data.Relationships[name] = atomicRelationship{Data: &atomicRef{Type: relation.Type, Lid: id}}
+	}
+	return data, nil
+}
+
+// relatedID returns the id of a related resource, value is either the id or a nested object with the id.
+func relatedID(value json.RawMessage) (string, error) {
+	var id json.Number
+	if err := json.Unmarshal(value, &id); err == nil {
+		return string(id), nil
+	}
+	nested := struct {
+		ID json.Number `json:"id"`
+	}{}
+	if err := json.Unmarshal(value, &nested); err != nil {
+		return "", err
+	}
+	return string(nested.ID), nil
+}
+
+func writeArchiveFile(archive *zip.Writer, name string, v any) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func readArchiveFile(archive *zip.Reader, name string, v any) error {
+	file, err := archive.Open(name)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	defer func() { _ = file.Close() }()
+	if err := json.NewDecoder(file).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidArchive, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func registerTestItems(service *inMemItemService) {
+	RegisterAtomicResource[*testItem, any]("test.item", service, func() *testItem {
+		return &testItem{}
+	}, func(item *testItem) string {
+		return "test/" + strconv.Itoa(item.ID)
+	}, Relation{Name: "parent", Type: "test.item", Attribute: "parentId"})
+}
+
+func TestImportArchive(t *testing.T) {
+	exported := map[int]*testItem{
+		3: {ID: 3, Name: "root"},
+		7: {ID: 7, Name: "child", ParentID: 3},
+	}
+	tests := []struct {
+		name          string
+		items         map[int]*testItem
+		schemaVersion uint
+		wantItems     map[int]*testItem
+		wantErr       error
+	}{{
+		name:          "GIVEN empty database THEN import items with new ids",
+		items:         map[int]*testItem{},
+		schemaVersion: 4,
+		wantItems: map[int]*testItem{
+			1: {ID: 1, Name: "root", Version: 1},
+			2: {ID: 2, Name: "child", ParentID: 1, Version: 1},
+		},
+	}, {
+		name:          "GIVEN database with items THEN reject import",
+		items:         map[int]*testItem{1: {ID: 1, Name: "existing"}},
+		schemaVersion: 4,
+		wantItems:     map[int]*testItem{1: {ID: 1, Name: "existing"}},
+		wantErr:       ErrDatabaseNotEmpty,
+	}, {
+		name:          "GIVEN older schema version THEN reject import",
+		items:         map[int]*testItem{},
+		schemaVersion: 3,
+		wantItems:     map[int]*testItem{},
+		wantErr:       ErrInvalidArchive,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registerTestItems(&inMemItemService{items: exported})
+			var archive bytes.Buffer
+			manifest, err := ExportArchive(nil, &archive, 4)
+			if err != nil {
+				t.Fatalf("ExportArchive() error = %v", err)
+			}
+			if diff := cmp.Diff([]*ArchiveEntry{{Type: "test.item", File: "test.item.json", Count: 2}}, manifest.Resources); diff != "" {
+				t.Errorf("ExportArchive() resources mismatch (-want +got):\n%s", diff)
+			}
+
+			service := &inMemItemService{items: tt.items}
+			registerTestItems(service)
+			_, err = ImportArchive(nil, bytes.NewReader(archive.Bytes()), int64(archive.Len()), tt.schemaVersion)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ImportArchive() error = %v, want %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.wantItems, service.items); diff != "" {
+				t.Errorf("ImportArchive() items mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
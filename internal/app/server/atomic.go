@@ -0,0 +1,469 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/vloryan/go-libs/httpx/router"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/request"
+	"github.com/vloryan/protrakgon/internal/app/server/validate"
+)
+
+// AtomicMediaType is the media type of requests and responses of the JSON:API Atomic Operations extension.
+const AtomicMediaType = `application/vnd.api+json;ext="https://jsonapi.org/ext/atomic"`
+
+// Resource is implemented by all resource objects of the API.
+type Resource interface {
+	GetIdentifier() *jsonapi.ResourceIdentifierObject
+	SetIdentifier(id *jsonapi.ResourceIdentifierObject)
+}
+
+// Relation describes a relationship of a resource type.
+type Relation struct {
+	// Name of the relationship, e.g. "client".
+	Name string
+	// Type of the related resource.
+	Type string
+	// Attribute holding the id of the related resource, e.g. "projectId". Without attribute the related
+	// resource is a nested object with its id named like the relationship.
+	Attribute string
+}
+
+type atomicResource struct {
+	relations   map[string]Relation
+	newItem     func() Resource
+	getByID     func(tx db.Transaction, id int) (Resource, error)
+	save        func(tx db.Transaction, item Resource) error
+	delete      func(tx db.Transaction, id int) error
+	getAll      func(tx db.Transaction) ([]Resource, error)
+	selfLink    func(item Resource) string
+	authorize   func(req *http.Request, tx db.Transaction, item Resource) error
+	importAfter []string
+	restore     func(tx db.Transaction, item Resource) error
+}
+
+var atomicResources = make(map[string]*atomicResource)
+
+// RegisterAtomicResource makes resources of resourceType available to the atomic operations endpoint
+// and to archives. All operations are executed by service, newItem creates an empty resource for add operations.
+// The relations map relationships of operations to the resource and order the types of archives.
+func RegisterAtomicResource[T Resource, F any](resourceType string, service CrudService[T, F], newItem func() T, selfLink func(item T) string, relations ...Relation) {
+	byName := make(map[string]Relation, len(relations))
+	for _, relation := range relations {
+		byName[relation.Name] = relation
+	}
+	atomicResources[resourceType] = &atomicResource{
+		relations: byName,
+		newItem: func() Resource {
+			return newItem()
+		},
+		getByID: func(tx db.Transaction, id int) (Resource, error) {
+			item, err := service.GetByID(tx, id)
+			if err != nil || isNil(item) {
+				return nil, err
+			}
+			return item, nil
+		},
+		save: func(tx db.Transaction, item Resource) error {
+			return service.Save(tx, item.(T))
+		},
+		delete: service.Delete,
+		getAll: func(tx db.Transaction) ([]Resource, error) {
+			var filter F
+			items, err := service.GetAll(tx, &pagination.Page{Limit: -1}, filter)
+			if err != nil {
+				return nil, err
+			}
+			resources := make([]Resource, len(items))
+			for i, item := range items {
+				resources[i] = item
+			}
+			return resources, nil
+		},
+		selfLink: func(item Resource) string {
+			return selfLink(item.(T))
+		},
+	}
+}
+
+// AtomicOptions are the optional hooks of a resource type registered with RegisterAtomicResource.
+type AtomicOptions[T Resource] struct {
+	// Authorize is called before an operation of req saves or removes item, it has to check what the
+	// Authorize or BeforeSave hook of the CrudHandler of the type checks.
+	Authorize func(req *http.Request, tx db.Transaction, item T) error
+	// ImportAfter are the types imported from archives before the type, besides its relations, e.g.
+	// the slots a lock would reject.
+	ImportAfter []string
+	// Restore saves items imported from archives as they were exported, for services which set the
+	// state of new items themselves.
+	Restore func(tx db.Transaction, item T) error
+}
+
+// SetAtomicOptions sets the options of resourceType, which has to be registered with RegisterAtomicResource.
+func SetAtomicOptions[T Resource](resourceType string, options *AtomicOptions[T]) {
+	resource, ok := atomicResources[resourceType]
+	if !ok {
+		panic("atomic resource " + resourceType + " is not registered")
+	}
+	resource.authorize, resource.importAfter, resource.restore = nil, options.ImportAfter, nil
+	if options.Authorize != nil {
+		resource.authorize = func(req *http.Request, tx db.Transaction, item Resource) error {
+			return options.Authorize(req, tx, item.(T))
+		}
+	}
+	if options.Restore != nil {
+		resource.restore = func(tx db.Transaction, item Resource) error {
+			return options.Restore(tx, item.(T))
+		}
+	}
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+type atomicRef struct {
+	Type         string `json:"type"`
+	ID           string `json:"id,omitempty"`
+	Lid          string `json:"lid,omitempty"`
+	Relationship string `json:"relationship,omitempty"`
+}
+
+type atomicData struct {
+	Type          string                        `json:"type"`
+	ID            string                        `json:"id,omitempty"`
+	Lid           string                        `json:"lid,omitempty"`
+	Attributes    map[string]json.RawMessage    `json:"attributes,omitempty"`
+	Relationships map[string]atomicRelationship `json:"relationships,omitempty"`
+}
+
+type atomicRelationship struct {
+	Data *atomicRef `json:"data"`
+}
+
+type atomicOperation struct {
+	Op   string      `json:"op"`
+	Ref  *atomicRef  `json:"ref,omitempty"`
+	Data *atomicData `json:"data,omitempty"`
+}
+
+type atomicResult struct {
+	Data any `json:"data,omitempty"`
+}
+
+// operationError is an error of a single operation, Index is its position in the request.
+type operationError struct {
+	Index int
+	Err   error
+}
+
+func (e *operationError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *operationError) Unwrap() error {
+	return e.Err
+}
+
+// AtomicOperations is an Extension serving the JSON:API Atomic Operations extension at /operations.
+// All operations of a request are executed in one transaction, so either all or none are applied.
+type AtomicOperations struct {
+	// restore saves added items with the Restore hook of their type and without authorization, for ImportArchive.
+	restore bool
+}
+
+func NewAtomicOperations() *AtomicOperations {
+	return &AtomicOperations{}
+}
+
+func (a *AtomicOperations) Apply(route router.RouteElement) {
+	route.POST("operations", a.Handle)
+	operations := Schema{
+		"type":     "object",
+		"required": []string{"atomic:operations"},
+		"properties": Schema{
+			"atomic:operations": Schema{"type": "array", "items": Schema{
+				"type":     "object",
+				"required": []string{"op"},
+				"properties": Schema{
+					"op":   Schema{"type": "string", "enum": []string{"add", "update", "remove"}},
+					"ref":  Schema{"$ref": "#/components/schemas/ResourceIdentifier"},
+					"data": Schema{"type": "object"},
+				},
+			}},
+		},
+	}
+	results := Schema{
+		"type": "object",
+		"properties": Schema{
+			"atomic:results": Schema{"type": "array", "items": Schema{
+				"type":       "object",
+				"properties": Schema{"data": Schema{"type": "object"}},
+			}},
+		},
+	}
+	APIDoc.Describe(route, http.MethodPost, "operations", &Operation{
+		Summary:  "Execute atomic operations in one transaction",
+		Request:  &Content{MediaType: AtomicMediaType, Schema: operations},
+		Response: &Content{MediaType: AtomicMediaType, Schema: results},
+	})
+}
+
+func (a *AtomicOperations) Handle(w http.ResponseWriter, req *http.Request) {
+	if !isAtomicRequest(req) {
+		writeErrorDocument(w, http.StatusUnsupportedMediaType, "media type must be "+AtomicMediaType, nil)
+		return
+	}
+	doc := struct {
+		Operations []*atomicOperation `json:"atomic:operations"`
+	}{}
+	if err := json.NewDecoder(req.Body).Decode(&doc); err != nil {
+		writeErrorDocument(w, http.StatusBadRequest, "failed to decode operations", err)
+		return
+	}
+	if len(doc.Operations) == 0 {
+		writeErrorDocument(w, http.StatusBadRequest, "no operations", nil)
+		return
+	}
+	results := make([]*atomicResult, 0, len(doc.Operations))
+	lids := make(map[string]string)
+	err := request.DB(req).DoTransaction(func(tx db.Transaction) error {
+		for i, op := range doc.Operations {
+			result, err := a.execute(req, tx, op, lids)
+			if err != nil {
+				return &operationError{Index: i, Err: err}
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		var opErr *operationError
+		if errors.As(err, &opErr) {
+			apiErrs := translateAll(req, opErr.Err)
+			for i, apiErr := range apiErrs {
+				prefixed := *apiErr
+				pointer := "/atomic:operations/" + strconv.Itoa(opErr.Index)
+				if apiErr.Source != nil && apiErr.Source.Pointer != "" {
+					pointer += apiErr.Source.Pointer
+				}
+				apiErrs[i] = prefixed.WithPointer(pointer)
+			}
+			writeErrors(w, apiErrs[0].Status, apiErrs...)
+			return
+		}
+		writeErrorDocument(w, http.StatusInternalServerError, "failed to execute operations", err)
+		return
+	}
+	w.Header().Set("Content-Type", AtomicMediaType)
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{"atomic:results": results})
+}
+
+func (a *AtomicOperations) execute(req *http.Request, tx db.Transaction, op *atomicOperation, lids map[string]string) (*atomicResult, error) {
+	ref := op.Ref
+	if ref == nil && op.Data != nil {
+		ref = &atomicRef{Type: op.Data.Type, ID: op.Data.ID, Lid: op.Data.Lid}
+	}
+	if ref == nil {
+		return nil, badOperation(errors.New("operation needs ref or data"))
+	}
+	if ref.Relationship != "" {
+		return nil, badOperation(errors.New("relationship operations are not supported"))
+	}
+	if err := checkRef(ref, op.Data); err != nil {
+		return nil, err
+	}
+	resource, ok := atomicResources[ref.Type]
+	if !ok {
+		return nil, badOperation(fmt.Errorf("unknown type %s", ref.Type))
+	}
+	switch op.Op {
+	case "add":
+		if op.Data == nil {
+			return nil, badOperation(errors.New("add needs data"))
+		}
+		item := resource.newItem()
+		if err := a.apply(resource, item, op.Data, lids); err != nil {
+			return nil, err
+		}
+		if err := validate.Struct(item); err != nil {
+			return nil, err
+		}
+		save := resource.save
+		if a.restore && resource.restore != nil {
+			save = resource.restore
+		}
+		if err := a.authorize(req, tx, resource, item); err != nil {
+			return nil, err
+		}
+		if err := save(tx, item); err != nil {
+			return nil, err
+		}
+		if op.Data.Lid != "" {
+			lids[lidKey(op.Data.Type, op.Data.Lid)] = item.GetIdentifier().ID
+		}
+		return a.result(resource, item)
+	case "update":
+		if op.Data == nil {
+			return nil, badOperation(errors.New("update needs data"))
+		}
+		id, err := resolveID(ref, lids)
+		if err != nil {
+			return nil, err
+		}
+		item, err := resource.getByID(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			return nil, NotFound(ref.Type, id)
+		}
+		if err := a.apply(resource, item, op.Data, lids); err != nil {
+			return nil, err
+		}
+		if err := validate.Struct(item); err != nil {
+			return nil, err
+		}
+		if err := a.authorize(req, tx, resource, item); err != nil {
+			return nil, err
+		}
+		if err := resource.save(tx, item); err != nil {
+			return nil, err
+		}
+		return a.result(resource, item)
+	case "remove":
+		id, err := resolveID(ref, lids)
+		if err != nil {
+			return nil, err
+		}
+		if resource.authorize != nil {
+			item, err := resource.getByID(tx, id)
+			if err != nil {
+				return nil, err
+			}
+			if item == nil {
+				return nil, NotFound(ref.Type, id)
+			}
+			if err := a.authorize(req, tx, resource, item); err != nil {
+				return nil, err
+			}
+		}
+		if err := resource.delete(tx, id); err != nil {
+			return nil, err
+		}
+		return &atomicResult{}, nil
+	default:
+		return nil, badOperation(fmt.Errorf("unknown op %q", op.Op))
+	}
+}
+
+// authorize checks an operation of req on item with the Authorize hook of resource.
+func (a *AtomicOperations) authorize(req *http.Request, tx db.Transaction, resource *atomicResource, item Resource) error {
+	if a.restore || resource.authorize == nil {
+		return nil
+	}
+	return resource.authorize(req, tx, item)
+}
+
+// checkRef fails if the type, id or lid set in data differs from ref, like an id of the url differing
+// from the request document.
+func checkRef(ref *atomicRef, data *atomicData) error {
+	switch {
+	case data == nil:
+		return nil
+	case data.Type != "" && data.Type != ref.Type:
+		return TypeMismatch("/data/type")
+	case data.ID != "" && data.ID != ref.ID:
+		return IDMismatch("/data/id")
+	case data.Lid != "" && data.Lid != ref.Lid:
+		return IDMismatch("/data/lid")
+	}
+	return nil
+}
+
+// apply sets the attributes and relationships of data on item. Relationships are mapped to the
+// attribute of their Relation or to a nested object with the id of the related resource. The members
+// id and type are not attributes and left out, a version is kept so that the update of a stale item
+// conflicts.
+func (a *AtomicOperations) apply(resource *atomicResource, item Resource, data *atomicData, lids map[string]string) error {
+	members := make(map[string]any, len(data.Attributes)+len(data.Relationships))
+	for name, value := range data.Attributes {
+		if name == "id" || name == "type" {
+			continue
+		}
+		members[name] = value
+	}
+	for name, rel := range data.Relationships {
+		if rel.Data == nil {
+			members[name] = nil
+			continue
+		}
+		id, err := resolveID(rel.Data, lids)
+		if err != nil {
+			return err
+		}
+		relation, ok := resource.relations[name]
+		if ok && relation.Attribute != "" {
+			members[relation.Attribute] = id
+		} else {
+			members[name] = map[string]any{"id": id}
+		}
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, item); err != nil {
+		return badOperation(err)
+	}
+	return nil
+}
+
+func (a *AtomicOperations) result(resource *atomicResource, item Resource) (*atomicResult, error) {
+	resObj, err := jsonapi.MarshalResourceObject(item, nil)
+	if err != nil {
+		return nil, err
+	}
+	resObj.Links = map[string]any{"self": joinSelfLink(resource.selfLink(item))}
+	return &atomicResult{Data: resObj}, nil
+}
+
+func resolveID(ref *atomicRef, lids map[string]string) (int, error) {
+	id := ref.ID
+	if id == "" && ref.Lid != "" {
+		var ok bool
+		if id, ok = lids[lidKey(ref.Type, ref.Lid)]; !ok {
+			return 0, badOperation(fmt.Errorf("unknown lid %s of type %s", ref.Lid, ref.Type))
+		}
+	}
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, badOperation(fmt.Errorf("invalid id %q of type %s", id, ref.Type))
+	}
+	return idInt, nil
+}
+
+func lidKey(resourceType, lid string) string {
+	return resourceType + "/" + lid
+}
+
+func badOperation(err error) error {
+	return NewError(http.StatusBadRequest, "invalid_operation", "invalid operation").Wrap(err)
+}
+
+func isAtomicRequest(req *http.Request) bool {
+	return strings.Contains(req.Header.Get("Content-Type"), "ext=\"https://jsonapi.org/ext/atomic\"")
+}
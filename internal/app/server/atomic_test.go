@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/request"
+)
+
+type testItem struct {
+	ID       int       `json:"id,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	ParentID int       `json:"parentId,omitempty"`
+	Other    *testItem `json:"other,omitempty"`
+	Version  int       `json:"version,omitempty"`
+}
+
+func (t *testItem) SetIdentifier(id *jsonapi.ResourceIdentifierObject) {
+	t.ID, _ = strconv.Atoi(id.ID)
+}
+
+func (t *testItem) GetIdentifier() *jsonapi.ResourceIdentifierObject {
+	return &jsonapi.ResourceIdentifierObject{Type: "test.item", ID: strconv.Itoa(t.ID)}
+}
+
+type inMemItemService struct {
+	items map[int]*testItem
+}
+
+func (s *inMemItemService) Save(_ db.Transaction, item *testItem) error {
+	if item.ID == 0 {
+		item.ID = len(s.items) + 1
+	} else if stored, ok := s.items[item.ID]; ok && stored.Version != item.Version {
+		return db.ErrVersionConflict
+	}
+	item.Version++
+	saved := *item
+	s.items[item.ID] = &saved
+	return nil
+}
+
+func (s *inMemItemService) GetAll(_ db.Transaction, _ *pagination.Page, _ any) ([]*testItem, error) {
+	items := make([]*testItem, 0, len(s.items))
+	for _, item := range s.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+	return items, nil
+}
+
+func (s *inMemItemService) GetByID(_ db.Transaction, id int) (*testItem, error) {
+	item, ok := s.items[id]
+	if !ok {
+		return nil, nil
+	}
+	loaded := *item
+	return &loaded, nil
+}
+
+func (s *inMemItemService) Delete(_ db.Transaction, id int) error {
+	delete(s.items, id)
+	return nil
+}
+
+// noTxConnection runs transactions without database, the error of txFunc is returned as is.
+type noTxConnection struct{}
+
+func (noTxConnection) Select(_ any, _ string, _ ...any) error      { return nil }
+func (noTxConnection) Exec(_ string, _ ...any) (sql.Result, error) { return nil, nil }
+func (noTxConnection) DoTransaction(txFunc db.TxFunc) error        { return txFunc(nil) }
+func (noTxConnection) Close() error                                { return nil }
+
+func TestAtomicOperations_Handle(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantItems   map[int]*testItem
+		wantPointer string
+	}{{
+		name:        "GIVEN add with lid and update referencing lid THEN resolve lid",
+		contentType: AtomicMediaType,
+		body: `{"atomic:operations":[
+			{"op":"add","data":{"type":"test.item","lid":"a","attributes":{"name":"first"}}},
+			{"op":"add","data":{"type":"test.item","attributes":{"name":"second"},
+				"relationships":{"parent":{"data":{"type":"test.item","lid":"a"}},"other":{"data":{"type":"test.item","lid":"a"}}}}},
+			{"op":"update","ref":{"type":"test.item","lid":"a"},"data":{"type":"test.item","attributes":{"name":"renamed"}}}
+		]}`,
+		wantStatus: http.StatusOK,
+		wantItems: map[int]*testItem{
+			1: {ID: 1, Name: "renamed", Version: 2},
+			2: {ID: 2, Name: "second", ParentID: 1, Other: &testItem{ID: 1}, Version: 1},
+		},
+	}, {
+		name:        "GIVEN unknown lid THEN point to failing operation",
+		contentType: AtomicMediaType,
+		body: `{"atomic:operations":[
+			{"op":"add","data":{"type":"test.item","attributes":{"name":"first"}}},
+			{"op":"remove","ref":{"type":"test.item","lid":"unknown"}}
+		]}`,
+		wantStatus:  http.StatusBadRequest,
+		wantPointer: "/atomic:operations/1",
+	}, {
+		name:        "GIVEN update of missing item THEN return not found",
+		contentType: AtomicMediaType,
+		body:        `{"atomic:operations":[{"op":"update","data":{"type":"test.item","id":"9","attributes":{"name":"x"}}}]}`,
+		wantStatus:  http.StatusNotFound,
+		wantPointer: "/atomic:operations/0",
+	}, {
+		name:        "GIVEN id attribute THEN ignore it",
+		contentType: AtomicMediaType,
+		body:        `{"atomic:operations":[{"op":"add","data":{"type":"test.item","attributes":{"id":7,"name":"first"}}}]}`,
+		wantStatus:  http.StatusOK,
+		wantItems:   map[int]*testItem{1: {ID: 1, Name: "first", Version: 1}},
+	}, {
+		name:        "GIVEN update with current version THEN save it",
+		contentType: AtomicMediaType,
+		body: `{"atomic:operations":[
+			{"op":"add","data":{"type":"test.item","attributes":{"name":"first"}}},
+			{"op":"update","ref":{"type":"test.item","id":"1"},"data":{"type":"test.item","attributes":{"name":"x","version":1}}}
+		]}`,
+		wantStatus: http.StatusOK,
+		wantItems:  map[int]*testItem{1: {ID: 1, Name: "x", Version: 2}},
+	}, {
+		name:        "GIVEN update with outdated version THEN return conflict",
+		contentType: AtomicMediaType,
+		body: `{"atomic:operations":[
+			{"op":"add","data":{"type":"test.item","attributes":{"name":"first"}}},
+			{"op":"update","ref":{"type":"test.item","id":"1"},"data":{"type":"test.item","attributes":{"name":"x"}}},
+			{"op":"update","ref":{"type":"test.item","id":"1"},"data":{"type":"test.item","attributes":{"name":"y","version":1}}}
+		]}`,
+		wantStatus:  http.StatusConflict,
+		wantPointer: "/atomic:operations/2/data/attributes/version",
+	}, {
+		name:        "GIVEN data id differing from ref THEN return conflict",
+		contentType: AtomicMediaType,
+		body: `{"atomic:operations":[
+			{"op":"add","data":{"type":"test.item","attributes":{"name":"first"}}},
+			{"op":"update","ref":{"type":"test.item","id":"1"},"data":{"type":"test.item","id":"2","attributes":{"name":"x"}}}
+		]}`,
+		wantStatus:  http.StatusConflict,
+		wantPointer: "/atomic:operations/1/data/id",
+	}, {
+		name:        "GIVEN data type differing from ref THEN return conflict",
+		contentType: AtomicMediaType,
+		body:        `{"atomic:operations":[{"op":"update","ref":{"type":"test.item","id":"1"},"data":{"type":"other","id":"1"}}]}`,
+		wantStatus:  http.StatusConflict,
+		wantPointer: "/atomic:operations/0/data/type",
+	}, {
+		name:        "GIVEN plain JSON:API media type THEN return unsupported media type",
+		contentType: "application/vnd.api+json",
+		body:        `{"atomic:operations":[]}`,
+		wantStatus:  http.StatusUnsupportedMediaType,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &inMemItemService{items: make(map[int]*testItem)}
+			registerTestItems(service)
+			req := httptest.NewRequest(http.MethodPost, "/operations", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req = req.WithContext(context.WithValue(req.Context(), request.CtxKeyDatabase, db.Connection(noTxConnection{})))
+			rec := httptest.NewRecorder()
+
+			NewAtomicOperations().Handle(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Handle() status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantItems != nil {
+				if diff := cmp.Diff(tt.wantItems, service.items); diff != "" {
+					t.Errorf("Handle() items mismatch (-want +got):\n%s", diff)
+				}
+			}
+			if tt.wantPointer != "" {
+				doc := struct {
+					Errors []struct {
+						Source struct {
+							Pointer string `json:"pointer"`
+						} `json:"source"`
+					} `json:"errors"`
+				}{}
+				if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil || len(doc.Errors) != 1 {
+					t.Fatalf("Handle() invalid error document %s", rec.Body.String())
+				}
+				if doc.Errors[0].Source.Pointer != tt.wantPointer {
+					t.Errorf("Handle() pointer = %s, want %s", doc.Errors[0].Source.Pointer, tt.wantPointer)
+				}
+			}
+		})
+	}
+}
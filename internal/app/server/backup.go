@@ -0,0 +1,296 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vloryan/go-libs/httpx/router"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/db/sqlite"
+	"github.com/vloryan/protrakgon/internal/app/server/request"
+)
+
+const (
+	backupPrefix     = "protrakgon-"
+	backupSuffix     = ".db"
+	backupTimeFormat = "20060102T150405Z"
+)
+
+// ErrBackupNotFound is returned for names which are no backups of the backup directory.
+var ErrBackupNotFound = errors.New("backup not found")
+
+// BackupFile is a backup of the database in the backup directory.
+type BackupFile struct {
+	Name    string    `json:"-"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+}
+
+func (b *BackupFile) SetIdentifier(id *jsonapi.ResourceIdentifierObject) {
+	b.Name = id.ID
+}
+
+func (b *BackupFile) GetIdentifier() *jsonapi.ResourceIdentifierObject {
+	return &jsonapi.ResourceIdentifierObject{Type: "admin.backup", ID: b.Name}
+}
+
+// Backups writes online backups of the database into Dir and keeps the newest Keep backups.
+// The backups are consistent copies made by VACUUM INTO, the database can be used meanwhile.
+type Backups struct {
+	Dir  string
+	Keep int
+	now  func() time.Time
+	mu   sync.Mutex
+}
+
+func NewBackups(dir string, keep int) *Backups {
+	return &Backups{Dir: dir, Keep: keep, now: time.Now}
+}
+
+// Create writes a backup of the database of con and removes the backups exceeding Keep.
+func (b *Backups) Create(con db.Transaction) (*BackupFile, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := os.MkdirAll(b.Dir, 0o750); err != nil {
+		return nil, err
+	}
+	// backups of the same second are numbered, the names are unique as Create holds the lock
+	created := b.now().UTC().Truncate(time.Second)
+	name := backupName(created, 1)
+	for seq := 2; fileExists(filepath.Join(b.Dir, name)); seq++ {
+		name = backupName(created, seq)
+	}
+	if err := sqlite.Backup(con, filepath.Join(b.Dir, name)); err != nil {
+		return nil, fmt.Errorf("backup %s: %w", name, err)
+	}
+	backups, err := b.list()
+	if err != nil {
+		return nil, err
+	}
+	var backup *BackupFile
+	for i, file := range backups {
+		if file.Name == name {
+			backup = file
+		}
+		if b.Keep > 0 && i >= b.Keep && file.Name != name {
+			if err := os.Remove(filepath.Join(b.Dir, file.Name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if backup == nil {
+		return nil, fmt.Errorf("backup %s: %w", name, ErrBackupNotFound)
+	}
+	return backup, nil
+}
+
+// List returns the backups, the newest first.
+func (b *Backups) List() ([]*BackupFile, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.list()
+}
+
+func (b *Backups) list() ([]*BackupFile, error) {
+	entries, err := os.ReadDir(b.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []*BackupFile
+	for _, entry := range entries {
+		created, _, ok := parseBackupName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, &BackupFile{Name: entry.Name(), Created: created, Size: info.Size()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].Created.Equal(backups[j].Created) {
+			return backups[i].Created.After(backups[j].Created)
+		}
+		_, seqI, _ := parseBackupName(backups[i].Name)
+		_, seqJ, _ := parseBackupName(backups[j].Name)
+		return seqI > seqJ
+	})
+	return backups, nil
+}
+
+// Path returns the path of the backup name or ErrBackupNotFound.
+func (b *Backups) Path(name string) (string, error) {
+	if _, _, ok := parseBackupName(name); !ok {
+		return "", ErrBackupNotFound
+	}
+	p := filepath.Join(b.Dir, name)
+	if _, err := os.Stat(p); err != nil {
+		return "", ErrBackupNotFound
+	}
+	return p, nil
+}
+
+// At returns the newest backup created at or before t.
+func (b *Backups) At(t time.Time) (*BackupFile, error) {
+	backups, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, backup := range backups {
+		if !backup.Created.After(t) {
+			return backup, nil
+		}
+	}
+	return nil, fmt.Errorf("no backup at or before %s: %w", t.Format(time.RFC3339), ErrBackupNotFound)
+}
+
+// Schedule creates a backup every interval until ctx is done.
+func (b *Backups) Schedule(ctx context.Context, con db.Connection, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			backup, err := b.Create(con)
+			if err != nil {
+				log.Err(err).Msg("scheduled backup failed")
+				continue
+			}
+			log.Info().Str("name", backup.Name).Int64("size", backup.Size).Msg("backup written")
+		}
+	}
+}
+
+// backupName returns the name of the backup created at a second, seq numbers the backups of the same second.
+func backupName(created time.Time, seq int) string {
+	name := backupPrefix + created.Format(backupTimeFormat)
+	if seq > 1 {
+		name += "-" + strconv.Itoa(seq)
+	}
+	return name + backupSuffix
+}
+
+// parseBackupName returns the creation time and the number of the backup name.
+func parseBackupName(name string) (time.Time, int, bool) {
+	if !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+		return time.Time{}, 0, false
+	}
+	stamp, seqText, numbered := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix), "-")
+	created, err := time.Parse(backupTimeFormat, stamp)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	seq := 1
+	if numbered {
+		if seq, err = strconv.Atoi(seqText); err != nil || seq < 2 {
+			return time.Time{}, 0, false
+		}
+	}
+	return created, seq, true
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// BackupEndpoint is an Extension serving the backups at /admin/backup. It is only enabled with an
+// admin token, requests have to send it as bearer token.
+//
+//	GET  /admin/backup        lists the backups
+//	POST /admin/backup        creates a backup and downloads it
+//	GET  /admin/backup/:name  downloads a backup
+type BackupEndpoint struct {
+	jsonapi.GenericHandler[*BackupFile]
+	Backups *Backups
+	Token   string
+}
+
+func NewBackupEndpoint(backups *Backups, token string) *BackupEndpoint {
+	return &BackupEndpoint{Backups: backups, Token: token}
+}
+
+func (e *BackupEndpoint) Apply(route router.RouteElement) {
+	r := route.SubRoute("admin/backup")
+	r.GET("", e.authorize(Handle(&e.GenericHandler, e.List)))
+	r.POST("", e.authorize(e.Create))
+	r.GET(":name", e.authorize(e.Download))
+	download := &Content{MediaType: "application/vnd.sqlite3", Schema: Schema{"type": "string", "format": "binary"}}
+	APIDoc.Describe(r, http.MethodGet, "", &Operation{
+		Summary:  "List backups",
+		Response: CollectionDocument(&BackupFile{}),
+	})
+	APIDoc.Describe(r, http.MethodPost, "", &Operation{
+		Summary:  "Create a backup and download it",
+		Response: download,
+	})
+	APIDoc.Describe(r, http.MethodGet, ":name", &Operation{
+		Summary:  "Download a backup",
+		Response: download,
+	})
+}
+
+// authorize rejects requests without the admin token, without configured token the endpoint does not exist.
+func (e *BackupEndpoint) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return RequireAdmin(e.Token)(next)
+}
+
+func (e *BackupEndpoint) List(_ *http.Request) (*jsonapi.DocumentData[*BackupFile], error) {
+	backups, err := e.Backups.List()
+	if err != nil {
+		return nil, err
+	}
+	return jsonapi.NewDocumentData[*BackupFile](backups, "/admin/backup"), nil
+}
+
+func (e *BackupEndpoint) Create(w http.ResponseWriter, req *http.Request) {
+	backup, err := e.Backups.Create(request.DB(req))
+	if err != nil {
+		writeErrorDocument(w, http.StatusInternalServerError, "failed to create backup", err)
+		return
+	}
+	e.serve(w, req, backup.Name)
+}
+
+func (e *BackupEndpoint) Download(w http.ResponseWriter, req *http.Request) {
+	e.serve(w, req, request.Query(req, ":name"))
+}
+
+func (e *BackupEndpoint) serve(w http.ResponseWriter, req *http.Request, name string) {
+	p, err := e.Backups.Path(name)
+	if err != nil {
+		writeErrorDocument(w, http.StatusNotFound, "backup "+name+" not found", nil)
+		return
+	}
+	file, err := os.Open(p)
+	if err != nil {
+		writeErrorDocument(w, http.StatusInternalServerError, "failed to read backup", err)
+		return
+	}
+	defer func() { _ = file.Close() }()
+	info, err := file.Stat()
+	if err != nil {
+		writeErrorDocument(w, http.StatusInternalServerError, "failed to read backup", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	http.ServeContent(w, req, name, info.ModTime(), file)
+}
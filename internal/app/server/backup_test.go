@@ -0,0 +1,115 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fileTransaction writes the file of VACUUM INTO like SQLite does.
+type fileTransaction struct{}
+
+func (fileTransaction) Select(_ any, _ string, _ ...any) error { return nil }
+func (fileTransaction) Exec(_ string, args ...any) (sql.Result, error) {
+	return nil, os.WriteFile(args[0].(string), []byte("backup"), 0o600)
+}
+
+func TestBackups_Create(t *testing.T) {
+	backups := NewBackups(t.TempDir(), 2)
+	now := time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+	backups.now = func() time.Time {
+		return now
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := backups.Create(fileTransaction{}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		now = now.Add(time.Hour)
+	}
+
+	got, err := backups.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []*BackupFile{
+		{Name: "protrakgon-20250310T100000Z.db", Created: time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC), Size: 6},
+		{Name: "protrakgon-20250310T090000Z.db", Created: time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC), Size: 6},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("List() mismatch (-want +got):\n%s", diff)
+	}
+	at, err := backups.At(time.Date(2025, 3, 10, 9, 30, 0, 0, time.UTC))
+	if err != nil || at.Name != "protrakgon-20250310T090000Z.db" {
+		t.Errorf("At() = %v, %v, want backup of 09:00", at, err)
+	}
+	if _, err := backups.Path("../protrakgon.db"); err == nil {
+		t.Errorf("Path() accepted a name outside of the backup directory")
+	}
+}
+
+func TestBackups_Create_sameSecond(t *testing.T) {
+	backups := NewBackups(t.TempDir(), 0)
+	backups.now = func() time.Time {
+		return time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+	}
+	var names []string
+	for i := 0; i < 3; i++ {
+		backup, err := backups.Create(fileTransaction{})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		names = append(names, backup.Name)
+	}
+
+	want := []string{"protrakgon-20250310T080000Z.db", "protrakgon-20250310T080000Z-2.db", "protrakgon-20250310T080000Z-3.db"}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("Create() names mismatch (-want +got):\n%s", diff)
+	}
+	at, err := backups.At(time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC))
+	if err != nil || at.Name != "protrakgon-20250310T080000Z-3.db" {
+		t.Errorf("At() = %v, %v, want the last backup of 08:00", at, err)
+	}
+}
+
+func TestBackupEndpoint_authorize(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		wantStatus    int
+	}{{
+		name:       "GIVEN no admin token THEN endpoint is disabled",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:          "GIVEN wrong token THEN return unauthorized",
+		token:         "secret",
+		authorization: "Bearer wrong",
+		wantStatus:    http.StatusUnauthorized,
+	}, {
+		name:          "GIVEN admin token THEN call handler",
+		token:         "secret",
+		authorization: "Bearer secret",
+		wantStatus:    http.StatusOK,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewBackupEndpoint(NewBackups(t.TempDir(), 1), tt.token)
+			req := httptest.NewRequest(http.MethodGet, "/admin/backup", nil)
+			req.Header.Set("Authorization", tt.authorization)
+			rec := httptest.NewRecorder()
+
+			e.authorize(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("authorize() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
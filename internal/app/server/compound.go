@@ -0,0 +1,305 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+// Include makes a relationship of the resources of a CrudHandler includable with the include parameter.
+// The related resources of all resources of a response are loaded with one call of Resolve.
+type Include struct {
+	Relation
+	// Resolve loads the related resources by id, ids without resource are left out.
+	Resolve func(tx db.Transaction, ids []int) ([]Resource, error)
+	// SelfLink returns the link of a related resource, e.g. "client/1".
+	SelfLink func(item Resource) string
+	// Includes are the includable relationships of the related resources, e.g. the client of a project.
+	Includes []*Include
+}
+
+// ResolveByIDs returns an Include.Resolve loading the resources of service with the filter of the ids.
+func ResolveByIDs[T Resource, F any](service CrudService[T, F], filter func(ids []int) F) func(tx db.Transaction, ids []int) ([]Resource, error) {
+	return func(tx db.Transaction, ids []int) ([]Resource, error) {
+		items, err := service.GetAll(tx, &pagination.Page{Limit: -1}, filter(ids))
+		if err != nil {
+			return nil, err
+		}
+		resources := make([]Resource, len(items))
+		for i, item := range items {
+			resources[i] = item
+		}
+		return resources, nil
+	}
+}
+
+// includeNode is a relationship of an include path, paths with the same prefix share their nodes.
+type includeNode struct {
+	include  *Include
+	children []*includeNode
+}
+
+// compound adds the included resources (include parameter) and applies the sparse fieldsets (fields[TYPE]
+// parameter) as DocumentUpdater. The handler loads the included resources in its transaction with resolve.
+type compound struct {
+	nodes    []*includeNode
+	fields   map[string][]string
+	included []*jsonapi.ResourceObject
+	related  []Resource
+	// linked are the relationships derived from attributes by resource type, they link the included
+	// resources from the resource objects.
+	linked map[string][]Relation
+}
+
+// newCompound returns nil if the request has neither include nor fields parameters. It fails for include
+// paths with a relationship which is not includable.
+func newCompound(query url.Values, resourceType string, includes []*Include) (*compound, error) {
+	include := query.Get("include")
+	fields := sparseFieldsets(query)
+	if include == "" && len(fields) == 0 {
+		return nil, nil
+	}
+	c := &compound{fields: fields, linked: make(map[string][]Relation)}
+	if include == "" {
+		return c, nil
+	}
+	for _, path := range strings.Split(include, ",") {
+		if err := c.add(&c.nodes, resourceType, includes, strings.Split(path, ".")); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *compound) add(nodes *[]*includeNode, resourceType string, includes []*Include, names []string) error {
+	index := slices.IndexFunc(includes, func(include *Include) bool {
+		return include.Name == names[0]
+	})
+	if index < 0 {
+		return fmt.Errorf("%s has no includable relationship %q", resourceType, names[0])
+	}
+	include := includes[index]
+	var node *includeNode
+	for _, n := range *nodes {
+		if n.include == include {
+			node = n
+		}
+	}
+	if node == nil {
+		node = &includeNode{include: include}
+		*nodes = append(*nodes, node)
+	}
+	if len(names) == 1 {
+		return nil
+	}
+	return c.add(&node.children, include.Type, include.Includes, names[1:])
+}
+
+func sparseFieldsets(query url.Values) map[string][]string {
+	fields := make(map[string][]string)
+	for key, values := range query {
+		if !strings.HasPrefix(key, "fields[") || !strings.HasSuffix(key, "]") {
+			continue
+		}
+		resourceType := key[len("fields[") : len(key)-1]
+		fields[resourceType] = []string{}
+		for _, value := range values {
+			for _, field := range strings.Split(value, ",") {
+				if field != "" {
+					fields[resourceType] = append(fields[resourceType], field)
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// resolve loads the included resources of items, one query per relationship of the include paths.
+func (c *compound) resolve(tx db.Transaction, items []Resource) error {
+	if c == nil {
+		return nil
+	}
+	return c.resolveNodes(tx, c.nodes, items)
+}
+
+func (c *compound) resolveNodes(tx db.Transaction, nodes []*includeNode, items []Resource) error {
+	if len(items) == 0 {
+		return nil
+	}
+	resourceType := items[0].GetIdentifier().Type
+	for _, node := range nodes {
+		if node.include.Attribute != "" && !slices.Contains(c.linked[resourceType], node.include.Relation) {
+			c.linked[resourceType] = append(c.linked[resourceType], node.include.Relation)
+		}
+		ids, err := relatedIDs(items, node.include.Relation)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		related, err := node.include.Resolve(tx, ids)
+		if err != nil {
+			return err
+		}
+		for _, item := range related {
+			obj, err := jsonapi.MarshalResourceObject(item, nil)
+			if err != nil {
+				return err
+			}
+			obj.Links = map[string]any{"self": node.include.SelfLink(item)}
+			c.included = append(c.included, obj)
+			c.related = append(c.related, item)
+		}
+		if err := c.resolveNodes(tx, node.children, related); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// etag returns the ETag of a document with a resource of version, the included resources and the sparse
+// fieldsets of query. It fails if an included resource is not Versioned.
+func (c *compound) etag(version int, query string) (string, bool) {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(query))
+	for _, item := range c.related {
+		versioned, ok := item.(Versioned)
+		if !ok {
+			return "", false
+		}
+		id := item.GetIdentifier()
+		_, _ = fmt.Fprintf(hash, "\n%s/%s/%d", id.Type, id.ID, versioned.GetVersion())
+	}
+	return fmt.Sprintf(`"%d-%x"`, version, hash.Sum32()), true
+}
+
+// relatedIDs returns the distinct ids of the resources related to items. The id is read from the
+// attribute of relation or from the nested object named like the relation.
+func relatedIDs(items []Resource, relation Relation) ([]int, error) {
+	attribute := relation.Attribute
+	if attribute == "" {
+		attribute = relation.Name
+	}
+	var ids []int
+	for _, item := range items {
+		b, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		members := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(b, &members); err != nil {
+			return nil, err
+		}
+		value, ok := members[attribute]
+		if !ok {
+			continue
+		}
+		id, err := relatedID(value)
+		if err != nil || id == "" {
+			continue
+		}
+		idInt, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("relationship %s: %w", relation.Name, err)
+		}
+		if !slices.Contains(ids, idInt) {
+			ids = append(ids, idInt)
+		}
+	}
+	return ids, nil
+}
+
+// Update adds the included resources which are not part of the document yet, links them from the
+// resource objects and applies the sparse fieldsets.
+func (c *compound) Update(doc *jsonapi.Document) error {
+	seen := make(map[string]bool)
+	var objects, included []*jsonapi.ResourceObject
+	if err := jsonapi.ForEachElem[*jsonapi.ResourceObject](doc.Data, func(obj *jsonapi.ResourceObject) error {
+		seen[obj.Type+"/"+obj.ID] = true
+		objects = append(objects, obj)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := jsonapi.ForEachElem[*jsonapi.ResourceObject](doc.Included, func(obj *jsonapi.ResourceObject) error {
+		seen[obj.Type+"/"+obj.ID] = true
+		included = append(included, obj)
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, obj := range c.included {
+		if !seen[obj.Type+"/"+obj.ID] {
+			seen[obj.Type+"/"+obj.ID] = true
+			included = append(included, obj)
+		}
+	}
+	for _, obj := range append(objects, included...) {
+		c.link(obj)
+		c.applyFields(obj)
+	}
+	if len(included) > 0 {
+		doc.Included = included
+	}
+	return nil
+}
+
+// link adds the relationships derived from attributes to obj.
+func (c *compound) link(obj *jsonapi.ResourceObject) {
+	for _, relation := range c.linked[obj.Type] {
+		value, ok := obj.Attributes[relation.Attribute]
+		if !ok || value == nil {
+			continue
+		}
+		if obj.Relationships == nil {
+			obj.Relationships = make(map[string]any)
+		}
+		obj.Relationships[relation.Name] = map[string]any{
+			"data": &jsonapi.ResourceIdentifierObject{Type: relation.Type, ID: fmt.Sprint(value)},
+		}
+	}
+}
+
+func (c *compound) applyFields(obj *jsonapi.ResourceObject) {
+	allowed, ok := c.fields[obj.Type]
+	if !ok {
+		return
+	}
+	for key := range obj.Attributes {
+		if !slices.Contains(allowed, key) {
+			delete(obj.Attributes, key)
+		}
+	}
+	for key := range obj.Relationships {
+		if !slices.Contains(allowed, key) {
+			delete(obj.Relationships, key)
+		}
+	}
+	if len(obj.Relationships) == 0 {
+		obj.Relationships = nil
+	}
+}
+
+// resourceObject is the generic representation of a serialized JSON:API resource object.
+type resourceObject map[string]any
+
+func toResourceObject(v any) (resourceObject, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	obj := resourceObject{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	return obj, decoder.Decode(&obj)
+}
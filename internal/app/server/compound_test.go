@@ -0,0 +1,175 @@
+package server
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+// countingInclude includes the parent of test items from items and counts the calls of Resolve.
+func countingInclude(items map[int]*testItem, calls *int) *Include {
+	include := &Include{
+		Relation: Relation{Name: "parent", Type: "test.item", Attribute: "parentId"},
+		Resolve: func(_ db.Transaction, ids []int) ([]Resource, error) {
+			*calls++
+			var related []Resource
+			for _, id := range ids {
+				if item, ok := items[id]; ok {
+					related = append(related, item)
+				}
+			}
+			return related, nil
+		},
+		SelfLink: func(item Resource) string {
+			return "test/" + item.GetIdentifier().ID
+		},
+	}
+	include.Includes = []*Include{include}
+	return include
+}
+
+func TestCompound(t *testing.T) {
+	items := map[int]*testItem{
+		1: {ID: 1, Name: "root"},
+		2: {ID: 2, Name: "child", ParentID: 1},
+		3: {ID: 3, Name: "grandchild", ParentID: 2},
+		4: {ID: 4, Name: "sibling", ParentID: 2},
+	}
+	tests := []struct {
+		name         string
+		query        string
+		primary      []int
+		wantErr      bool
+		wantCalls    int
+		wantIncluded []string
+		wantObject   *jsonapi.ResourceObject
+	}{{
+		name:      "GIVEN no parameters THEN no compound",
+		query:     "",
+		primary:   []int{3},
+		wantCalls: 0,
+	}, {
+		name:         "GIVEN include THEN resolve related items of all resources at once",
+		query:        "include=parent",
+		primary:      []int{3, 4},
+		wantCalls:    1,
+		wantIncluded: []string{"2"},
+		wantObject: &jsonapi.ResourceObject{ID: "3", Type: "test.item",
+			Attributes: map[string]any{"name": "grandchild", "parentId": 2},
+			Relationships: map[string]any{
+				"parent": map[string]any{"data": &jsonapi.ResourceIdentifierObject{Type: "test.item", ID: "2"}},
+			}},
+	}, {
+		name:         "GIVEN nested include THEN resolve each level once and skip primary resources",
+		query:        "include=parent.parent",
+		primary:      []int{3, 2},
+		wantCalls:    2,
+		wantIncluded: []string{"1"},
+		wantObject: &jsonapi.ResourceObject{ID: "3", Type: "test.item",
+			Attributes: map[string]any{"name": "grandchild", "parentId": 2},
+			Relationships: map[string]any{
+				"parent": map[string]any{"data": &jsonapi.ResourceIdentifierObject{Type: "test.item", ID: "2"}},
+			}},
+	}, {
+		name:         "GIVEN sparse fieldset THEN remove other attributes and relationships",
+		query:        "include=parent&fields[test.item]=name",
+		primary:      []int{3},
+		wantCalls:    1,
+		wantIncluded: []string{"2"},
+		wantObject:   &jsonapi.ResourceObject{ID: "3", Type: "test.item", Attributes: map[string]any{"name": "grandchild"}},
+	}, {
+		name:    "GIVEN unknown include path THEN fail",
+		query:   "include=parent.unknown",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			query, _ := url.ParseQuery(tt.query)
+			c, err := newCompound(query, "test.item", []*Include{countingInclude(items, &calls)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newCompound() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var primary []Resource
+			var data []*jsonapi.ResourceObject
+			for _, id := range tt.primary {
+				primary = append(primary, items[id])
+				obj := &jsonapi.ResourceObject{ID: strconv.Itoa(id), Type: "test.item",
+					Attributes: map[string]any{"name": items[id].Name, "parentId": items[id].ParentID}}
+				data = append(data, obj)
+			}
+			if err := c.resolve(nil, primary); err != nil {
+				t.Fatalf("resolve() error = %v", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("resolve() calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if c == nil {
+				return
+			}
+			doc := &jsonapi.Document{Data: data}
+			if err := c.Update(doc); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+			var included []string
+			_ = jsonapi.ForEachElem[*jsonapi.ResourceObject](doc.Included, func(obj *jsonapi.ResourceObject) error {
+				included = append(included, obj.ID)
+				return nil
+			})
+			if diff := cmp.Diff(tt.wantIncluded, included); diff != "" {
+				t.Errorf("Update() included mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantObject, data[0]); diff != "" {
+				t.Errorf("Update() resource object mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+type versionedItem struct {
+	testItem
+	Version int `json:"version"`
+}
+
+func (v *versionedItem) GetVersion() int {
+	return v.Version
+}
+
+func TestCompound_etag(t *testing.T) {
+	tests := []struct {
+		name     string
+		related  []Resource
+		wantETag string
+		wantOK   bool
+	}{{
+		name:     "GIVEN no included resources THEN hash query only",
+		wantETag: `"3-811c9dc5"`,
+		wantOK:   true,
+	}, {
+		name:     "GIVEN versioned included resource THEN hash its version",
+		related:  []Resource{&versionedItem{testItem: testItem{ID: 1}, Version: 2}},
+		wantETag: `"3-a0ed5c8f"`,
+		wantOK:   true,
+	}, {
+		name:     "GIVEN changed version of included resource THEN change ETag",
+		related:  []Resource{&versionedItem{testItem: testItem{ID: 1}, Version: 3}},
+		wantETag: `"3-9fed5afc"`,
+		wantOK:   true,
+	}, {
+		name:    "GIVEN unversioned included resource THEN no ETag",
+		related: []Resource{&testItem{ID: 1}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &compound{related: tt.related}
+			etag, ok := c.etag(3, "")
+			if ok != tt.wantOK || etag != tt.wantETag {
+				t.Errorf("etag() = %q, %v, want %q, %v", etag, ok, tt.wantETag, tt.wantOK)
+			}
+		})
+	}
+}
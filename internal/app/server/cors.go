@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exposedHeaders are the response headers cross-origin scripts may read.
+var exposedHeaders = []string{"Content-Disposition", "ETag", "Retry-After", RequestIDHeader}
+
+// CORSOptions allow scripts of other origins, e.g. a browser extension or a dashboard, to call the API.
+type CORSOptions struct {
+	// AllowedOrigins are origins like https://dashboard.lan, * allows all origins. Without origins
+	// only the origin of the UI may call the API.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// AllowCredentials lets browsers send cookies, it cannot be combined with the origin *.
+	AllowCredentials bool
+	// MaxAge is the time browsers cache the result of a preflight request.
+	MaxAge time.Duration
+}
+
+// WithCORS allows cross-origin requests to the API as configured by options, nil disables them.
+func (svr *Server) WithCORS(options *CORSOptions) *Server {
+	svr.cors = nil
+	if options != nil && len(options.AllowedOrigins) > 0 {
+		svr.cors = options
+	}
+	return svr
+}
+
+func (o *CORSOptions) allowsAnyOrigin() bool {
+	return slices.Contains(o.AllowedOrigins, "*")
+}
+
+func (o *CORSOptions) allowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if o.allowsAnyOrigin() {
+		return true
+	}
+	return slices.ContainsFunc(o.AllowedOrigins, func(allowed string) bool {
+		return strings.EqualFold(allowed, origin)
+	})
+}
+
+// setAllowOrigin allows the origin of req to read the response if it is allowed.
+func (o *CORSOptions) setAllowOrigin(w http.ResponseWriter, req *http.Request) bool {
+	w.Header().Add("Vary", "Origin")
+	origin := req.Header.Get("Origin")
+	if !o.allowsOrigin(origin) {
+		return false
+	}
+	if o.allowsAnyOrigin() && !o.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if o.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// allowCrossOrigin adds the CORS headers to the responses of allowed origins.
+func (svr *Server) allowCrossOrigin(next http.HandlerFunc) http.HandlerFunc {
+	if svr.cors == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		if svr.cors.setAllowOrigin(w, req) {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(exposedHeaders, ", "))
+		}
+		next(w, req)
+	}
+}
+
+// preflight answers OPTIONS requests of a route with the methods registered for it. The CORS headers
+// are only added for allowed origins requesting an allowed method, browsers block the request otherwise.
+func (svr *Server) preflight(methods func() []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		allow := append([]string{http.MethodOptions}, methods()...)
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		method := req.Header.Get("Access-Control-Request-Method")
+		if svr.cors == nil || method == "" || !slices.Contains(allow, method) || !slices.Contains(svr.cors.AllowedMethods, method) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if svr.cors.setAllowOrigin(w, req) {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(svr.cors.AllowedMethods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(svr.cors.AllowedHeaders, ", "))
+			if svr.cors.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(svr.cors.MaxAge.Seconds())))
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestServer_preflight(t *testing.T) {
+	options := &CORSOptions{
+		AllowedOrigins: []string{"https://dashboard.lan"},
+		AllowedMethods: []string{"GET", "POST", "PATCH"},
+		AllowedHeaders: []string{"Content-Type", "X-CSRF-Token"},
+		MaxAge:         10 * time.Minute,
+	}
+	tests := []struct {
+		name    string
+		cors    *CORSOptions
+		origin  string
+		method  string
+		want    http.Header
+		wantVia string
+	}{{
+		name:   "GIVEN allowed origin and method THEN allow request",
+		cors:   options,
+		origin: "https://dashboard.lan",
+		method: "PATCH",
+		want: http.Header{
+			"Allow":                        {"OPTIONS, GET, PATCH, DELETE"},
+			"Vary":                         {"Origin"},
+			"Access-Control-Allow-Origin":  {"https://dashboard.lan"},
+			"Access-Control-Allow-Methods": {"GET, POST, PATCH"},
+			"Access-Control-Allow-Headers": {"Content-Type, X-CSRF-Token"},
+			"Access-Control-Max-Age":       {"600"},
+		},
+	}, {
+		name:   "GIVEN other origin THEN omit cors headers",
+		cors:   options,
+		origin: "https://evil.example",
+		method: "PATCH",
+		want:   http.Header{"Allow": {"OPTIONS, GET, PATCH, DELETE"}, "Vary": {"Origin"}},
+	}, {
+		name:   "GIVEN method of other route THEN omit cors headers",
+		cors:   options,
+		origin: "https://dashboard.lan",
+		method: "POST",
+		want:   http.Header{"Allow": {"OPTIONS, GET, PATCH, DELETE"}},
+	}, {
+		name:   "GIVEN any origin THEN allow all origins",
+		cors:   &CORSOptions{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}},
+		origin: "https://dashboard.lan",
+		method: "GET",
+		want: http.Header{
+			"Allow":                        {"OPTIONS, GET, PATCH, DELETE"},
+			"Vary":                         {"Origin"},
+			"Access-Control-Allow-Origin":  {"*"},
+			"Access-Control-Allow-Methods": {"GET"},
+			"Access-Control-Allow-Headers": {""},
+		},
+	}, {
+		name:   "GIVEN no cors THEN answer allowed methods",
+		origin: "https://dashboard.lan",
+		method: "GET",
+		want:   http.Header{"Allow": {"OPTIONS, GET, PATCH, DELETE"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New().WithCORS(tt.cors)
+			handler := srv.preflight(func() []string { return []string{"GET", "PATCH", "DELETE"} })
+			req := httptest.NewRequest(http.MethodOptions, "/v1/client/1", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("preflight() status = %d, want %d", rec.Code, http.StatusNoContent)
+			}
+			if diff := cmp.Diff(tt.want, rec.Header()); diff != "" {
+				t.Errorf("preflight() headers mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServer_allowCrossOrigin(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials bool
+		origin      string
+		want        http.Header
+	}{{
+		name:   "GIVEN allowed origin THEN expose headers",
+		origin: "https://dashboard.lan",
+		want: http.Header{
+			"Vary":                          {"Origin"},
+			"Access-Control-Allow-Origin":   {"https://dashboard.lan"},
+			"Access-Control-Expose-Headers": {"Content-Disposition, ETag, Retry-After, X-Request-ID"},
+		},
+	}, {
+		name:        "GIVEN credentials THEN allow credentials",
+		credentials: true,
+		origin:      "https://dashboard.lan",
+		want: http.Header{
+			"Vary":                             {"Origin"},
+			"Access-Control-Allow-Origin":      {"https://dashboard.lan"},
+			"Access-Control-Allow-Credentials": {"true"},
+			"Access-Control-Expose-Headers":    {"Content-Disposition, ETag, Retry-After, X-Request-ID"},
+		},
+	}, {
+		name: "GIVEN same origin THEN only vary",
+		want: http.Header{"Vary": {"Origin"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New().WithCORS(&CORSOptions{AllowedOrigins: []string{"https://Dashboard.lan"}, AllowCredentials: tt.credentials})
+			handler := srv.allowCrossOrigin(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/v1/client", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			if diff := cmp.Diff(tt.want, rec.Header()); diff != "" {
+				t.Errorf("allowCrossOrigin() headers mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
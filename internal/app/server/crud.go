@@ -0,0 +1,383 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/vloryan/go-libs/httpx"
+	"github.com/vloryan/go-libs/httpx/router"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/request"
+	"github.com/vloryan/protrakgon/internal/app/server/validate"
+)
+
+// Versioned is implemented by resources with optimistic concurrency, see CheckIfMatch.
+type Versioned interface {
+	GetVersion() int
+}
+
+// CrudHandler serves create, read, update and delete of a resource backed by a CrudService.
+//
+// It registers the routes POST and GET of Path and PATCH, GET and DELETE of Path/IDParam. If New
+// is set, GET Path/new returns a template of a new resource. All hooks are optional.
+type CrudHandler[T Resource, F any] struct {
+	jsonapi.GenericHandler[T]
+	Service CrudService[T, F]
+	// Path is the collection path relative to the route, e.g. "client" or ":projectID/slot".
+	Path string
+	// IDParam is the route parameter of the resource id, e.g. ":clientID".
+	IDParam string
+	// Tables are the tables the collection depends on, they determine its ETag.
+	Tables []string
+	// NewItem creates an empty resource the request document is bound to.
+	NewItem func() T
+	// NewFilter creates the filter the query parameters are bound to.
+	NewFilter func(req *http.Request) F
+	// New creates the template returned by GET Path/new.
+	New func(req *http.Request) T
+	// Link returns the link of the collection, by default "/" + Path.
+	Link func(req *http.Request) string
+	// BindParent sets the ids of parent resources of the url on item, e.g. the project id of a slot.
+	BindParent func(req *http.Request, item T) error
+	// AfterLoad is called with every item loaded by id, e.g. to check it belongs to the parent of the url.
+	AfterLoad func(req *http.Request, item T) error
+	// BeforeSave is called after binding, before the item is validated and saved.
+	BeforeSave func(req *http.Request, tx db.Transaction, item T) error
+	// Authorize wraps the handlers of POST, PATCH and DELETE, e.g. with RequireAdmin.
+	Authorize func(next http.HandlerFunc) http.HandlerFunc
+	// Includes are the relationships which can be included with the include parameter. They resolve the
+	// related resources of request documents too, unless ResolveObjectWithReqFunc is set.
+	Includes []*Include
+}
+
+type ctxKeyCompound struct{}
+
+func (h *CrudHandler[T, F]) RegisterRoutes(route router.RouteElement) {
+	h.DocumentUpdaters = append(h.DocumentUpdaters, SelfLinkUpdaterInstance)
+	if h.ResolveObjectWithReqFunc == nil && len(h.Includes) > 0 {
+		h.ResolveObjectWithReqFunc = h.resolveObject
+	}
+	authorize := h.Authorize
+	if authorize == nil {
+		authorize = func(next http.HandlerFunc) http.HandlerFunc { return next }
+	}
+	r := route.SubRoute(h.Path)
+	r.POST("", authorize(h.Handle(h.Create)))
+	r.PATCH(h.IDParam, authorize(ETag(h.Handle(h.Update))))
+	r.GET("", ETag(h.read(h.GetAll)))
+	if h.New != nil {
+		r.GET("new", h.Handle(h.NewTemplate))
+	}
+	r.GET(h.IDParam, ETag(h.read(h.Get)))
+	r.DELETE(h.IDParam, authorize(h.Handle(h.Delete)))
+	h.describe(r)
+}
+
+// Handle serves fn with the GenericHandler of h, see Handle.
+func (h *CrudHandler[T, F]) Handle(fn func(req *http.Request) (*jsonapi.DocumentData[T], error)) http.HandlerFunc {
+	return Handle(&h.GenericHandler, fn)
+}
+
+func (h *CrudHandler[T, F]) describe(r router.RouteElement) {
+	resource := h.NewItem()
+	resourceType := resource.GetIdentifier().Type
+	var filter F
+	APIDoc.Describe(r, http.MethodPost, "", &Operation{
+		Summary:  "Create " + resourceType,
+		Request:  Document(resource),
+		Response: Document(resource),
+	})
+	APIDoc.Describe(r, http.MethodPatch, h.IDParam, &Operation{
+		Summary:  "Update " + resourceType,
+		Request:  Document(resource),
+		Response: Document(resource),
+	})
+	APIDoc.Describe(r, http.MethodGet, "", &Operation{
+		Summary:   "List " + resourceType,
+		Response:  CollectionDocument(resource),
+		Query:     filter,
+		Paginated: true,
+	})
+	if h.New != nil {
+		APIDoc.Describe(r, http.MethodGet, "new", &Operation{
+			Summary:  "Template of a new " + resourceType,
+			Response: Document(resource),
+		})
+	}
+	APIDoc.Describe(r, http.MethodGet, h.IDParam, &Operation{
+		Summary:  "Get " + resourceType,
+		Response: Document(resource),
+	})
+	APIDoc.Describe(r, http.MethodDelete, h.IDParam, &Operation{
+		Summary: "Delete " + resourceType,
+		Status:  http.StatusNoContent,
+	})
+}
+
+func (h *CrudHandler[T, F]) Create(req *http.Request) (data *jsonapi.DocumentData[T], err error) {
+	con := request.DB(req)
+	if err := con.DoTransaction(func(tx db.Transaction) error {
+		item := h.NewItem()
+		if err := httpx.ShouldBindWith(req, item, jsonapi.Binding); err != nil {
+			return InvalidBody(err)
+		}
+		if err := h.save(req, tx, item); err != nil {
+			return err
+		}
+		data = jsonapi.NewDocumentData[T](item, h.link(req))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (h *CrudHandler[T, F]) Update(req *http.Request) (data *jsonapi.DocumentData[T], err error) {
+	con := request.DB(req)
+	if err := con.DoTransaction(func(tx db.Transaction) error {
+		id := request.QueryInt(req, h.IDParam, 0)
+		item, err := h.load(req, tx, id)
+		if err != nil {
+			return err
+		}
+		if versioned, ok := any(item).(Versioned); ok {
+			if err := CheckIfMatch(req, versioned.GetVersion()); err != nil {
+				return err
+			}
+		}
+		if err := httpx.ShouldBindWith(req, item, jsonapi.Binding); err != nil {
+			return InvalidBody(err)
+		}
+		identifier := item.GetIdentifier()
+		if identifier.ID != strconv.Itoa(id) {
+			if identifier.ID != "" {
+				return IDMismatch("/data/id")
+			}
+			item.SetIdentifier(&jsonapi.ResourceIdentifierObject{Type: identifier.Type, ID: strconv.Itoa(id)})
+		}
+		if err := h.save(req, tx, item); err != nil {
+			return err
+		}
+		data = jsonapi.NewDocumentData[T](item, h.link(req))
+		h.setETag(req, item)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (h *CrudHandler[T, F]) GetAll(req *http.Request) (data *jsonapi.DocumentData[T], err error) {
+	con := request.DB(req)
+	if err := con.DoTransaction(func(tx db.Transaction) error {
+		filter := h.NewFilter(req)
+		if err := request.BindFilter(req, filter); err != nil {
+			return InvalidQuery(err)
+		}
+		if notModified, err := CollectionETag(req, tx, h.Tables...); err != nil || notModified {
+			data = jsonapi.NewDocumentData[T]([]T{}, h.link(req))
+			return err
+		}
+		if cursorPage := request.CursorPage(req); cursorPage != nil {
+			items, err := h.Service.(db.KeysetRepository[T, F]).GetPage(tx, cursorPage, filter)
+			if err != nil {
+				return err
+			}
+			data = jsonapi.NewDocumentData[T](items, h.link(req))
+			return compoundOf(req).resolve(tx, resources(items))
+		}
+		page := jsonapi.ExtractPagination(req)
+		items, err := h.Service.GetAll(tx, page, filter)
+		if err != nil {
+			return err
+		}
+		data = jsonapi.NewDocumentData[T](items, h.link(req))
+		data.Page = page
+		return compoundOf(req).resolve(tx, resources(items))
+	}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (h *CrudHandler[T, F]) Get(req *http.Request) (data *jsonapi.DocumentData[T], err error) {
+	con := request.DB(req)
+	if err := con.DoTransaction(func(tx db.Transaction) error {
+		item, err := h.load(req, tx, request.QueryInt(req, h.IDParam, 0))
+		if err != nil {
+			return err
+		}
+		data = jsonapi.NewDocumentData[T](item, h.link(req))
+		if err := compoundOf(req).resolve(tx, []Resource{item}); err != nil {
+			return err
+		}
+		h.setETag(req, item)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (h *CrudHandler[T, F]) NewTemplate(req *http.Request) (data *jsonapi.DocumentData[T], err error) {
+	return jsonapi.NewDocumentData[T](h.New(req), h.link(req)), nil
+}
+
+func (h *CrudHandler[T, F]) Delete(req *http.Request) (data *jsonapi.DocumentData[T], err error) {
+	con := request.DB(req)
+	if err := con.DoTransaction(func(tx db.Transaction) error {
+		id := request.QueryInt(req, h.IDParam, 0)
+		if req.Header.Get("If-Match") != "" || h.AfterLoad != nil {
+			item, err := h.load(req, tx, id)
+			if err != nil {
+				return err
+			}
+			if versioned, ok := any(item).(Versioned); ok {
+				if err := CheckIfMatch(req, versioned.GetVersion()); err != nil {
+					return err
+				}
+			}
+		}
+		return h.Service.Delete(tx, id)
+	}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// read serves fn with the document updaters of the request. These are the included resources and sparse
+// fieldsets, which fn loads in its transaction with compoundOf(req).resolve, and the links of keyset
+// paginated collections, whose page fn reads with request.CursorPage.
+func (h *CrudHandler[T, F]) read(fn func(req *http.Request) (*jsonapi.DocumentData[T], error)) http.HandlerFunc {
+	plain := h.Handle(fn)
+	_, keyset := h.Service.(db.KeysetRepository[T, F])
+	return func(w http.ResponseWriter, req *http.Request) {
+		var updaters []jsonapi.DocumentUpdater
+		c, err := newCompound(req.URL.Query(), h.NewItem().GetIdentifier().Type, h.Includes)
+		if err != nil {
+			writeErrors(w, http.StatusBadRequest, NewError(http.StatusBadRequest, "invalid_parameter", "invalid include parameter").
+				WithParameter("include").
+				Wrap(err))
+			return
+		}
+		if c != nil {
+			req = req.WithContext(context.WithValue(req.Context(), ctxKeyCompound{}, c))
+			updaters = append(updaters, c)
+		}
+		if page := request.ExtractCursorPage(req); page != nil && keyset {
+			req = req.WithContext(context.WithValue(req.Context(), request.CtxKeyCursorPage, page))
+			updaters = append(updaters, &cursorLinks{req: req, page: page})
+		}
+		if len(updaters) == 0 {
+			plain(w, req)
+			return
+		}
+		handler := h.GenericHandler
+		handler.DocumentUpdaters = append(updaters, h.DocumentUpdaters...)
+		Handle(&handler, fn)(w, req)
+	}
+}
+
+// setETag sets the version of a Versioned item as ETag. Included resources and sparse fieldsets change
+// the document, so their ETag covers the query and the versions of the included resources as well.
+func (h *CrudHandler[T, F]) setETag(req *http.Request, item T) {
+	versioned, ok := any(item).(Versioned)
+	if !ok {
+		return
+	}
+	c := compoundOf(req)
+	if c == nil {
+		SetETag(req, versionETag(versioned.GetVersion()))
+		return
+	}
+	if etag, ok := c.etag(versioned.GetVersion(), req.URL.RawQuery); ok {
+		SetETag(req, etag)
+	}
+}
+
+// compoundOf returns the compound of a request served by read, it is nil without include and fields parameters.
+func compoundOf(req *http.Request) *compound {
+	c, _ := req.Context().Value(ctxKeyCompound{}).(*compound)
+	return c
+}
+
+func resources[T Resource](items []T) []Resource {
+	converted := make([]Resource, len(items))
+	for i, item := range items {
+		converted[i] = item
+	}
+	return converted
+}
+
+// resolveObject resolves the related resources of request documents with the Includes of their type.
+func (h *CrudHandler[T, F]) resolveObject(req *http.Request, id *jsonapi.ResourceIdentifierObject) (*jsonapi.ResourceObject, *jsonapi.Error) {
+	index := slices.IndexFunc(h.Includes, func(include *Include) bool {
+		return include.Type == id.Type
+	})
+	if index < 0 {
+		return nil, TranslateError(req, NewError(http.StatusBadRequest, "unknown_type", "unknown type "+id.Type))
+	}
+	include := h.Includes[index]
+	idInt, err := strconv.Atoi(id.ID)
+	if err != nil {
+		return nil, TranslateError(req, NewError(http.StatusBadRequest, "invalid_reference", "invalid "+id.Type+" id").
+			WithPointer("/data/relationships/"+include.Name).
+			Wrap(err))
+	}
+	items, err := include.Resolve(request.DB(req), []int{idInt})
+	if err != nil {
+		return nil, TranslateError(req, err)
+	}
+	if len(items) == 0 {
+		return nil, TranslateError(req, NotFound(id.Type, idInt))
+	}
+	resObj, err := jsonapi.MarshalResourceObject(items[0], nil)
+	if err != nil {
+		return nil, TranslateError(req, err)
+	}
+	resObj.Links = map[string]any{"self": include.SelfLink(items[0])}
+	return resObj, nil
+}
+
+func (h *CrudHandler[T, F]) load(req *http.Request, tx db.Transaction, id int) (T, error) {
+	item, err := h.Service.GetByID(tx, id)
+	if err != nil {
+		return item, err
+	}
+	if isNil(item) {
+		return item, NotFound(h.NewItem().GetIdentifier().Type, id)
+	}
+	if h.AfterLoad != nil {
+		if err := h.AfterLoad(req, item); err != nil {
+			return item, err
+		}
+	}
+	return item, nil
+}
+
+func (h *CrudHandler[T, F]) save(req *http.Request, tx db.Transaction, item T) error {
+	if h.BindParent != nil {
+		if err := h.BindParent(req, item); err != nil {
+			return err
+		}
+	}
+	if h.BeforeSave != nil {
+		if err := h.BeforeSave(req, tx, item); err != nil {
+			return err
+		}
+	}
+	if err := validate.Struct(item); err != nil {
+		return err
+	}
+	return h.Service.Save(tx, item)
+}
+
+func (h *CrudHandler[T, F]) link(req *http.Request) string {
+	if h.Link != nil {
+		return h.Link(req)
+	}
+	return "/" + h.Path
+}
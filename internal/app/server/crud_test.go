@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/request"
+)
+
+func newTestCrudHandler(service *inMemItemService) *CrudHandler[*testItem, any] {
+	return &CrudHandler[*testItem, any]{
+		Service: service,
+		Path:    ":parentID/item",
+		IDParam: ":itemID",
+		NewItem: func() *testItem {
+			return &testItem{}
+		},
+		AfterLoad: func(req *http.Request, item *testItem) error {
+			if item.ParentID != request.QueryInt(req, ":parentID", 0) {
+				return NotFound("test.item", item.ID)
+			}
+			return nil
+		},
+	}
+}
+
+func TestCrudHandler_load(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		id         int
+		wantStatus int
+	}{{
+		name: "GIVEN item of parent THEN return it",
+		url:  "/1/item/1?:parentID=1&:itemID=1",
+		id:   1,
+	}, {
+		name:       "GIVEN missing item THEN return not found",
+		url:        "/1/item/9?:parentID=1&:itemID=9",
+		id:         9,
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "GIVEN item of other parent THEN return not found",
+		url:        "/2/item/1?:parentID=2&:itemID=1",
+		id:         1,
+		wantStatus: http.StatusNotFound,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &inMemItemService{items: map[int]*testItem{1: {ID: 1, ParentID: 1}}}
+			h := newTestCrudHandler(service)
+
+			got, err := h.load(httptest.NewRequest(http.MethodGet, tt.url, nil), nil, tt.id)
+
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("load() error = %v", err)
+				}
+				if diff := cmp.Diff(service.items[tt.id], got); diff != "" {
+					t.Errorf("load() mismatch (-want +got):\n%s", diff)
+				}
+				return
+			}
+			var apiErr *Error
+			if !errors.As(err, &apiErr) || apiErr.Status != tt.wantStatus {
+				t.Errorf("load() error = %v, want status %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCrudHandler_Delete(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		wantErr   bool
+		wantItems map[int]*testItem
+	}{{
+		name:      "GIVEN item of parent THEN delete it",
+		url:       "/1/item/1?:parentID=1&:itemID=1",
+		wantItems: map[int]*testItem{},
+	}, {
+		name:      "GIVEN item of other parent THEN keep it",
+		url:       "/2/item/1?:parentID=2&:itemID=1",
+		wantErr:   true,
+		wantItems: map[int]*testItem{1: {ID: 1, ParentID: 1}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &inMemItemService{items: map[int]*testItem{1: {ID: 1, ParentID: 1}}}
+			h := newTestCrudHandler(service)
+			req := httptest.NewRequest(http.MethodDelete, tt.url, nil)
+			req = req.WithContext(context.WithValue(req.Context(), request.CtxKeyDatabase, db.Connection(noTxConnection{})))
+
+			_, jErr := h.Delete(req)
+
+			if (jErr != nil) != tt.wantErr {
+				t.Fatalf("Delete() error = %v, wantErr %v", jErr, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.wantItems, service.items); diff != "" {
+				t.Errorf("Delete() items mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
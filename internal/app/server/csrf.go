@@ -0,0 +1,95 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+// CSRFHeader carries the CSRF token of a session, see WithCSRF.
+const CSRFHeader = "X-CSRF-Token"
+
+// CSRFOptions protect requests authenticated by a session cookie against cross-site request forgery.
+type CSRFOptions struct {
+	// SessionCookie is the name of the cookie identifying the session.
+	SessionCookie string
+	// Secret signs the tokens. Without secret a random one is used, the tokens expire on restart then.
+	Secret []byte
+}
+
+// csrfProtection derives the token of a session from its cookie, so no token has to be stored.
+type csrfProtection struct {
+	sessionCookie string
+	secret        []byte
+}
+
+// WithCSRF requires the token of the session in the CSRFHeader of mutating requests sending the session
+// cookie. Scripts get the token with GET <api>/csrf, other sites can neither read it nor guess it.
+// Requests with an Authorization header are not affected, browsers never add it on their own.
+func (svr *Server) WithCSRF(options *CSRFOptions) *Server {
+	svr.csrf = nil
+	if options == nil || options.SessionCookie == "" {
+		return svr
+	}
+	secret := options.Secret
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	}
+	svr.csrf = &csrfProtection{sessionCookie: options.SessionCookie, secret: secret}
+	return svr
+}
+
+func (p *csrfProtection) token(session string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// session returns the value of the session cookie of req if it is authenticated by the cookie.
+func (p *csrfProtection) session(req *http.Request) (string, bool) {
+	if req.Header.Get("Authorization") != "" {
+		return "", false
+	}
+	cookie, err := req.Cookie(p.sessionCookie)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+// checkCSRF rejects mutating requests of a cookie session without its token with 403.
+func (svr *Server) checkCSRF(next http.HandlerFunc) http.HandlerFunc {
+	if svr.csrf == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next(w, req)
+			return
+		}
+		session, ok := svr.csrf.session(req)
+		if ok && !hmac.Equal([]byte(req.Header.Get(CSRFHeader)), []byte(svr.csrf.token(session))) {
+			writeErrors(w, http.StatusForbidden, NewError(http.StatusForbidden, "csrf_failed", "missing or invalid csrf token").
+				WithHeader(CSRFHeader))
+			return
+		}
+		next(w, req)
+	}
+}
+
+// csrfToken serves the token of the session of the request in the meta of a JSON:API document.
+func (svr *Server) csrfToken(w http.ResponseWriter, req *http.Request) {
+	session, ok := svr.csrf.session(req)
+	if !ok {
+		writeErrors(w, http.StatusUnauthorized, NewError(http.StatusUnauthorized, "no_session", "request has no session cookie"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(map[string]any{"meta": map[string]string{"token": svr.csrf.token(session)}})
+}
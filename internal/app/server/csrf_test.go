@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_checkCSRF(t *testing.T) {
+	srv := New().WithCSRF(&CSRFOptions{SessionCookie: "session", Secret: []byte("secret")})
+	validToken := srv.csrf.token("s1")
+	tests := []struct {
+		name          string
+		method        string
+		session       string
+		token         string
+		authorization string
+		wantStatus    int
+	}{{
+		name:       "GIVEN session without token THEN reject",
+		method:     http.MethodPost,
+		session:    "s1",
+		wantStatus: http.StatusForbidden,
+	}, {
+		name:       "GIVEN token of other session THEN reject",
+		method:     http.MethodDelete,
+		session:    "s2",
+		token:      validToken,
+		wantStatus: http.StatusForbidden,
+	}, {
+		name:       "GIVEN session with token THEN accept",
+		method:     http.MethodPatch,
+		session:    "s1",
+		token:      validToken,
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "GIVEN safe method THEN accept without token",
+		method:     http.MethodGet,
+		session:    "s1",
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "GIVEN no session THEN accept without token",
+		method:     http.MethodPost,
+		wantStatus: http.StatusOK,
+	}, {
+		name:          "GIVEN authorization header THEN accept without token",
+		method:        http.MethodPost,
+		session:       "s1",
+		authorization: "Bearer t",
+		wantStatus:    http.StatusOK,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := srv.checkCSRF(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest(tt.method, "/v1/client", nil)
+			if tt.session != "" {
+				req.AddCookie(&http.Cookie{Name: "session", Value: tt.session})
+			}
+			if tt.token != "" {
+				req.Header.Set(CSRFHeader, tt.token)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("checkCSRF() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestServer_csrfToken(t *testing.T) {
+	srv := New().WithCSRF(&CSRFOptions{SessionCookie: "session"})
+	req := httptest.NewRequest(http.MethodGet, "/v1/csrf", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+	rec := httptest.NewRecorder()
+
+	srv.csrfToken(rec, req)
+
+	doc := struct {
+		Meta struct {
+			Token string `json:"token"`
+		} `json:"meta"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Meta.Token != srv.csrf.token("s1") {
+		t.Errorf("csrfToken() = %s, want token of session", doc.Meta.Token)
+	}
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorPage requests a page of a keyset pagination. At most one of After and Before is set.
+// After loading the page, Next and Prev contain the cursors of the adjacent pages if they exist.
+type CursorPage struct {
+	Size   int
+	After  string
+	Before string
+	Next   string
+	Prev   string
+}
+
+// Keyset defines the unique, ascending sort order a keyset pagination is based on.
+type Keyset[T any] struct {
+	Columns []string
+	// Values returns the values of Columns of item.
+	Values func(item T) []any
+	// Targets returns pointers of the types of the column values, the cursor is decoded into.
+	Targets func() []any
+}
+
+type KeysetRepository[T any, F any] interface {
+	GetPage(tx Transaction, page *CursorPage, filter F) ([]T, error)
+}
+
+// GetPage selects the page of items following page.After or preceding page.Before. Other than
+// GetAll it does not count the items and the cost of a page does not depend on its position.
+func GetPage[T any](tx Transaction, q *Query, keyset *Keyset[T], page *CursorPage, filter any) ([]T, error) {
+	where, params, err := Where(filter)
+	if err != nil {
+		return nil, err
+	}
+	backwards := page.Before != ""
+	cursor := page.After
+	if backwards {
+		cursor = page.Before
+	}
+	if cursor != "" {
+		values, err := decodeCursor(cursor, keyset.Targets())
+		if err != nil {
+			return nil, err
+		}
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			name := "cursor" + strconv.Itoa(i)
+			params[name] = v
+			placeholders[i] = ":" + name
+		}
+		op := " > "
+		if backwards {
+			op = " < "
+		}
+		cond := "(" + strings.Join(keyset.Columns, ", ") + ")" + op + "(" + strings.Join(placeholders, ", ") + ")"
+		if where == "" {
+			where = "WHERE " + cond
+		} else {
+			where += " AND " + cond
+		}
+	}
+	orderArgs := make([]string, len(keyset.Columns))
+	for i, column := range keyset.Columns {
+		orderArgs[i] = column
+		if backwards {
+			orderArgs[i] += " DESC"
+		}
+	}
+	stmt := "SELECT " + strings.Join(q.Columns, ", ") + "\n  FROM " + q.Table
+	if where != "" {
+		stmt += "\n" + where
+	}
+	stmt += "\nORDER BY " + strings.Join(orderArgs, ", ") + "\nLIMIT :limit"
+	// one additional item tells whether there is a page after this one
+	params["limit"] = page.Size + 1
+
+	items := make([]T, 0, page.Size+1)
+	if err := tx.Select(&items, stmt, params); err != nil {
+		return nil, err
+	}
+	hasMore := len(items) > page.Size
+	if hasMore {
+		items = items[:page.Size]
+	}
+	if backwards {
+		slices.Reverse(items)
+	}
+	page.Next, page.Prev = "", ""
+	if len(items) == 0 {
+		return items, nil
+	}
+	if hasMore || backwards {
+		if page.Next, err = encodeCursor(keyset.Values(items[len(items)-1])); err != nil {
+			return nil, err
+		}
+	}
+	if (hasMore && backwards) || page.After != "" {
+		if page.Prev, err = encodeCursor(keyset.Values(items[0])); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func encodeCursor(values []any) (string, error) {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(cursor string, targets []any) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil || len(raw) != len(targets) {
+		return nil, ErrInvalidCursor
+	}
+	values := make([]any, len(targets))
+	for i, target := range targets {
+		if err := json.Unmarshal(raw[i], target); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = reflect.ValueOf(target).Elem().Interface()
+	}
+	return values, nil
+}
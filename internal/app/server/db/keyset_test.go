@@ -0,0 +1,108 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type keysetItem struct {
+	ID int
+}
+
+var keysetItemKeyset = &Keyset[*keysetItem]{
+	Columns: []string{"id"},
+	Values: func(item *keysetItem) []any {
+		return []any{item.ID}
+	},
+	Targets: func() []any {
+		return []any{new(int)}
+	},
+}
+
+// resultTx returns the given items on Select and records the statement.
+type resultTx struct {
+	items  []*keysetItem
+	query  string
+	params map[string]any
+}
+
+func (r *resultTx) Select(dest any, query string, args ...any) error {
+	r.query = query
+	r.params = args[0].(map[string]any)
+	reflect.ValueOf(dest).Elem().Set(reflect.ValueOf(r.items))
+	return nil
+}
+
+func (r *resultTx) Exec(_ string, _ ...any) (sql.Result, error) {
+	return nil, nil
+}
+
+func TestGetPage(t *testing.T) {
+	q := &Query{Table: "item", Columns: []string{"id"}}
+	cursor2, _ := encodeCursor([]any{2})
+	cursor3, _ := encodeCursor([]any{3})
+	tests := []struct {
+		name      string
+		page      *CursorPage
+		result    []*keysetItem
+		wantQuery string
+		wantItems []*keysetItem
+		wantNext  string
+		wantPrev  string
+		wantErr   error
+	}{{
+		name:      "GIVEN first page with more items THEN return next cursor only",
+		page:      &CursorPage{Size: 2},
+		result:    []*keysetItem{{ID: 1}, {ID: 2}, {ID: 3}},
+		wantQuery: "SELECT id\n  FROM item\nORDER BY id\nLIMIT :limit",
+		wantItems: []*keysetItem{{ID: 1}, {ID: 2}},
+		wantNext:  cursor2,
+	}, {
+		name:      "GIVEN after cursor and last page THEN return prev cursor only",
+		page:      &CursorPage{Size: 2, After: cursor2},
+		result:    []*keysetItem{{ID: 3}},
+		wantQuery: "SELECT id\n  FROM item\nWHERE (id) > (:cursor0)\nORDER BY id\nLIMIT :limit",
+		wantItems: []*keysetItem{{ID: 3}},
+		wantPrev:  cursor3,
+	}, {
+		name:      "GIVEN before cursor THEN query backwards and reverse items",
+		page:      &CursorPage{Size: 1, Before: cursor3},
+		result:    []*keysetItem{{ID: 2}, {ID: 1}},
+		wantQuery: "SELECT id\n  FROM item\nWHERE (id) < (:cursor0)\nORDER BY id DESC\nLIMIT :limit",
+		wantItems: []*keysetItem{{ID: 2}},
+		wantNext:  cursor2,
+		wantPrev:  cursor2,
+	}, {
+		name:    "GIVEN malformed cursor THEN throw ErrInvalidCursor",
+		page:    &CursorPage{Size: 1, After: "!"},
+		wantErr: ErrInvalidCursor,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &resultTx{items: tt.result}
+			got, err := GetPage(tx, q, keysetItemKeyset, tt.page, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetPage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if tx.query != tt.wantQuery {
+				t.Errorf("GetPage() query = %q, want %q", tx.query, tt.wantQuery)
+			}
+			if tx.params["limit"] != tt.page.Size+1 {
+				t.Errorf("GetPage() limit = %v, want %d", tx.params["limit"], tt.page.Size+1)
+			}
+			if diff := cmp.Diff(tt.wantItems, got); diff != "" {
+				t.Errorf("GetPage() mismatch (-want +got):\n%s", diff)
+			}
+			if tt.page.Next != tt.wantNext || tt.page.Prev != tt.wantPrev {
+				t.Errorf("GetPage() next = %q, prev = %q, want %q, %q", tt.page.Next, tt.page.Prev, tt.wantNext, tt.wantPrev)
+			}
+		})
+	}
+}
@@ -4,54 +4,98 @@ import (
 	"database/sql"
 	"fmt"
 	"reflect"
+	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+// QueryObserver is notified about every statement, operation is "select" or "exec".
+type QueryObserver func(operation, query string, duration time.Duration)
+
+// StatementLogger logs the statements executed on a connection at debug level and statements slower
+// than the slow query threshold at warn level.
 type StatementLogger struct {
-	db Connection
+	db        Connection
+	observer  QueryObserver
+	logger    *zerolog.Logger
+	slowQuery time.Duration
 }
 
 func (s StatementLogger) Close() error {
-	log.Debug().Msg("Close")
+	s.logger.Debug().Msg("Close")
 	return s.db.Close()
 }
 
 func (s StatementLogger) DoTransaction(txFunc TxFunc) error {
-	log.Debug().Msg("Begin transaction")
+	s.logger.Debug().Msg("Begin transaction")
 	err := s.db.DoTransaction(func(tx Transaction) error {
-		logger := &statementLoggerTX{tx: tx}
+		logger := &statementLoggerTX{tx: tx, parent: &s}
 		return txFunc(logger)
 	})
-	log.Debug().Msg("End transaction")
+	s.logger.Debug().Msg("End transaction")
 	return err
 }
 
-func WithStatementLogger(db Connection) Connection {
-	return &StatementLogger{db: db}
+// WithStatementLogger logs the statements executed on db and reports them to observer, which may be nil.
+func WithStatementLogger(db Connection, observer QueryObserver) *StatementLogger {
+	return &StatementLogger{db: db, observer: observer, logger: &log.Logger}
+}
+
+// WithLogger returns a copy logging to logger, e.g. the logger of a request.
+func (s StatementLogger) WithLogger(logger *zerolog.Logger) *StatementLogger {
+	s.logger = logger
+	return &s
+}
+
+// WithObserver returns a copy reporting the statements to observer.
+func (s StatementLogger) WithObserver(observer QueryObserver) *StatementLogger {
+	s.observer = observer
+	return &s
+}
+
+// WithSlowQueryThreshold returns a copy logging statements taking at least threshold as warning, 0 disables it.
+func (s StatementLogger) WithSlowQueryThreshold(threshold time.Duration) *StatementLogger {
+	s.slowQuery = threshold
+	return &s
 }
 
 func (s StatementLogger) Exec(query string, args ...any) (sql.Result, error) {
-	log.Debug().Str("Query", query).Str("args", printArgs(args)).Msg("Exec")
+	s.logger.Debug().Str("Query", query).Str("args", printArgs(args)).Msg("Exec")
+	defer s.observe("exec", query, args, time.Now())
 	return s.db.Exec(query, args...)
 }
 
 func (s StatementLogger) Select(dest any, query string, args ...any) error {
-	log.Debug().Str("Query", query).Str("args", printArgs(args)).Msg("Select")
+	s.logger.Debug().Str("Query", query).Str("args", printArgs(args)).Msg("Select")
+	defer s.observe("select", query, args, time.Now())
 	return s.db.Select(dest, query, args...)
 }
 
+func (s StatementLogger) observe(operation, query string, args []any, start time.Time) {
+	duration := time.Since(start)
+	if s.slowQuery > 0 && duration >= s.slowQuery {
+		s.logger.Warn().Str("Query", query).Str("args", printArgs(args)).Dur("duration", duration).Msg("slow query")
+	}
+	if s.observer != nil {
+		s.observer(operation, query, duration)
+	}
+}
+
 type statementLoggerTX struct {
-	tx Transaction
+	tx     Transaction
+	parent *StatementLogger
 }
 
 func (s *statementLoggerTX) Select(dest any, query string, args ...any) error {
-	log.Debug().Str("Query", query).Str("args", printArgs(args)).Msg("  Select")
+	s.parent.logger.Debug().Str("Query", query).Str("args", printArgs(args)).Msg("  Select")
+	defer s.parent.observe("select", query, args, time.Now())
 	return s.tx.Select(dest, query, args...)
 }
 
 func (s *statementLoggerTX) Exec(query string, args ...any) (sql.Result, error) {
-	log.Debug().Str("Query", query).Str("args", printArgs(args)).Msg("  Exec")
+	s.parent.logger.Debug().Str("Query", query).Str("args", printArgs(args)).Msg("  Exec")
+	defer s.parent.observe("exec", query, args, time.Now())
 	return s.tx.Exec(query, args...)
 }
 
@@ -2,6 +2,7 @@ package db
 
 import (
 	"errors"
+	"io/fs"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database"
@@ -11,27 +12,89 @@ import (
 type MigrationInfo struct {
 	CurrentVersion  uint
 	MigratedVersion uint
+	// Dirty is set if a migration failed, the database has to be fixed manually.
+	Dirty bool
 }
 
 func (m *MigrationInfo) Migrated() bool {
 	return m.MigratedVersion != m.CurrentVersion
 }
 
-func DoMigration(driver database.Driver, sourceName string, sourceInstance source.Driver) (*MigrationInfo, error) {
+// Migrator applies the migrations of a source to a database.
+type Migrator struct {
+	migrations *migrate.Migrate
+	source     source.Driver
+}
+
+func NewMigrator(driver database.Driver, sourceName string, sourceInstance source.Driver) (*Migrator, error) {
 	migrations, err := migrate.NewWithInstance(sourceName, sourceInstance, "", driver)
 	if err != nil {
 		return nil, err
 	}
-	versionBefore, _, err := migrations.Version()
+	return &Migrator{migrations: migrations, source: sourceInstance}, nil
+}
+
+// Latest returns the version of the last migration of the source.
+func (m *Migrator) Latest() (uint, error) {
+	version, err := m.source.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := m.source.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// Version returns the version of the database, 0 if no migration was applied.
+func (m *Migrator) Version() (*MigrationInfo, error) {
+	version, dirty, err := m.migrations.Version()
 	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
 		return nil, err
 	}
-	if err := migrations.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+	return &MigrationInfo{CurrentVersion: version, MigratedVersion: version, Dirty: dirty}, nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up() (*MigrationInfo, error) {
+	return m.apply(func() error {
+		return m.migrations.Up()
+	})
+}
+
+// Down reverts the last steps migrations.
+func (m *Migrator) Down(steps int) (*MigrationInfo, error) {
+	return m.apply(func() error {
+		return m.migrations.Steps(-steps)
+	})
+}
+
+func (m *Migrator) apply(migrateFunc func() error) (*MigrationInfo, error) {
+	before, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateFunc(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		return nil, err
 	}
-	versionAfter, _, err := migrations.Version()
+	after, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	after.MigratedVersion = before.CurrentVersion
+	return after, nil
+}
+
+func DoMigration(driver database.Driver, sourceName string, sourceInstance source.Driver) (*MigrationInfo, error) {
+	migrator, err := NewMigrator(driver, sourceName, sourceInstance)
 	if err != nil {
 		return nil, err
 	}
-	return &MigrationInfo{CurrentVersion: versionAfter, MigratedVersion: versionBefore}, nil
+	return migrator.Up()
 }
@@ -0,0 +1,287 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/vloryan/go-libs/reflectx"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+)
+
+// Operator is a comparison operator usable in the query tag of filter fields.
+type Operator string
+
+const (
+	OpEqual              Operator = "eq"
+	OpNotEqual           Operator = "ne"
+	OpLessThan           Operator = "lt"
+	OpLessThanOrEqual    Operator = "lte"
+	OpGreaterThan        Operator = "gt"
+	OpGreaterThanOrEqual Operator = "gte"
+	OpLike               Operator = "like"
+	OpIn                 Operator = "in"
+	OpIsNull             Operator = "isnull"
+)
+
+var sqlOperators = map[Operator]string{
+	OpEqual:              "=",
+	OpNotEqual:           "<>",
+	OpLessThan:           "<",
+	OpLessThanOrEqual:    "<=",
+	OpGreaterThan:        ">",
+	OpGreaterThanOrEqual: ">=",
+}
+
+// Comparator is implemented by filter fields that choose the operator of another field at runtime.
+type Comparator interface {
+	Operator() Operator
+}
+
+// FieldError reports a field of a query parameter that is not supported by the resource.
+type FieldError struct {
+	Parameter string
+	Field     string
+}
+
+func (e *FieldError) Error() string {
+	return "unknown field " + strconv.Quote(e.Field) + " in parameter " + e.Parameter
+}
+
+var ErrInvalidFilter = errors.New("invalid filter definition")
+
+// Query builds parameterized SELECT statements for a table from a filter struct.
+//
+// Filter fields are mapped with the query tag `query:"column,operator[,date][,cmp=Field]"`.
+// Nil pointers, empty strings and empty slices are ignored. The option date compares the
+// date part of the column only and cmp names a field implementing Comparator which overrides
+// the operator. Fields without query tag are not part of the where clause.
+type Query struct {
+	Table   string
+	Columns []string
+	// SortFields maps the sortable field names of the API to their column, sort fields match them
+	// case-insensitively.
+	SortFields map[string]string
+	// DefaultSort is applied if the page does not request a sort order.
+	DefaultSort []string
+}
+
+// GetAll selects all rows matching the filter into dest and stores the total count in page.
+func (q *Query) GetAll(tx Transaction, dest any, page *pagination.Page, filter any) error {
+	where, params, err := Where(filter)
+	if err != nil {
+		return err
+	}
+	orderBy, err := q.OrderBy(page.Sort)
+	if err != nil {
+		return err
+	}
+	stmt := "SELECT " + strings.Join(q.Columns, ", ") + "\n  FROM " + q.Table
+	countStmt := "SELECT COUNT(*) AS total_count\n  FROM " + q.Table
+	if where != "" {
+		stmt += "\n" + where
+		countStmt += "\n" + where
+	}
+	if orderBy != "" {
+		stmt += "\n" + orderBy
+	}
+	selectParams := make(map[string]any, len(params)+2)
+	for k, v := range params {
+		selectParams[k] = v
+	}
+	if page.Limit != -1 {
+		stmt += "\nLIMIT :limit"
+		selectParams["limit"] = page.Limit
+		if page.Offset != 0 {
+			stmt += "\nOFFSET :offset"
+			selectParams["offset"] = page.Offset * page.Limit
+		}
+	}
+	if len(params) > 0 {
+		if err := tx.Select(page, countStmt, params); err != nil {
+			return err
+		}
+	} else {
+		if err := tx.Select(page, countStmt); err != nil {
+			return err
+		}
+	}
+	if len(selectParams) > 0 {
+		return tx.Select(dest, stmt, selectParams)
+	}
+	return tx.Select(dest, stmt)
+}
+
+// OrderBy translates sort fields like "name" or "-start" into an ORDER BY clause.
+func (q *Query) OrderBy(sort []string) (string, error) {
+	if len(sort) == 0 {
+		sort = q.DefaultSort
+	}
+	args := make([]string, 0, len(sort))
+	for _, field := range sort {
+		dir := ""
+		if strings.HasPrefix(field, "-") {
+			field = field[1:]
+			dir = " DESC"
+		}
+		column, ok := q.sortColumn(field)
+		if !ok {
+			return "", &FieldError{Parameter: "sort", Field: field}
+		}
+		args = append(args, column+dir)
+	}
+	if len(args) == 0 {
+		return "", nil
+	}
+	return "ORDER BY " + strings.Join(args, ", "), nil
+}
+
+func (q *Query) sortColumn(field string) (string, bool) {
+	if column, ok := q.SortFields[field]; ok {
+		return column, true
+	}
+	for name, column := range q.SortFields {
+		if strings.EqualFold(name, field) {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+// Where builds the WHERE clause and its named parameters from the query tags of filter.
+func Where(filter any) (string, map[string]any, error) {
+	params := make(map[string]any)
+	if filter == nil {
+		return "", params, nil
+	}
+	v := reflect.ValueOf(filter)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", params, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return "", nil, ErrInvalidFilter
+	}
+	t := v.Type()
+	parts := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := reflectx.Tag(field, "query").Value
+		if tag == "" || tag == "-" {
+			continue
+		}
+		value, ok := filterValue(v.Field(i))
+		if !ok {
+			continue
+		}
+		cond, err := parseCondition(v, field.Name, tag)
+		if err != nil {
+			return "", nil, err
+		}
+		part, err := cond.build(value, params)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "", params, nil
+	}
+	return "WHERE " + strings.Join(parts, " AND "), params, nil
+}
+
+func filterValue(v reflect.Value) (any, bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, false
+		}
+		return v.Elem().Interface(), true
+	case reflect.String, reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			return nil, false
+		}
+	}
+	return v.Interface(), true
+}
+
+type condition struct {
+	param    string
+	column   string
+	operator Operator
+	date     bool
+}
+
+func parseCondition(filter reflect.Value, fieldName, tag string) (*condition, error) {
+	options := strings.Split(tag, ",")
+	if len(options) < 2 {
+		return nil, fmt.Errorf("%w: %s needs column and operator", ErrInvalidFilter, fieldName)
+	}
+	cond := &condition{
+		param:    strings.ToLower(fieldName),
+		column:   options[0],
+		operator: Operator(options[1]),
+	}
+	for _, option := range options[2:] {
+		switch {
+		case option == "date":
+			cond.date = true
+		case strings.HasPrefix(option, "cmp="):
+			cmpField := filter.FieldByName(strings.TrimPrefix(option, "cmp="))
+			if !cmpField.IsValid() || !cmpField.CanInterface() {
+				return nil, fmt.Errorf("%w: comparator of %s not found", ErrInvalidFilter, fieldName)
+			}
+			comparator, ok := cmpField.Interface().(Comparator)
+			if !ok {
+				return nil, fmt.Errorf("%w: comparator of %s is no Comparator", ErrInvalidFilter, fieldName)
+			}
+			cond.operator = comparator.Operator()
+		default:
+			return nil, fmt.Errorf("%w: unknown option %s of %s", ErrInvalidFilter, option, fieldName)
+		}
+	}
+	return cond, nil
+}
+
+func (c *condition) build(value any, params map[string]any) (string, error) {
+	column, placeholder := c.column, ":"+c.param
+	if c.date {
+		column, placeholder = "date("+column+")", "date("+placeholder+")"
+	}
+	switch c.operator {
+	case OpLike:
+		params[c.param] = "%" + strings.ToLower(fmt.Sprint(value)) + "%"
+		return "LOWER(" + column + ") LIKE " + placeholder, nil
+	case OpIsNull:
+		isNull, ok := value.(bool)
+		if !ok {
+			return "", fmt.Errorf("%w: %s must be bool for isnull", ErrInvalidFilter, c.param)
+		}
+		if isNull {
+			return column + " IS NULL", nil
+		}
+		return column + " IS NOT NULL", nil
+	case OpIn:
+		values := reflect.ValueOf(value)
+		if values.Kind() != reflect.Slice {
+			return "", fmt.Errorf("%w: %s must be slice for in", ErrInvalidFilter, c.param)
+		}
+		placeholders := make([]string, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			name := c.param + strconv.Itoa(i)
+			params[name] = values.Index(i).Interface()
+			placeholders[i] = ":" + name
+		}
+		return column + " IN (" + strings.Join(placeholders, ", ") + ")", nil
+	}
+	op, ok := sqlOperators[c.operator]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown operator %s", ErrInvalidFilter, c.operator)
+	}
+	params[c.param] = value
+	return column + " " + op + " " + placeholder, nil
+}
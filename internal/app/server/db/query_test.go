@@ -0,0 +1,162 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/go-libs/testhelper"
+)
+
+type testComparator int
+
+func (c testComparator) Operator() Operator {
+	if c == 1 {
+		return OpGreaterThanOrEqual
+	}
+	return OpEqual
+}
+
+type testFilter struct {
+	ID       *int           `query:"id,eq"`
+	Name     string         `query:"name,like"`
+	IDs      []int          `query:"id,in"`
+	IsOpen   *bool          `query:"ended_at,isnull"`
+	From     *time.Time     `query:"started_at,eq,date,cmp=FromCmp"`
+	FromCmp  testComparator `form:"filter[fromCmp]"`
+	Ignored  string
+	Excluded *int `query:"-"`
+}
+
+func TestWhere(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     any
+		wantClause string
+		wantParams map[string]any
+		wantErr    error
+	}{{
+		name:       "GIVEN empty filter THEN return empty clause",
+		filter:     &testFilter{Ignored: "x", Excluded: testhelper.Ptr(1)},
+		wantClause: "",
+		wantParams: map[string]any{},
+	}, {
+		name:       "GIVEN nil filter THEN return empty clause",
+		filter:     (*testFilter)(nil),
+		wantClause: "",
+		wantParams: map[string]any{},
+	}, {
+		name:       "GIVEN eq and like THEN combine with AND and lower like pattern",
+		filter:     &testFilter{ID: testhelper.Ptr(3), Name: "FoO"},
+		wantClause: "WHERE id = :id AND LOWER(name) LIKE :name",
+		wantParams: map[string]any{"id": 3, "name": "%foo%"},
+	}, {
+		name:       "GIVEN in and isnull THEN expand parameters",
+		filter:     &testFilter{IDs: []int{1, 2}, IsOpen: testhelper.Ptr(false)},
+		wantClause: "WHERE id IN (:ids0, :ids1) AND ended_at IS NOT NULL",
+		wantParams: map[string]any{"ids0": 1, "ids1": 2},
+	}, {
+		name:       "GIVEN comparator and date option THEN use operator of comparator",
+		filter:     &testFilter{From: testhelper.Ptr(testhelper.FixedNow), FromCmp: 1},
+		wantClause: "WHERE date(started_at) >= date(:from)",
+		wantParams: map[string]any{"from": testhelper.FixedNow},
+	}, {
+		name: "GIVEN unknown operator THEN throw ErrInvalidFilter",
+		filter: &struct {
+			A *int `query:"a,between"`
+		}{A: testhelper.Ptr(1)},
+		wantErr: ErrInvalidFilter,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clause, params, err := Where(tt.filter)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Where() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if clause != tt.wantClause {
+				t.Errorf("Where() clause = %q, want %q", clause, tt.wantClause)
+			}
+			if diff := cmp.Diff(tt.wantParams, params); diff != "" {
+				t.Errorf("Where() params mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestQuery_OrderBy(t *testing.T) {
+	q := &Query{SortFields: map[string]string{"start": "started_at", "projectId": "project_id", "id": "id"}, DefaultSort: []string{"id"}}
+	tests := []struct {
+		name    string
+		sort    []string
+		want    string
+		wantErr bool
+	}{{
+		name: "GIVEN no sort THEN use default sort",
+		want: "ORDER BY id",
+	}, {
+		name: "GIVEN descending sort THEN map to column",
+		sort: []string{"-start", "id"},
+		want: "ORDER BY started_at DESC, id",
+	}, {
+		name: "GIVEN field in other case THEN map to column",
+		sort: []string{"projectid", "-Start"},
+		want: "ORDER BY project_id, started_at DESC",
+	}, {
+		name:    "GIVEN unknown field THEN throw FieldError",
+		sort:    []string{"started_at"},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := q.OrderBy(tt.sort)
+			var fieldErr *FieldError
+			if errors.As(err, &fieldErr) != tt.wantErr {
+				t.Fatalf("OrderBy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("OrderBy() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type recordingTx struct {
+	queries []string
+	args    [][]any
+}
+
+func (r *recordingTx) Select(_ any, query string, args ...any) error {
+	r.queries = append(r.queries, query)
+	r.args = append(r.args, args)
+	return nil
+}
+
+func (r *recordingTx) Exec(_ string, _ ...any) (sql.Result, error) {
+	return nil, nil
+}
+
+func TestQuery_GetAll(t *testing.T) {
+	q := &Query{Table: "client", Columns: []string{"id", "name"}, SortFields: map[string]string{"name": "name"}}
+	tx := &recordingTx{}
+	page := &pagination.Page{Limit: 10, Offset: 2, Sort: []string{"-name"}}
+	if err := q.GetAll(tx, &[]struct{}{}, page, &testFilter{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"SELECT COUNT(*) AS total_count\n  FROM client\nWHERE LOWER(name) LIKE :name",
+		"SELECT id, name\n  FROM client\nWHERE LOWER(name) LIKE :name\nORDER BY name DESC\nLIMIT :limit\nOFFSET :offset",
+	}
+	if diff := cmp.Diff(want, tx.queries); diff != "" {
+		t.Errorf("GetAll() queries mismatch (-want +got):\n%s", diff)
+	}
+	wantArgs := map[string]any{"name": "%a%", "limit": 10, "offset": 20}
+	if diff := cmp.Diff(wantArgs, tx.args[1][0]); diff != "" {
+		t.Errorf("GetAll() args mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -1,6 +1,10 @@
 package db
 
-import "github.com/vloryan/go-libs/sqlx/pagination"
+import (
+	"errors"
+
+	"github.com/vloryan/go-libs/sqlx/pagination"
+)
 
 type CRUDRepository[T any, F any] interface {
 	Save(tx Transaction, item T) error
@@ -8,3 +12,6 @@ type CRUDRepository[T any, F any] interface {
 	GetAll(tx Transaction, page *pagination.Page, filter F) ([]T, error)
 	Delete(tx Transaction, id int) error
 }
+
+// ErrNotFound is returned by repositories if the item to update or delete does not exist.
+var ErrNotFound = errors.New("item not found")
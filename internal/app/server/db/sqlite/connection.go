@@ -55,6 +55,9 @@ func WithTransaction(db *sqlx.DB, fn db.TxFunc) (err error) {
 	return err
 }
 
+// ErrForeignKeyCheck is returned if a transaction leaves foreign key violations behind.
+var ErrForeignKeyCheck = errors.New("foreign_key_check failed")
+
 type foreignKeyCheck struct {
 	Table  string
 	RowID  int
@@ -75,15 +78,64 @@ func checkForeignKey(tx *sqlx.Transaction) error {
 	}
 
 	if len(violations) > 0 {
-		return fmt.Errorf("foreign_key_check failed:%s", strings.Join(violations, ", "))
+		return fmt.Errorf("%w:%s", ErrForeignKeyCheck, strings.Join(violations, ", "))
 	}
 	return nil
 }
 
 func IsFkConstraintFailed(err error) bool {
+	if errors.Is(err, ErrForeignKeyCheck) {
+		return true
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey
+	}
+	return false
+}
+
+// IsConstraintFailed reports whether err was caused by any constraint, e.g. NOT NULL or UNIQUE.
+func IsConstraintFailed(err error) bool {
 	var sqliteErr sqlite3.Error
 	if errors.As(err, &sqliteErr) {
 		return errors.Is(sqliteErr.Code, sqlite3.ErrConstraint)
 	}
 	return false
 }
+
+type integrityCheck struct {
+	IntegrityCheck string
+}
+
+// Check verifies the integrity of the database file and its foreign keys.
+func (c *Connection) Check() error {
+	return c.DoTransaction(func(tx db.Transaction) error {
+		var results []*integrityCheck
+		if err := tx.Select(&results, "PRAGMA integrity_check;"); err != nil {
+			return err
+		}
+		var problems []string
+		for _, result := range results {
+			if result.IntegrityCheck != "ok" {
+				problems = append(problems, result.IntegrityCheck)
+			}
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("integrity_check failed: %s", strings.Join(problems, ", "))
+		}
+		// the foreign keys are checked by WithTransaction
+		return nil
+	})
+}
+
+// Backup writes a consistent copy of the database to fileName, which must not exist.
+// It can be used while other connections write to the database.
+func (c *Connection) Backup(fileName string) error {
+	return Backup(c, fileName)
+}
+
+// Backup writes a consistent copy of the database of con to fileName, which must not exist.
+func Backup(con db.Transaction, fileName string) error {
+	_, err := con.Exec("VACUUM INTO ?;", fileName)
+	return err
+}
@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"database/sql"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+const benchSlotCount = 100_000
+
+type benchSlot struct {
+	ID    int
+	Start time.Time
+}
+
+var (
+	benchQuery = &db.Query{
+		Table:      "slot",
+		Columns:    []string{"id", "started_at"},
+		SortFields: map[string]string{"start": "started_at", "id": "id"},
+	}
+	benchKeyset = &db.Keyset[*benchSlot]{
+		Columns: []string{"started_at", "id"},
+		Values: func(slot *benchSlot) []any {
+			return []any{slot.Start, slot.ID}
+		},
+		Targets: func() []any {
+			return []any{&time.Time{}, new(int)}
+		},
+	}
+)
+
+// benchTx executes the statements of db.Query with database/sql. Named parameters are passed
+// with sql.Named, rows are scanned into the fields of the destination items in column order.
+type benchTx struct {
+	db *sql.DB
+}
+
+func (t *benchTx) Exec(query string, args ...any) (sql.Result, error) {
+	return t.db.Exec(query, namedArgs(args)...)
+}
+
+func (t *benchTx) Select(dest any, query string, args ...any) error {
+	rows, err := t.db.Query(query, namedArgs(args)...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	slice := reflect.ValueOf(dest).Elem()
+	if slice.Kind() != reflect.Slice {
+		// count queries are executed but their result is not needed
+		for rows.Next() {
+		}
+		return rows.Err()
+	}
+	for rows.Next() {
+		item := reflect.New(slice.Type().Elem().Elem())
+		fields := make([]any, item.Elem().NumField())
+		for i := range fields {
+			fields[i] = item.Elem().Field(i).Addr().Interface()
+		}
+		if err := rows.Scan(fields...); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, item))
+	}
+	return rows.Err()
+}
+
+func namedArgs(args []any) []any {
+	var named []any
+	for _, arg := range args {
+		if m, ok := arg.(map[string]any); ok {
+			for k, v := range m {
+				named = append(named, sql.Named(k, v))
+			}
+		}
+	}
+	return named
+}
+
+func setupBenchDB(b *testing.B) *benchTx {
+	b.Helper()
+	con, err := sql.Open("sqlite3", "file:"+b.TempDir()+"/bench.db")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = con.Close() })
+	stmts := []string{
+		`CREATE TABLE slot (id INTEGER PRIMARY KEY, started_at TIMESTAMP NOT NULL)`,
+		`CREATE INDEX slot_started_at ON slot (started_at, id)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := con.Exec(stmt); err != nil {
+			b.Fatal(err)
+		}
+	}
+	tx, err := con.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	start := time.Date(2015, 1, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < benchSlotCount; i++ {
+		if _, err := tx.Exec(`INSERT INTO slot (started_at) VALUES (?)`, start.Add(time.Duration(i)*time.Hour)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	return &benchTx{db: con}
+}
+
+// BenchmarkOffsetPagination loads the page at the given depth with LIMIT/OFFSET and COUNT(*).
+func BenchmarkOffsetPagination(b *testing.B) {
+	tx := setupBenchDB(b)
+	for _, depth := range []int{0, 1_000, 1_900} {
+		b.Run("page="+strconv.Itoa(depth), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var items []*benchSlot
+				page := &pagination.Page{Limit: 50, Offset: depth, Sort: []string{"start", "id"}}
+				if err := benchQuery.GetAll(tx, &items, page, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkKeysetPagination loads the page at the given depth following its cursor.
+func BenchmarkKeysetPagination(b *testing.B) {
+	tx := setupBenchDB(b)
+	cursors := make(map[int]string)
+	page := &db.CursorPage{Size: 50}
+	for depth := 1; depth <= 1_900; depth++ {
+		if _, err := db.GetPage(tx, benchQuery, benchKeyset, page, nil); err != nil {
+			b.Fatal(err)
+		}
+		cursors[depth] = page.Next
+		page = &db.CursorPage{Size: 50, After: page.Next}
+	}
+	for _, depth := range []int{0, 1_000, 1_900} {
+		b.Run("page="+strconv.Itoa(depth), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				page := &db.CursorPage{Size: 50, After: cursors[depth]}
+				if _, err := db.GetPage(tx, benchQuery, benchKeyset, page, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
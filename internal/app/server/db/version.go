@@ -0,0 +1,47 @@
+package db
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrVersionConflict is returned when an item was changed since it has been read.
+var ErrVersionConflict = errors.New("item was modified concurrently")
+
+// Revision returns a number changing whenever a row of one of the tables is inserted, updated or deleted.
+func Revision(tx Transaction, tables ...string) (int, error) {
+	placeholders := make([]string, len(tables))
+	params := make(map[string]any, len(tables))
+	for i, table := range tables {
+		name := "table" + strconv.Itoa(i)
+		placeholders[i] = ":" + name
+		params[name] = table
+	}
+	result := &struct {
+		Revision int `db:"revision"`
+	}{}
+	stmt := `SELECT COALESCE(SUM(value), 0) AS revision
+			   FROM revision
+			  WHERE name IN (` + strings.Join(placeholders, ", ") + `)`
+	if err := tx.Select(result, stmt, params); err != nil {
+		return 0, err
+	}
+	return result.Revision, nil
+}
+
+// UpdateError returns the error of an update of the row id of table that affected no rows.
+// It is ErrVersionConflict if the row exists, the version of the update was outdated then,
+// and ErrNotFound otherwise.
+func UpdateError(tx Transaction, table string, id int) error {
+	result := &struct {
+		Count int `db:"count"`
+	}{}
+	if err := tx.Select(result, "SELECT COUNT(*) AS count FROM "+table+" WHERE id = :id", map[string]any{"id": id}); err != nil {
+		return err
+	}
+	if result.Count > 0 {
+		return ErrVersionConflict
+	}
+	return ErrNotFound
+}
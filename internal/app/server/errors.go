@@ -0,0 +1,276 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/db/sqlite"
+	"github.com/vloryan/protrakgon/internal/app/server/validate"
+)
+
+// ErrorSource references the part of the request that caused an Error.
+type ErrorSource struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+	Header    string `json:"header,omitempty"`
+}
+
+// Error is a JSON:API error object. Handlers served by Handle return it or any other error, domain and
+// database errors are mapped to an Error with a proper status.
+type Error struct {
+	Status int
+	Code   string
+	Title  string
+	Detail string
+	Source *ErrorSource
+	Meta   map[string]any
+	Err    error
+}
+
+func NewError(status int, code, title string) *Error {
+	return &Error{Status: status, Code: code, Title: title}
+}
+
+// NotFound reports that the resource of resourceType with id does not exist.
+func NotFound(resourceType string, id int) *Error {
+	return NewError(http.StatusNotFound, "not_found", resourceType+" with id "+strconv.Itoa(id)+" not found").
+		WithMeta("type", resourceType).
+		WithMeta("id", strconv.Itoa(id))
+}
+
+// InvalidBody reports a request document that could not be bound to a resource.
+func InvalidBody(err error) *Error {
+	return NewError(http.StatusBadRequest, "invalid_body", "invalid request document").
+		WithPointer("/data").
+		Wrap(err)
+}
+
+// InvalidQuery reports query parameters that could not be bound to a filter.
+func InvalidQuery(err error) error {
+	var fieldErr *db.FieldError
+	if errors.As(err, &fieldErr) {
+		return err
+	}
+	return NewError(http.StatusBadRequest, "invalid_parameter", "invalid query parameter").Wrap(err)
+}
+
+// IDMismatch reports an id of the request document differing from the id of the url.
+func IDMismatch(pointer string) *Error {
+	return NewError(http.StatusConflict, "id_mismatch", "id of url does not match with request document").
+		WithPointer(pointer)
+}
+
+// TypeMismatch reports a type of the request document differing from the type of the endpoint.
+func TypeMismatch(pointer string) *Error {
+	return NewError(http.StatusConflict, "type_mismatch", "type of request document does not match with endpoint").
+		WithPointer(pointer)
+}
+
+func (e *Error) WithPointer(pointer string) *Error {
+	e.Source = &ErrorSource{Pointer: pointer}
+	return e
+}
+
+func (e *Error) WithParameter(parameter string) *Error {
+	e.Source = &ErrorSource{Parameter: parameter}
+	return e
+}
+
+func (e *Error) WithHeader(header string) *Error {
+	e.Source = &ErrorSource{Header: header}
+	return e
+}
+
+func (e *Error) WithMeta(key string, value any) *Error {
+	if e.Meta == nil {
+		e.Meta = make(map[string]any)
+	}
+	e.Meta[key] = value
+	return e
+}
+
+// Wrap sets the cause of the error, its message is used as detail.
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	if err != nil {
+		e.Detail = err.Error()
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return e.Title
+	}
+	return e.Title + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Status string         `json:"status"`
+		Code   string         `json:"code,omitempty"`
+		Title  string         `json:"title"`
+		Detail string         `json:"detail,omitempty"`
+		Source *ErrorSource   `json:"source,omitempty"`
+		Meta   map[string]any `json:"meta,omitempty"`
+	}{
+		Status: strconv.Itoa(e.Status),
+		Code:   e.Code,
+		Title:  e.Title,
+		Detail: e.Detail,
+		Source: e.Source,
+		Meta:   e.Meta,
+	})
+}
+
+type errorMapping struct {
+	target  error
+	status  int
+	code    string
+	pointer string
+}
+
+var errorMappings []errorMapping
+
+// RegisterError maps errors matching target to status and code. The pointer references the member
+// of the request document causing the error, e.g. "/data/attributes/end", and may be empty.
+func RegisterError(target error, status int, code, pointer string) {
+	errorMappings = append(errorMappings, errorMapping{target: target, status: status, code: code, pointer: pointer})
+}
+
+// Handle serves fn with handler. The error returned by fn is translated and written as error document
+// with code, source and meta of the error objects, the response of handler is dropped then.
+func Handle[T jsonapi.IdentifiableObject](handler *jsonapi.GenericHandler[T], fn func(req *http.Request) (*jsonapi.DocumentData[T], error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		hw := &handledWriter{ResponseWriter: w}
+		handler.Handle(func(req *http.Request) (*jsonapi.DocumentData[T], *jsonapi.Error) {
+			data, err := fn(req)
+			if err == nil {
+				return data, nil
+			}
+			apiErrs := translateAll(req, err)
+			writeErrors(w, apiErrs[0].Status, apiErrs...)
+			hw.handled = true
+			return nil, jsonapi.NewError(apiErrs[0].Status, apiErrs[0].Title, err)
+		})(hw, req)
+	}
+}
+
+// handledWriter drops the response once the error document of Handle is written.
+type handledWriter struct {
+	http.ResponseWriter
+	handled bool
+}
+
+func (w *handledWriter) WriteHeader(status int) {
+	if !w.handled {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *handledWriter) Write(b []byte) (int, error) {
+	if w.handled {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// TranslateError maps err to the error of callbacks of jsonapi.GenericHandler, e.g. of
+// ResolveObjectWithReqFunc. It has the status and title of the first error object of err.
+func TranslateError(req *http.Request, err error) *jsonapi.Error {
+	var jErr *jsonapi.Error
+	if errors.As(err, &jErr) {
+		return jErr
+	}
+	apiErrs := translateAll(req, err)
+	return jsonapi.NewError(apiErrs[0].Status, apiErrs[0].Title, err)
+}
+
+// translateAll returns an error object for each field error of validation errors and
+// the error object of translate for any other error.
+func translateAll(req *http.Request, err error) []*Error {
+	var fieldErrs validate.Errors
+	if !errors.As(err, &fieldErrs) {
+		return []*Error{translate(req, err)}
+	}
+	apiErrs := make([]*Error, len(fieldErrs))
+	for i, fieldErr := range fieldErrs {
+		apiErrs[i] = NewError(http.StatusUnprocessableEntity, "validation_failed", "validation failed").
+			WithPointer(fieldErr.Pointer).
+			WithMeta("rule", fieldErr.Rule)
+		apiErrs[i].Detail = fieldErr.Pointer[strings.LastIndex(fieldErr.Pointer, "/")+1:] + " " + fieldErr.Message
+	}
+	return apiErrs
+}
+
+func translate(req *http.Request, err error) *Error {
+	// a cut body fails to decode, whatever error the handler made of it
+	if apiErr := bodyTooLarge(err); apiErr != nil {
+		return apiErr
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	for _, mapping := range errorMappings {
+		if errors.Is(err, mapping.target) {
+			apiErr = NewError(mapping.status, mapping.code, mapping.target.Error()).Wrap(err)
+			if mapping.pointer != "" {
+				apiErr.WithPointer(mapping.pointer)
+			}
+			return apiErr
+		}
+	}
+	var fieldErr *db.FieldError
+	switch {
+	case errors.As(err, &fieldErr):
+		return NewError(http.StatusBadRequest, "invalid_parameter", "unsupported field").
+			WithParameter(fieldErr.Parameter).
+			WithMeta("field", fieldErr.Field).
+			Wrap(err)
+	case errors.Is(err, db.ErrInvalidCursor):
+		return NewError(http.StatusBadRequest, "invalid_cursor", "invalid page cursor").
+			WithParameter("page").
+			Wrap(err)
+	case errors.Is(err, db.ErrNotFound):
+		return NewError(http.StatusNotFound, "not_found", "item not found").Wrap(err)
+	case errors.Is(err, db.ErrVersionConflict):
+		return NewError(http.StatusConflict, "version_conflict", "item was modified concurrently, reload and retry").
+			WithPointer("/data/attributes/version").
+			Wrap(err)
+	case sqlite.IsFkConstraintFailed(err):
+		if req.Method == http.MethodDelete {
+			return NewError(http.StatusConflict, "still_referenced", "item is still referenced").Wrap(err)
+		}
+		return NewError(http.StatusUnprocessableEntity, "invalid_reference", "related item does not exist").
+			WithPointer("/data/relationships").
+			Wrap(err)
+	case sqlite.IsConstraintFailed(err):
+		return NewError(http.StatusUnprocessableEntity, "constraint_violation", "item violates a constraint").
+			WithPointer("/data").
+			Wrap(err)
+	}
+	log.Err(err).Str("method", req.Method).Str("path", req.URL.Path).Msg("request failed")
+	return NewError(http.StatusInternalServerError, "internal_error", http.StatusText(http.StatusInternalServerError)).Wrap(err)
+}
+
+// writeErrorDocument writes a JSON:API error document with a single error object.
+func writeErrorDocument(w http.ResponseWriter, status int, title string, err error) {
+	writeErrors(w, status, NewError(status, "", title).Wrap(err))
+}
+
+func writeErrors(w http.ResponseWriter, status int, errs ...*Error) {
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"errors": errs})
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/db/sqlite"
+	"github.com/vloryan/protrakgon/internal/app/server/validate"
+)
+
+var errTestDomain = errors.New("test domain error")
+
+func TestTranslate(t *testing.T) {
+	RegisterError(errTestDomain, http.StatusUnprocessableEntity, "test_domain", "/data/attributes/name")
+	tests := []struct {
+		name        string
+		method      string
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantPointer string
+	}{{
+		name:        "GIVEN registered domain error THEN use its mapping",
+		method:      http.MethodPost,
+		err:         fmt.Errorf("wrapped: %w", errTestDomain),
+		wantStatus:  http.StatusUnprocessableEntity,
+		wantCode:    "test_domain",
+		wantPointer: "/data/attributes/name",
+	}, {
+		name:       "GIVEN api error THEN keep it",
+		method:     http.MethodGet,
+		err:        NotFound("client", 3),
+		wantStatus: http.StatusNotFound,
+		wantCode:   "not_found",
+	}, {
+		name:       "GIVEN field error THEN return bad request",
+		method:     http.MethodGet,
+		err:        &db.FieldError{Parameter: "sort", Field: "x"},
+		wantStatus: http.StatusBadRequest,
+		wantCode:   "invalid_parameter",
+	}, {
+		name:        "GIVEN version conflict THEN return conflict",
+		method:      http.MethodPatch,
+		err:         db.ErrVersionConflict,
+		wantStatus:  http.StatusConflict,
+		wantCode:    "version_conflict",
+		wantPointer: "/data/attributes/version",
+	}, {
+		name:       "GIVEN missing item THEN return not found",
+		method:     http.MethodDelete,
+		err:        db.ErrNotFound,
+		wantStatus: http.StatusNotFound,
+		wantCode:   "not_found",
+	}, {
+		name:       "GIVEN foreign key violation on delete THEN return conflict",
+		method:     http.MethodDelete,
+		err:        fmt.Errorf("%w: project", sqlite.ErrForeignKeyCheck),
+		wantStatus: http.StatusConflict,
+		wantCode:   "still_referenced",
+	}, {
+		name:        "GIVEN foreign key violation on create THEN return unprocessable entity",
+		method:      http.MethodPost,
+		err:         fmt.Errorf("%w: project", sqlite.ErrForeignKeyCheck),
+		wantStatus:  http.StatusUnprocessableEntity,
+		wantCode:    "invalid_reference",
+		wantPointer: "/data/relationships",
+	}, {
+		name:       "GIVEN unknown error THEN return internal server error",
+		method:     http.MethodGet,
+		err:        errors.New("boom"),
+		wantStatus: http.StatusInternalServerError,
+		wantCode:   "internal_error",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := translate(httptest.NewRequest(tt.method, "/client", nil), tt.err)
+			if got.Status != tt.wantStatus {
+				t.Errorf("translate() status = %d, want %d", got.Status, tt.wantStatus)
+			}
+			if got.Code != tt.wantCode {
+				t.Errorf("translate() code = %s, want %s", got.Code, tt.wantCode)
+			}
+			pointer := ""
+			if got.Source != nil {
+				pointer = got.Source.Pointer
+			}
+			if pointer != tt.wantPointer {
+				t.Errorf("translate() pointer = %s, want %s", pointer, tt.wantPointer)
+			}
+		})
+	}
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantBody   string
+	}{{
+		name:       "GIVEN no error THEN write document of handler",
+		wantStatus: http.StatusOK,
+		wantBody:   `{"data":null}`,
+	}, {
+		name:       "GIVEN error THEN write error objects",
+		err:        fmt.Errorf("load: %w", NotFound("client", 3)),
+		wantStatus: http.StatusNotFound,
+		wantBody:   `{"errors":[{"status":"404","code":"not_found","title":"client with id 3 not found","meta":{"id":"3","type":"client"}}]}` + "\n",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Handle(&jsonapi.GenericHandler[*testItem]{}, func(_ *http.Request) (*jsonapi.DocumentData[*testItem], error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return jsonapi.NewDocumentData[*testItem]([]*testItem{}, "/test"), nil
+			})
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodGet, "/client/3", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Handle() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if diff := cmp.Diff(tt.wantBody, rec.Body.String()); diff != "" {
+				t.Errorf("Handle() body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTranslateAll(t *testing.T) {
+	err := validate.Errors{
+		validate.Attribute("name", "required", "must not be empty"),
+		validate.Relationship("client", "required", "must not be empty"),
+	}
+	got := translateAll(httptest.NewRequest(http.MethodPost, "/project", nil), fmt.Errorf("save: %w", err))
+	want := []*Error{
+		NewError(http.StatusUnprocessableEntity, "validation_failed", "validation failed").
+			WithPointer("/data/attributes/name").
+			WithMeta("rule", "required"),
+		NewError(http.StatusUnprocessableEntity, "validation_failed", "validation failed").
+			WithPointer("/data/relationships/client").
+			WithMeta("rule", "required"),
+	}
+	want[0].Detail = "name must not be empty"
+	want[1].Detail = "client must not be empty"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("translateAll() mismatch (-want +got):\n%s", diff)
+	}
+}
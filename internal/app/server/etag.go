@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+type ctxKeyETag struct{}
+
+// entityTag is the ETag of a response, set by the handler while it reads the resources in its transaction.
+type entityTag struct {
+	value string
+}
+
+// ETag serves next with support of SetETag: responses with status 200 get the ETag header and a GET
+// request with a matching If-None-Match header is answered with 304 Not Modified and without body.
+func ETag(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		tag := &entityTag{}
+		req = req.WithContext(context.WithValue(req.Context(), ctxKeyETag{}, tag))
+		next(&etagWriter{ResponseWriter: w, req: req, tag: tag}, req)
+	}
+}
+
+// SetETag sets the ETag of the response to req. It has no effect outside of ETag.
+func SetETag(req *http.Request, etag string) {
+	if tag, ok := req.Context().Value(ctxKeyETag{}).(*entityTag); ok {
+		tag.value = etag
+	}
+}
+
+// versionETag returns the strong ETag of a resource version, which is compared with If-Match by CheckIfMatch.
+func versionETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// CollectionETag sets a weak ETag of the collection response to req that changes whenever a row of one
+// of the tables is changed. Include all tables the response depends on, e.g. the tables of included
+// resources. It reports whether the If-None-Match header matches, the handler can skip reading the
+// collection then as the response will be 304 Not Modified.
+func CollectionETag(req *http.Request, tx db.Transaction, tables ...string) (bool, error) {
+	if len(tables) == 0 {
+		return false, nil
+	}
+	revision, err := db.Revision(tx, tables...)
+	if err != nil {
+		return false, err
+	}
+	// the query determines filter, page, sort and includes of the collection
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(req.URL.RawQuery))
+	etag := fmt.Sprintf(`W/"%d-%x"`, revision, hash.Sum32())
+	SetETag(req, etag)
+	return req.Method == http.MethodGet && matchesETag(req.Header.Get("If-None-Match"), etag), nil
+}
+
+// etagWriter adds the ETag header when the status is written and drops the body of 304 responses.
+type etagWriter struct {
+	http.ResponseWriter
+	req         *http.Request
+	tag         *entityTag
+	wroteHeader bool
+	notModified bool
+}
+
+func (w *etagWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if status == http.StatusOK && w.tag.value != "" {
+		w.Header().Set("ETag", w.tag.value)
+		if w.req.Method == http.MethodGet && matchesETag(w.req.Header.Get("If-None-Match"), w.tag.value) {
+			w.notModified = true
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Length")
+			status = http.StatusNotModified
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notModified {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *etagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// CheckIfMatch compares the If-Match header of req with the ETag of version. It returns
+// 412 Precondition Failed if they do not match and nil if they match or the header is missing.
+func CheckIfMatch(req *http.Request, version int) error {
+	ifMatch := req.Header.Get("If-Match")
+	if ifMatch == "" || ifMatch == "*" {
+		return nil
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		// If-Match uses the strong comparison, weak tags never match
+		if strings.TrimSpace(tag) == versionETag(version) {
+			return nil
+		}
+	}
+	return NewError(http.StatusPreconditionFailed, "precondition_failed", "item was modified").
+		WithHeader("If-Match").
+		WithMeta("version", version)
+}
+
+// matchesETag implements the weak comparison of If-None-Match.
+func matchesETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	client := `{"data":{"type":"client","id":"1","attributes":{"name":"A","version":3}}}`
+	tests := []struct {
+		name        string
+		method      string
+		ifNoneMatch string
+		etag        string
+		status      int
+		wantStatus  int
+		wantETag    string
+		wantBody    string
+	}{{
+		name:       "GIVEN ETag of handler THEN set ETag",
+		method:     http.MethodGet,
+		etag:       `"3"`,
+		status:     http.StatusOK,
+		wantStatus: http.StatusOK,
+		wantETag:   `"3"`,
+		wantBody:   client,
+	}, {
+		name:        "GIVEN matching If-None-Match THEN return not modified",
+		method:      http.MethodGet,
+		ifNoneMatch: `"2", "3"`,
+		etag:        `"3"`,
+		status:      http.StatusOK,
+		wantStatus:  http.StatusNotModified,
+		wantETag:    `"3"`,
+	}, {
+		name:        "GIVEN weak ETag matching If-None-Match THEN return not modified",
+		method:      http.MethodGet,
+		ifNoneMatch: `W/"7-a"`,
+		etag:        `W/"7-a"`,
+		status:      http.StatusOK,
+		wantStatus:  http.StatusNotModified,
+		wantETag:    `W/"7-a"`,
+	}, {
+		name:        "GIVEN outdated If-None-Match THEN return resource",
+		method:      http.MethodGet,
+		ifNoneMatch: `"2"`,
+		etag:        `"3"`,
+		status:      http.StatusOK,
+		wantStatus:  http.StatusOK,
+		wantETag:    `"3"`,
+		wantBody:    client,
+	}, {
+		name:        "GIVEN matching If-None-Match on PATCH THEN return resource",
+		method:      http.MethodPatch,
+		ifNoneMatch: `"3"`,
+		etag:        `"3"`,
+		status:      http.StatusOK,
+		wantStatus:  http.StatusOK,
+		wantETag:    `"3"`,
+		wantBody:    client,
+	}, {
+		name:       "GIVEN no ETag of handler THEN set no ETag",
+		method:     http.MethodGet,
+		status:     http.StatusOK,
+		wantStatus: http.StatusOK,
+		wantBody:   client,
+	}, {
+		name:        "GIVEN error response THEN set no ETag",
+		method:      http.MethodGet,
+		ifNoneMatch: `"3"`,
+		etag:        `"3"`,
+		status:      http.StatusNotFound,
+		wantStatus:  http.StatusNotFound,
+		wantBody:    client,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ETag(func(w http.ResponseWriter, req *http.Request) {
+				if tt.etag != "" {
+					SetETag(req, tt.etag)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(client))
+			})
+			req := httptest.NewRequest(tt.method, "/client/1", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("ETag() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag() header = %q, want %q", got, tt.wantETag)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("ETag() body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		version int
+		wantErr bool
+	}{{
+		name:    "GIVEN no If-Match THEN accept",
+		version: 2,
+	}, {
+		name:    "GIVEN wildcard THEN accept",
+		ifMatch: "*",
+		version: 2,
+	}, {
+		name:    "GIVEN current version THEN accept",
+		ifMatch: `"1", "2"`,
+		version: 2,
+	}, {
+		name:    "GIVEN outdated version THEN fail",
+		ifMatch: `"1"`,
+		version: 2,
+		wantErr: true,
+	}, {
+		name:    "GIVEN weak tag THEN fail",
+		ifMatch: `W/"2"`,
+		version: 2,
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/client/1", nil)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			if got := CheckIfMatch(req, tt.version); (got != nil) != tt.wantErr {
+				t.Errorf("CheckIfMatch() error = %v, wantErr %v", got, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+// healthStatus is the body of the health endpoints.
+type healthStatus struct {
+	Status        string `json:"status"`
+	Reason        string `json:"reason,omitempty"`
+	SchemaVersion uint   `json:"schemaVersion,omitempty"`
+}
+
+// setupMonitoring registers the endpoints used by container runtimes and monitoring below the context root:
+//
+//	GET /healthz  the process is up
+//	GET /readyz   the database is reachable and migrated to the expected version
+//	GET /metrics  the metrics in the Prometheus text format
+func (svr *Server) setupMonitoring(database db.Connection) {
+	Monitor.RegisterGauge("protrakgon_schema_version", "Migration version of the database.", func(_ db.Transaction) (float64, error) {
+		info, err := svr.migrator.Version()
+		if err != nil {
+			return 0, err
+		}
+		return float64(info.CurrentVersion), nil
+	})
+	svr.Router.HandleMethod(http.MethodGet, path.Join(svr.ContextRoot, "healthz"), func(w http.ResponseWriter, _ *http.Request) {
+		writeHealth(w, http.StatusOK, &healthStatus{Status: "ok"})
+	})
+	svr.Router.HandleMethod(http.MethodGet, path.Join(svr.ContextRoot, "readyz"), svr.ready)
+	svr.Router.HandleMethod(http.MethodGet, path.Join(svr.ContextRoot, "metrics"), Monitor.Handler(database))
+}
+
+// ready reports whether the server accepts requests. It is not ready while shutting down, if the database
+// is unreachable or if its version differs from the latest migration.
+func (svr *Server) ready(w http.ResponseWriter, _ *http.Request) {
+	if svr.stopping.Load() {
+		writeHealth(w, http.StatusServiceUnavailable, &healthStatus{Status: "unavailable", Reason: "shutting down"})
+		return
+	}
+	info, err := svr.migrator.Version()
+	if err != nil {
+		writeHealth(w, http.StatusServiceUnavailable, &healthStatus{Status: "unavailable", Reason: "database unreachable: " + err.Error()})
+		return
+	}
+	switch {
+	case info.Dirty:
+		writeHealth(w, http.StatusServiceUnavailable, &healthStatus{
+			Status: "unavailable", Reason: fmt.Sprintf("migration %d failed", info.CurrentVersion), SchemaVersion: info.CurrentVersion,
+		})
+	case info.CurrentVersion != svr.schemaVersion:
+		writeHealth(w, http.StatusServiceUnavailable, &healthStatus{
+			Status: "unavailable", Reason: fmt.Sprintf("schema version %d, expected %d", info.CurrentVersion, svr.schemaVersion), SchemaVersion: info.CurrentVersion,
+		})
+	default:
+		writeHealth(w, http.StatusOK, &healthStatus{Status: "ok", SchemaVersion: info.CurrentVersion})
+	}
+}
+
+func writeHealth(w http.ResponseWriter, status int, body *healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestServer_ready(t *testing.T) {
+	srv := New().
+		WithDBFile(filepath.Join(t.TempDir(), "test.db")).
+		WithAssets(os.DirFS("../../../assets"))
+	connection, _, err := srv.setupDB()
+	if err != nil {
+		t.Fatalf("setupDB() error = %v", err)
+	}
+	defer func() { _ = connection.Close() }()
+	latest := srv.schemaVersion
+	tests := []struct {
+		name          string
+		schemaVersion uint
+		stopping      bool
+		wantStatus    int
+		want          *healthStatus
+	}{{
+		name:          "GIVEN migrated database THEN ready",
+		schemaVersion: latest,
+		wantStatus:    http.StatusOK,
+		want:          &healthStatus{Status: "ok", SchemaVersion: latest},
+	}, {
+		name:          "GIVEN other expected version THEN not ready",
+		schemaVersion: latest + 1,
+		wantStatus:    http.StatusServiceUnavailable,
+		want:          &healthStatus{Status: "unavailable", Reason: fmt.Sprintf("schema version %d, expected %d", latest, latest+1), SchemaVersion: latest},
+	}, {
+		name:          "GIVEN shutdown THEN not ready",
+		schemaVersion: latest,
+		stopping:      true,
+		wantStatus:    http.StatusServiceUnavailable,
+		want:          &healthStatus{Status: "unavailable", Reason: "shutting down"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.schemaVersion = tt.schemaVersion
+			srv.stopping.Store(tt.stopping)
+			rec := httptest.NewRecorder()
+
+			srv.ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("ready() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			got := &healthStatus{}
+			if err := json.Unmarshal(rec.Body.Bytes(), got); err != nil {
+				t.Fatalf("ready() invalid body %s", rec.Body.String())
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("ready() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"net/http"
 	"net/url"
 
 	"github.com/vloryan/go-libs/httpx/router"
@@ -44,19 +45,25 @@ type JsonAPIModule struct {
 	exts     []Extension
 }
 
-func (m *JsonAPIModule) WithExtension(ext Extension) Module {
+func (m *JsonAPIModule) WithExtension(ext Extension) *JsonAPIModule {
 	m.exts = append(m.exts, ext)
 	return m
 }
 
 func (m *JsonAPIModule) Setup(route router.RouteElement) {
 	SelfLinkUpdaterInstance.contextPath = route.Path()
+	route = APIDoc.Routes(route)
 	for _, h := range m.handlers {
 		h.RegisterRoutes(route)
 	}
 	for _, e := range m.exts {
 		e.Apply(route)
 	}
+	route.GET("openapi.json", APIDoc.Serve)
+	APIDoc.Describe(route, http.MethodGet, "openapi.json", &Operation{
+		Summary:  "OpenAPI document of this API",
+		Response: &Content{MediaType: "application/json", Schema: Schema{"type": "object"}},
+	})
 }
 
 type SelfLinkUpdater struct {
@@ -94,3 +101,11 @@ func (u *SelfLinkUpdater) updateSelfLink(obj *jsonapi.ResourceObject) error {
 func (u *SelfLinkUpdater) ContextPath() string {
 	return u.contextPath
 }
+
+func joinSelfLink(link string) string {
+	joined, err := url.JoinPath(SelfLinkUpdaterInstance.ContextPath(), link)
+	if err != nil {
+		return link
+	}
+	return joined
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+// DefaultShutdownTimeout is the time in-flight requests get to finish when the server stops.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Starter is implemented by modules with background work like webhooks or reminders. Start is called
+// before the server accepts requests, ctx is canceled when the server stops.
+type Starter interface {
+	Start(ctx context.Context, database db.Connection) error
+}
+
+// Stopper is implemented by modules which have to clean up. Stop is called after the in-flight requests
+// are drained and before the database is closed, in reverse order of the modules.
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// WithShutdownTimeout sets the time in-flight requests and stop hooks get when the server stops.
+func (svr *Server) WithShutdownTimeout(timeout time.Duration) *Server {
+	svr.shutdownTimeout = timeout
+	return svr
+}
+
+// Run serves until the process receives SIGINT or SIGTERM, see Serve.
+func (svr *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return svr.Serve(ctx)
+}
+
+// Serve migrates the database, starts the modules and the background workers and serves requests until
+// ctx is done or a listener fails. Then it stops accepting requests, waits up to the shutdown timeout for
+// in-flight requests, stops the modules and workers and closes the database.
+func (svr *Server) Serve(ctx context.Context) (err error) {
+	connection, _, err := svr.setupDB()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := connection.Close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+		log.Info().Msg("database closed")
+	}()
+	if err := svr.setup(connection); err != nil {
+		return err
+	}
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	var started []Module
+	defer func() {
+		stopWorkers()
+		err = errors.Join(err, svr.stopModules(started))
+		// workers finish their current work, e.g. a backup, before the database is closed
+		workers.Wait()
+	}()
+	if svr.backups != nil && svr.backupInterval > 0 {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			svr.backups.Schedule(workerCtx, connection, svr.backupInterval)
+		}()
+	}
+	if svr.tracer != nil {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			svr.tracer.Run(workerCtx)
+		}()
+	}
+	database := db.WithStatementLogger(connection, Monitor.ObserveQuery).WithSlowQueryThreshold(svr.slowQuery)
+	for _, module := range svr.Modules() {
+		if starter, ok := module.(Starter); ok {
+			if err := starter.Start(workerCtx, database); err != nil {
+				return err
+			}
+		}
+		started = append(started, module)
+	}
+
+	listenErr := make(chan error, 2)
+	go func() {
+		listenErr <- svr.listen()
+	}()
+	if svr.Redirect != nil {
+		go func() {
+			listenErr <- svr.Redirect.ListenAndServe()
+		}()
+	}
+	log.Info().Str("address", svr.HTTP.Addr).Bool("tls", svr.tls != nil).Msg("server started")
+
+	select {
+	case <-ctx.Done():
+		log.Info().Msg("shutting down")
+	case err = <-listenErr:
+		log.Err(err).Msg("listener failed, shutting down")
+	}
+	svr.stopping.Store(true)
+	return errors.Join(err, svr.shutdown())
+}
+
+func (svr *Server) listen() error {
+	if svr.tls == nil {
+		return svr.HTTP.ListenAndServe()
+	}
+	// the certificate is provided by TLSConfig.GetCertificate
+	return svr.HTTP.ListenAndServeTLS("", "")
+}
+
+// shutdown stops the listeners and waits for in-flight requests.
+func (svr *Server) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), svr.shutdownTimeout)
+	defer cancel()
+	var errs []error
+	for _, s := range []*http.Server{svr.HTTP, svr.Redirect} {
+		if s == nil {
+			continue
+		}
+		if err := s.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (svr *Server) stopModules(modules []Module) error {
+	ctx, cancel := context.WithTimeout(context.Background(), svr.shutdownTimeout)
+	defer cancel()
+	var errs []error
+	for i := len(modules) - 1; i >= 0; i-- {
+		if stopper, ok := modules[i].(Stopper); ok {
+			if err := stopper.Stop(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/go-libs/httpx/router"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+// lifecycleModule records the calls of the lifecycle hooks.
+type lifecycleModule struct {
+	calls  *[]string
+	cancel context.CancelFunc
+}
+
+func (m *lifecycleModule) Setup(_ router.RouteElement) {
+	*m.calls = append(*m.calls, "setup")
+}
+
+func (m *lifecycleModule) Start(_ context.Context, _ db.Connection) error {
+	*m.calls = append(*m.calls, "start")
+	m.cancel()
+	return nil
+}
+
+func (m *lifecycleModule) Stop(_ context.Context) error {
+	*m.calls = append(*m.calls, "stop")
+	return nil
+}
+
+func TestServer_Serve(t *testing.T) {
+	tests := []struct {
+		name      string
+		address   string
+		wantCalls []string
+		wantErr   bool
+	}{{
+		name:      "GIVEN canceled context THEN stop modules",
+		address:   "127.0.0.1:0",
+		wantCalls: []string{"setup", "start", "stop"},
+	}, {
+		name:      "GIVEN invalid address THEN stop modules and fail",
+		address:   "127.0.0.1:-1",
+		wantCalls: []string{"setup", "start", "stop"},
+		wantErr:   true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.wantErr {
+				// the context stays active, the listener has to stop the server
+				cancel = func() {}
+			}
+			srv := New().
+				WithListenAddress(tt.address).
+				WithDBFile(filepath.Join(t.TempDir(), "test.db")).
+				WithAssets(os.DirFS("../../../assets")).
+				WithUISrc(os.DirFS(t.TempDir())).
+				WithModule(func() Module {
+					return &lifecycleModule{calls: &calls, cancel: cancel}
+				})
+
+			err := srv.Serve(ctx)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Serve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.wantCalls, calls); diff != "" {
+				t.Errorf("Serve() calls mismatch (-want +got):\n%s", diff)
+			}
+			if err := srv.HTTP.ListenAndServe(); err != http.ErrServerClosed {
+				t.Errorf("ListenAndServe() after Serve() error = %v, want %v", err, http.ErrServerClosed)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+// MetricsMediaType is the media type of the Prometheus text format.
+const MetricsMediaType = "text/plain; version=0.0.4; charset=utf-8"
+
+// defaultBuckets are the upper bounds in seconds of the duration histograms.
+var defaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Monitor holds the metrics of the instance, modules register their gauges with RegisterGauge.
+var Monitor = NewMetrics()
+
+// Metrics collects request and query metrics and serves them in the Prometheus text format.
+type Metrics struct {
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[routeKey]*histogram
+	queries   map[string]*histogram
+	gauges    map[string]*gauge
+}
+
+type routeKey struct {
+	method string
+	route  string
+}
+
+type requestKey struct {
+	routeKey
+	code int
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(seconds float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(defaultBuckets))
+	}
+	for i, bound := range defaultBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// gauge is read from the database when the metrics are scraped.
+type gauge struct {
+	help  string
+	value func(tx db.Transaction) (float64, error)
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:  make(map[requestKey]uint64),
+		durations: make(map[routeKey]*histogram),
+		queries:   make(map[string]*histogram),
+		gauges:    make(map[string]*gauge),
+	}
+}
+
+// RegisterGauge adds the gauge name, value is called with a transaction on every scrape.
+func (m *Metrics) RegisterGauge(name, help string, value func(tx db.Transaction) (float64, error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[name] = &gauge{help: help, value: value}
+}
+
+// Instrument counts the requests of route and records their durations. route is the registered path
+// template, so all requests of a route share their metrics.
+func (m *Metrics) Instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		if span := SpanFromContext(req.Context()); span != nil {
+			span.Name = req.Method + " " + route
+			span.Attributes["http.route"] = route
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		next(rec, req)
+		m.ObserveRequest(req.Method, route, rec.Status(), time.Since(start))
+	}
+}
+
+func (m *Metrics) ObserveRequest(method, route string, code int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := routeKey{method: method, route: route}
+	m.requests[requestKey{routeKey: key, code: code}]++
+	h, ok := m.durations[key]
+	if !ok {
+		h = &histogram{}
+		m.durations[key] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+// ObserveQuery records the duration of a statement, it is a db.QueryObserver.
+func (m *Metrics) ObserveQuery(operation, _ string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.queries[operation]
+	if !ok {
+		h = &histogram{}
+		m.queries[operation] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+// Handler serves the metrics, the gauges are read from database.
+func (m *Metrics) Handler(database db.Connection) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", MetricsMediaType)
+		m.Write(w, database)
+	}
+}
+
+// Write writes the metrics in the Prometheus text format to w.
+func (m *Metrics) Write(w io.Writer, database db.Connection) {
+	gauges := m.readGauges(database)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeHeader(w, "protrakgon_http_requests_total", "counter", "Number of HTTP requests by route and status code.")
+	requestKeys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		if requestKeys[i].routeKey != requestKeys[j].routeKey {
+			return lessRoute(requestKeys[i].routeKey, requestKeys[j].routeKey)
+		}
+		return requestKeys[i].code < requestKeys[j].code
+	})
+	for _, key := range requestKeys {
+		_, _ = fmt.Fprintf(w, "protrakgon_http_requests_total{method=%q,route=%q,code=\"%d\"} %d\n",
+			key.method, key.route, key.code, m.requests[key])
+	}
+
+	writeHeader(w, "protrakgon_http_request_duration_seconds", "histogram", "Duration of HTTP requests by route.")
+	routeKeys := make([]routeKey, 0, len(m.durations))
+	for key := range m.durations {
+		routeKeys = append(routeKeys, key)
+	}
+	sort.Slice(routeKeys, func(i, j int) bool {
+		return lessRoute(routeKeys[i], routeKeys[j])
+	})
+	for _, key := range routeKeys {
+		writeHistogram(w, "protrakgon_http_request_duration_seconds",
+			fmt.Sprintf("method=%q,route=%q", key.method, key.route), m.durations[key])
+	}
+
+	writeHeader(w, "protrakgon_db_query_duration_seconds", "histogram", "Duration of database statements by operation.")
+	operations := make([]string, 0, len(m.queries))
+	for operation := range m.queries {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	for _, operation := range operations {
+		writeHistogram(w, "protrakgon_db_query_duration_seconds", fmt.Sprintf("operation=%q", operation), m.queries[operation])
+	}
+
+	names := make([]string, 0, len(gauges))
+	for name := range gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeHeader(w, name, "gauge", m.gauges[name].help)
+		_, _ = fmt.Fprintf(w, "%s %s\n", name, formatFloat(gauges[name]))
+	}
+}
+
+// readGauges returns the values of the gauges, gauges failing to read are left out.
+func (m *Metrics) readGauges(database db.Connection) map[string]float64 {
+	m.mu.Lock()
+	gauges := make(map[string]*gauge, len(m.gauges))
+	for name, g := range m.gauges {
+		gauges[name] = g
+	}
+	m.mu.Unlock()
+
+	values := make(map[string]float64, len(gauges))
+	if database == nil {
+		return values
+	}
+	_ = database.DoTransaction(func(tx db.Transaction) error {
+		for name, g := range gauges {
+			value, err := g.value(tx)
+			if err != nil {
+				log.Err(err).Str("gauge", name).Msg("failed to read gauge")
+				continue
+			}
+			values[name] = value
+		}
+		return nil
+	})
+	return values
+}
+
+func writeHeader(w io.Writer, name, metricType, help string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func writeHistogram(w io.Writer, name, labels string, h *histogram) {
+	for i, bound := range defaultBuckets {
+		_, _ = fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(bound), h.counts[i])
+	}
+	_, _ = fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	_, _ = fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	_, _ = fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+func lessRoute(a, b routeKey) bool {
+	if a.route != b.route {
+		return a.route < b.route
+	}
+	return a.method < b.method
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// statusRecorder remembers the status code and the size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.size += n
+	return n, err
+}
+
+// Status returns the status code of the response, 200 if the handler wrote nothing.
+func (r *statusRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
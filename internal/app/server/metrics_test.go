@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+func TestMetrics_Write(t *testing.T) {
+	m := NewMetrics()
+	m.RegisterGauge("test_open_slots", "Open slots.", func(_ db.Transaction) (float64, error) {
+		return 2, nil
+	})
+	handler := m.Instrument("/v1/client/:clientID", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/client/1", nil))
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/client/2", nil))
+	m.ObserveQuery("select", "SELECT 1", 3*time.Millisecond)
+
+	var out strings.Builder
+	m.Write(&out, noTxConnection{})
+
+	for _, want := range []string{
+		"# TYPE protrakgon_http_requests_total counter\n",
+		`protrakgon_http_requests_total{method="GET",route="/v1/client/:clientID",code="404"} 2` + "\n",
+		`protrakgon_http_request_duration_seconds_count{method="GET",route="/v1/client/:clientID"} 2` + "\n",
+		`protrakgon_db_query_duration_seconds_bucket{operation="select",le="0.001"} 0` + "\n",
+		`protrakgon_db_query_duration_seconds_bucket{operation="select",le="0.005"} 1` + "\n",
+		`protrakgon_db_query_duration_seconds_bucket{operation="select",le="+Inf"} 1` + "\n",
+		"# TYPE test_open_slots gauge\ntest_open_slots 2\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Write() missing %q in\n%s", want, out.String())
+		}
+	}
+}
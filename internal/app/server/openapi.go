@@ -0,0 +1,451 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vloryan/go-libs/httpx/router"
+	"github.com/vloryan/go-libs/reflectx"
+)
+
+// JSONAPIMediaType is the media type of JSON:API documents.
+const JSONAPIMediaType = "application/vnd.api+json"
+
+// Schema is a JSON schema as used by OpenAPI 3.1.
+type Schema map[string]any
+
+// Content describes a request or response body.
+type Content struct {
+	MediaType string
+	Schema    Schema
+	// resource is added to the components of the document.
+	resource Resource
+}
+
+// Document is the content of a JSON:API document containing one resource.
+func Document(resource Resource) *Content {
+	return &Content{
+		MediaType: JSONAPIMediaType,
+		Schema: Schema{
+			"type":     "object",
+			"required": []string{"data"},
+			"properties": Schema{
+				"data":     resourceRef(resource),
+				"included": Schema{"type": "array", "items": Schema{"type": "object"}},
+				"links":    Schema{"type": "object"},
+			},
+		},
+		resource: resource,
+	}
+}
+
+// CollectionDocument is the content of a JSON:API document containing a list of resources.
+func CollectionDocument(resource Resource) *Content {
+	return &Content{
+		MediaType: JSONAPIMediaType,
+		Schema: Schema{
+			"type":     "object",
+			"required": []string{"data"},
+			"properties": Schema{
+				"data":     Schema{"type": "array", "items": resourceRef(resource)},
+				"included": Schema{"type": "array", "items": Schema{"type": "object"}},
+				"links":    Schema{"type": "object"},
+				"meta":     Schema{"type": "object"},
+			},
+		},
+		resource: resource,
+	}
+}
+
+// Operation describes a route of the API.
+type Operation struct {
+	Summary string
+	// Request is the content of the request body, nil if the operation has no body.
+	Request *Content
+	// Response is the content of a successful response, nil if it has no body.
+	Response *Content
+	// Status of a successful response, by default 200, or 204 without Response.
+	Status int
+	// Query is bound to the query parameters by the form tags of its fields, e.g. a filter.
+	Query any
+	// Paginated adds the pagination and sort parameters.
+	Paginated bool
+}
+
+// OpenAPI collects the routes of the API and their operations and serves them as OpenAPI 3.1 document.
+type OpenAPI struct {
+	base       string
+	routes     map[string]map[string]*Operation
+	components map[string]Resource
+}
+
+// APIDoc documents the routes registered by the JsonAPIModule.
+var APIDoc = NewOpenAPI()
+
+func NewOpenAPI() *OpenAPI {
+	return &OpenAPI{
+		routes:     make(map[string]map[string]*Operation),
+		components: make(map[string]Resource),
+	}
+}
+
+// Routes returns route recording all routes registered on it and its sub routes.
+// Paths of the document are relative to the path of route.
+func (o *OpenAPI) Routes(route router.RouteElement) router.RouteElement {
+	o.base = path.Clean("/" + route.Path())
+	return &recordingRoute{RouteElement: route, doc: o}
+}
+
+// Describe sets the operation of the route registered on route with method and relative path p.
+func (o *OpenAPI) Describe(route router.RouteElement, method, p string, op *Operation) {
+	o.record(method, route.Path()+"/"+p)[method] = op
+	for _, content := range []*Content{op.Request, op.Response} {
+		if content != nil && content.resource != nil {
+			o.components[content.resource.GetIdentifier().Type] = content.resource
+		}
+	}
+}
+
+// Undocumented returns "METHOD path" of all recorded routes without operation or response schema.
+// Only operations with status 204 No Content may omit the response.
+func (o *OpenAPI) Undocumented() []string {
+	var missing []string
+	for p, operations := range o.routes {
+		for method, op := range operations {
+			if op == nil || (op.Response == nil && op.Status != http.StatusNoContent) {
+				missing = append(missing, method+" "+p)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func (o *OpenAPI) record(method, fullPath string) map[string]*Operation {
+	p := strings.TrimPrefix(path.Clean("/"+fullPath), o.base)
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	p = path.Clean("/" + strings.Join(segments, "/"))
+	if o.routes[p] == nil {
+		o.routes[p] = make(map[string]*Operation)
+	}
+	if _, ok := o.routes[p][method]; !ok {
+		o.routes[p][method] = nil
+	}
+	return o.routes[p]
+}
+
+// Serve writes the OpenAPI document.
+func (o *OpenAPI) Serve(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(o.Document())
+}
+
+// Document returns the OpenAPI document of all recorded routes.
+func (o *OpenAPI) Document() map[string]any {
+	paths := make(map[string]any)
+	for p, operations := range o.routes {
+		item := make(map[string]any)
+		for method, op := range operations {
+			if op != nil {
+				item[strings.ToLower(method)] = o.operation(p, op)
+			}
+		}
+		paths[p] = item
+	}
+	schemas := map[string]any{
+		"ResourceIdentifier": Schema{
+			"type":     "object",
+			"required": []string{"type"},
+			"properties": Schema{
+				"type": Schema{"type": "string"},
+				"id":   Schema{"type": "string"},
+				"lid":  Schema{"type": "string"},
+			},
+		},
+		"Errors": Schema{
+			"type":     "object",
+			"required": []string{"errors"},
+			"properties": Schema{
+				"errors": Schema{"type": "array", "items": Schema{
+					"type": "object",
+					"properties": Schema{
+						"status": Schema{"type": "string"},
+						"code":   Schema{"type": "string"},
+						"title":  Schema{"type": "string"},
+						"detail": Schema{"type": "string"},
+						"source": Schema{"type": "object"},
+						"meta":   Schema{"type": "object"},
+					},
+				}},
+			},
+		},
+	}
+	for resourceType, resource := range o.components {
+		schemas[resourceType] = resourceSchema(resource)
+	}
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "protrakgon",
+			"version": "1",
+		},
+		"servers":    []any{map[string]any{"url": o.base}},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+func (o *OpenAPI) operation(p string, op *Operation) map[string]any {
+	parameters := make([]any, 0)
+	for _, segment := range strings.Split(p, "/") {
+		if strings.HasPrefix(segment, "{") {
+			parameters = append(parameters, parameter(strings.Trim(segment, "{}"), "path", Schema{"type": "string"}))
+		}
+	}
+	if op.Query != nil {
+		parameters = append(parameters, queryParameters(reflect.TypeOf(op.Query))...)
+	}
+	if op.Paginated {
+		for _, name := range []string{"page[offset]", "page[limit]", "page[after]", "page[before]", "page[size]"} {
+			schema := Schema{"type": "integer"}
+			if name == "page[after]" || name == "page[before]" {
+				schema = Schema{"type": "string"}
+			}
+			parameters = append(parameters, parameter(name, "query", schema))
+		}
+		parameters = append(parameters, parameter("sort", "query", Schema{"type": "string"}))
+		parameters = append(parameters, parameter("include", "query", Schema{"type": "string"}))
+	}
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+		if op.Response == nil {
+			status = http.StatusNoContent
+		}
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	if op.Response != nil {
+		success["content"] = content(op.Response)
+	}
+	result := map[string]any{
+		"parameters": parameters,
+		"responses": map[string]any{
+			strconv.Itoa(status): success,
+			"default": map[string]any{
+				"description": "Error",
+				"content": map[string]any{JSONAPIMediaType: map[string]any{
+					"schema": Schema{"$ref": "#/components/schemas/Errors"},
+				}},
+			},
+		},
+	}
+	if op.Summary != "" {
+		result["summary"] = op.Summary
+	}
+	if op.Request != nil {
+		result["requestBody"] = map[string]any{"required": true, "content": content(op.Request)}
+	}
+	return result
+}
+
+func content(c *Content) map[string]any {
+	return map[string]any{c.MediaType: map[string]any{"schema": c.Schema}}
+}
+
+func parameter(name, in string, schema Schema) map[string]any {
+	return map[string]any{
+		"name":     name,
+		"in":       in,
+		"required": in == "path",
+		"schema":   schema,
+	}
+}
+
+func queryParameters(t reflect.Type) []any {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var parameters []any
+	if t.Kind() != reflect.Struct {
+		return parameters
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := reflectx.Tag(field, "form").Value
+		if name == "" || name == "-" {
+			continue
+		}
+		schema := typeSchema(field.Type)
+		if format := field.Tag.Get("time_format"); format == "2006-01-02" {
+			schema = Schema{"type": "string", "format": "date"}
+		}
+		parameters = append(parameters, parameter(name, "query", schema))
+	}
+	return parameters
+}
+
+func resourceRef(resource Resource) Schema {
+	return Schema{"$ref": "#/components/schemas/" + resource.GetIdentifier().Type}
+}
+
+// resourceSchema describes the resource object of resource. Fields of resources are relationships,
+// all other fields except the id are attributes. Validate tags are translated to schema keywords.
+func resourceSchema(resource Resource) Schema {
+	attributes := Schema{}
+	relationships := Schema{}
+	var required []string
+	t := reflect.TypeOf(resource)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := jsonName(field)
+		if !field.IsExported() || name == "-" || strings.EqualFold(name, "id") || strings.EqualFold(name, "type") {
+			continue
+		}
+		if field.Type.Implements(resourceType) {
+			relationships[name] = Schema{
+				"type": "object",
+				"properties": Schema{
+					"data": Schema{"$ref": "#/components/schemas/ResourceIdentifier"},
+				},
+			}
+			continue
+		}
+		schema := typeSchema(field.Type)
+		for _, rule := range strings.Split(reflectx.Tag(field, "validate").Value, ",") {
+			ruleName, param, _ := strings.Cut(rule, "=")
+			limit, _ := strconv.Atoi(param)
+			switch {
+			case ruleName == "required":
+				required = append(required, name)
+			case ruleName == "max" && schema["type"] == "string":
+				schema["maxLength"] = limit
+			case ruleName == "min" && schema["type"] == "string":
+				schema["minLength"] = limit
+			case ruleName == "max":
+				schema["maximum"] = limit
+			case ruleName == "min":
+				schema["minimum"] = limit
+			}
+		}
+		attributes[name] = schema
+	}
+	attributesSchema := Schema{"type": "object", "properties": attributes}
+	if len(required) > 0 {
+		attributesSchema["required"] = required
+	}
+	return Schema{
+		"type":     "object",
+		"required": []string{"type"},
+		"properties": Schema{
+			"type":          Schema{"type": "string", "const": resource.GetIdentifier().Type},
+			"id":            Schema{"type": "string"},
+			"lid":           Schema{"type": "string"},
+			"attributes":    attributesSchema,
+			"relationships": Schema{"type": "object", "properties": relationships},
+			"links":         Schema{"type": "object"},
+		},
+	}
+}
+
+var (
+	resourceType  = reflect.TypeOf((*Resource)(nil)).Elem()
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	timeType      = reflect.TypeOf(time.Time{})
+)
+
+func typeSchema(t reflect.Type) Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+	if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+		// custom JSON representations are strings in this API, e.g. activities
+		return Schema{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object"}
+	case reflect.Struct:
+		properties := Schema{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if name := jsonName(field); field.IsExported() && name != "-" {
+				properties[name] = typeSchema(field.Type)
+			}
+		}
+		return Schema{"type": "object", "properties": properties}
+	default:
+		return Schema{}
+	}
+}
+
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// recordingRoute records all routes registered on it in the OpenAPI document.
+type recordingRoute struct {
+	router.RouteElement
+	doc *OpenAPI
+}
+
+func (r *recordingRoute) SubRoute(p string) router.RouteElement {
+	return &recordingRoute{RouteElement: r.RouteElement.SubRoute(p), doc: r.doc}
+}
+
+func (r *recordingRoute) GET(p string, h http.HandlerFunc) {
+	r.doc.record(http.MethodGet, r.Path()+"/"+p)
+	r.RouteElement.GET(p, h)
+}
+
+func (r *recordingRoute) POST(p string, h http.HandlerFunc) {
+	r.doc.record(http.MethodPost, r.Path()+"/"+p)
+	r.RouteElement.POST(p, h)
+}
+
+func (r *recordingRoute) PATCH(p string, h http.HandlerFunc) {
+	r.doc.record(http.MethodPatch, r.Path()+"/"+p)
+	r.RouteElement.PATCH(p, h)
+}
+
+func (r *recordingRoute) PUT(p string, h http.HandlerFunc) {
+	r.doc.record(http.MethodPut, r.Path()+"/"+p)
+	r.RouteElement.PUT(p, h)
+}
+
+func (r *recordingRoute) DELETE(p string, h http.HandlerFunc) {
+	r.doc.record(http.MethodDelete, r.Path()+"/"+p)
+	r.RouteElement.DELETE(p, h)
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/go-libs/httpx/router"
+	"github.com/vloryan/go-libs/jsonapi"
+)
+
+type documentedItem struct {
+	ID      int             `json:"id,omitempty"`
+	Name    string          `json:"name,omitempty" validate:"required,max=10"`
+	Count   *int            `json:"count,omitempty" validate:"min=1"`
+	Related *documentedItem `json:"related,omitempty"`
+}
+
+func (d *documentedItem) GetIdentifier() *jsonapi.ResourceIdentifierObject {
+	return &jsonapi.ResourceIdentifierObject{Type: "documented"}
+}
+
+func (d *documentedItem) SetIdentifier(_ *jsonapi.ResourceIdentifierObject) {}
+
+func TestResourceSchema(t *testing.T) {
+	got := resourceSchema(&documentedItem{})["properties"].(Schema)
+	want := Schema{
+		"type": "object",
+		"properties": Schema{
+			"name":  Schema{"type": "string", "maxLength": 10},
+			"count": Schema{"type": "integer", "minimum": 1},
+		},
+		"required": []string{"name"},
+	}
+	if diff := cmp.Diff(want, got["attributes"]); diff != "" {
+		t.Errorf("resourceSchema() attributes mismatch (-want +got):\n%s", diff)
+	}
+	if _, ok := got["relationships"].(Schema)["properties"].(Schema)["related"]; !ok {
+		t.Errorf("resourceSchema() missing relationship related")
+	}
+}
+
+func TestOpenAPI_Undocumented(t *testing.T) {
+	doc := NewOpenAPI()
+	route := doc.Routes(router.NewRoute("/v1", func(_, _ string, _ http.HandlerFunc) {}))
+	items := route.SubRoute("item")
+	items.GET("", nil)
+	items.GET(":itemID", nil)
+	items.DELETE(":itemID", nil)
+	doc.Describe(items, http.MethodGet, "", &Operation{Response: CollectionDocument(&documentedItem{})})
+	doc.Describe(items, http.MethodDelete, ":itemID", &Operation{Status: http.StatusNoContent})
+
+	want := []string{"GET /item/{itemID}"}
+	if diff := cmp.Diff(want, doc.Undocumented()); diff != "" {
+		t.Errorf("Undocumented() mismatch (-want +got):\n%s", diff)
+	}
+}
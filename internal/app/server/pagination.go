@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+// cursorLinks adds the links of a keyset paginated collection to the document. The handler reads the
+// requested page with request.CursorPage and sets its Next and Prev cursors.
+type cursorLinks struct {
+	req  *http.Request
+	page *db.CursorPage
+}
+
+func (l *cursorLinks) Update(doc *jsonapi.Document) error {
+	if doc.Links == nil {
+		doc.Links = make(map[string]any)
+	}
+	doc.Links["self"] = l.req.URL.RequestURI()
+	delete(doc.Links, "next")
+	delete(doc.Links, "prev")
+	if l.page.Next != "" {
+		doc.Links["next"] = cursorLink(l.req, "page[after]", l.page.Next)
+	}
+	if l.page.Prev != "" {
+		doc.Links["prev"] = cursorLink(l.req, "page[before]", l.page.Prev)
+	}
+	return nil
+}
+
+func cursorLink(req *http.Request, param, cursor string) string {
+	u := *req.URL
+	query := u.Query()
+	query.Del("page[after]")
+	query.Del("page[before]")
+	query.Set(param, cursor)
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+func TestCursorLinks_Update(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/project/1/slot?page[after]=a&page[size]=2", nil)
+	links := &cursorLinks{req: req, page: &db.CursorPage{Size: 2, After: "a", Next: "c", Prev: "b"}}
+	doc := &jsonapi.Document{Links: map[string]any{"next": "/project/1/slot?page[offset]=1"}}
+
+	if err := links.Update(doc); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	want := map[string]any{
+		"self": "/project/1/slot?page[after]=a&page[size]=2",
+		"next": "/project/1/slot?page%5Bafter%5D=c&page%5Bsize%5D=2",
+		"prev": "/project/1/slot?page%5Bbefore%5D=b&page%5Bsize%5D=2",
+	}
+	if diff := cmp.Diff(want, doc.Links); diff != "" {
+		t.Errorf("Update() links mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vloryan/go-libs/httpx/router"
+)
+
+const (
+	// DefaultMaxBodySize is the maximum size of JSON request documents without WithBodyLimits.
+	DefaultMaxBodySize = 1 << 20
+	// DefaultMaxUploadSize is the maximum size of uploaded files, e.g. calendars, without WithBodyLimits.
+	DefaultMaxUploadSize = 10 << 20
+
+	bucketPruneInterval = time.Minute
+)
+
+// RateLimit limits the requests of each client to the API with a token bucket. Clients are identified by
+// their IP address, the admin token is the only bearer token which is checked and has a bucket of its own.
+type RateLimit struct {
+	// PerMinute is the number of requests a client may send per minute on average, 0 disables the limit.
+	PerMinute int
+	// Burst is the number of requests a client may send at once.
+	Burst int
+	// TrustProxy takes the client address from the last X-Forwarded-For entry, which is added by the
+	// reverse proxy. Without a proxy clients could choose their address.
+	TrustProxy bool
+	// AdminToken is the token of the admin endpoints, requests with it are limited independent of their address.
+	AdminToken string
+}
+
+// WithRateLimit limits the requests to the API, nil disables the limit.
+func (svr *Server) WithRateLimit(limit *RateLimit) *Server {
+	svr.rateLimit = nil
+	if limit != nil && limit.PerMinute > 0 {
+		svr.rateLimit = newRateLimiter(limit)
+	}
+	return svr
+}
+
+// WithBodyLimits sets the maximum size of JSON request documents and of uploaded files in bytes.
+func (svr *Server) WithBodyLimits(maxBody, maxUpload int64) *Server {
+	svr.maxBody = maxBody
+	svr.maxUpload = maxUpload
+	return svr
+}
+
+// TooManyRequests reports a client exceeding the rate limit.
+func TooManyRequests(retryAfter int) *Error {
+	return NewError(http.StatusTooManyRequests, "rate_limited", "too many requests").
+		WithMeta("retryAfter", retryAfter)
+}
+
+// BodyTooLarge reports a request body exceeding limit bytes.
+func BodyTooLarge(limit int64) *Error {
+	return NewError(http.StatusRequestEntityTooLarge, "body_too_large", "request body too large").
+		WithMeta("limit", limit)
+}
+
+// uploadRoutes are the routes accepting files, by method and path.
+var uploadRoutes = make(map[string]bool)
+
+// AcceptUpload lets the route p of route accept request bodies up to the upload size, e.g. calendar files.
+// The bodies of all other routes are limited to the size of JSON documents.
+func AcceptUpload(route router.RouteElement, method, p string) {
+	uploadRoutes[uploadKey(method, route.Path()+"/"+p)] = true
+}
+
+func uploadKey(method, fullPath string) string {
+	return method + " " + path.Clean("/"+fullPath)
+}
+
+// limitRequests rejects requests of clients exceeding the rate limit with 429 and bodies exceeding
+// their limit with 413. Bodies without length are cut at the limit, handlers reading them fail with
+// an *http.MaxBytesError, which Handle answers with 413. The route is the method and path of next.
+func (svr *Server) limitRequests(method, fullPath string, next http.HandlerFunc) http.HandlerFunc {
+	route := uploadKey(method, fullPath)
+	return func(w http.ResponseWriter, req *http.Request) {
+		if svr.rateLimit != nil {
+			if wait, ok := svr.rateLimit.allow(svr.rateLimit.client(req), time.Now()); !ok {
+				retryAfter := int(math.Ceil(wait.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				writeErrors(w, http.StatusTooManyRequests, TooManyRequests(retryAfter))
+				return
+			}
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			limit := svr.maxBody
+			if uploadRoutes[route] {
+				limit = svr.maxUpload
+			}
+			if req.ContentLength > limit {
+				writeErrors(w, http.StatusRequestEntityTooLarge, BodyTooLarge(limit))
+				return
+			}
+			req.Body = http.MaxBytesReader(w, req.Body, limit)
+		}
+		next(w, req)
+	}
+}
+
+// bodyTooLarge returns the error of a body cut by limitRequests.
+func bodyTooLarge(err error) *Error {
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		return nil
+	}
+	return BodyTooLarge(maxBytesErr.Limit).Wrap(maxBytesErr)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	mu         sync.Mutex
+	rate       float64 // tokens per second
+	burst      float64
+	trustProxy bool
+	adminToken string
+	buckets    map[string]*bucket
+	pruned     time.Time
+}
+
+func newRateLimiter(limit *RateLimit) *rateLimiter {
+	return &rateLimiter{
+		rate:       float64(limit.PerMinute) / 60,
+		burst:      math.Max(float64(limit.Burst), 1),
+		trustProxy: limit.TrustProxy,
+		adminToken: limit.AdminToken,
+		buckets:    make(map[string]*bucket),
+	}
+}
+
+// allow takes a token of the bucket of client. Without token it returns the time until the next token.
+func (l *rateLimiter) allow(client string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.pruned) >= bucketPruneInterval {
+		l.prune(now)
+	}
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+}
+
+// prune removes the buckets which are full again, they are recreated on the next request.
+func (l *rateLimiter) prune(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+	l.pruned = now
+}
+
+// client identifies the client of req by its address. Other tokens than the admin token are not
+// verified, a client choosing a new token per request must not get a new bucket.
+func (l *rateLimiter) client(req *http.Request) string {
+	if IsAdmin(req, l.adminToken) {
+		return "admin"
+	}
+	if l.trustProxy {
+		if forwarded := req.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			addresses := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(addresses[len(addresses)-1]); ip != "" {
+				return "ip:" + ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/go-libs/httpx/router"
+	"github.com/vloryan/go-libs/jsonapi"
+)
+
+func TestRateLimiter_allow(t *testing.T) {
+	start := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	type call struct {
+		client string
+		after  time.Duration
+	}
+	type result struct {
+		Wait time.Duration
+		OK   bool
+	}
+	tests := []struct {
+		name  string
+		calls []call
+		want  []result
+	}{{
+		name:  "GIVEN burst THEN allow burst at once",
+		calls: []call{{"a", 0}, {"a", 0}, {"a", 0}},
+		want:  []result{{0, true}, {0, true}, {time.Second, false}},
+	}, {
+		name:  "GIVEN empty bucket THEN refill with rate",
+		calls: []call{{"a", 0}, {"a", 0}, {"a", 500 * time.Millisecond}, {"a", time.Second}},
+		want:  []result{{0, true}, {0, true}, {500 * time.Millisecond, false}, {0, true}},
+	}, {
+		name:  "GIVEN other client THEN use own bucket",
+		calls: []call{{"a", 0}, {"a", 0}, {"b", 0}, {"a", 0}},
+		want:  []result{{0, true}, {0, true}, {0, true}, {time.Second, false}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter(&RateLimit{PerMinute: 60, Burst: 2})
+			var got []result
+			for _, c := range tt.calls {
+				wait, ok := l.allow(c.client, start.Add(c.after))
+				got = append(got, result{Wait: wait, OK: ok})
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("allow() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_client(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustProxy bool
+		header     http.Header
+		want       string
+	}{{
+		name: "GIVEN no token THEN use remote address",
+		want: "ip:192.0.2.1",
+	}, {
+		name:   "GIVEN forwarded address without trusted proxy THEN use remote address",
+		header: http.Header{"X-Forwarded-For": {"198.51.100.7"}},
+		want:   "ip:192.0.2.1",
+	}, {
+		name:       "GIVEN trusted proxy THEN use address added by proxy",
+		trustProxy: true,
+		header:     http.Header{"X-Forwarded-For": {"203.0.113.9, 198.51.100.7"}},
+		want:       "ip:198.51.100.7",
+	}, {
+		name:   "GIVEN other bearer token than admin token THEN use remote address",
+		header: http.Header{"Authorization": {"Bearer guess"}},
+		want:   "ip:192.0.2.1",
+	}, {
+		name:   "GIVEN admin token THEN use admin bucket",
+		header: http.Header{"Authorization": {"Bearer secret"}},
+		want:   "admin",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter(&RateLimit{PerMinute: 60, Burst: 1, TrustProxy: tt.trustProxy, AdminToken: "secret"})
+			req := httptest.NewRequest(http.MethodGet, "/v1/client", nil)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+
+			if got := l.client(req); got != tt.want {
+				t.Errorf("client() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_limitRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		requests   int
+		body       string
+		path       string
+		chunked    bool
+		wantStatus int
+		wantHeader string
+		wantErrors string
+	}{{
+		name:       "GIVEN requests within limit THEN handle them",
+		requests:   2,
+		body:       `{"data":{}}`,
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "GIVEN requests exceeding limit THEN reject with retry after",
+		requests:   3,
+		wantStatus: http.StatusTooManyRequests,
+		wantHeader: "30",
+		wantErrors: `[{"status":"429","code":"rate_limited","title":"too many requests","meta":{"retryAfter":30}}]`,
+	}, {
+		name:       "GIVEN too large document THEN reject before reading",
+		requests:   1,
+		body:       strings.Repeat("x", 17),
+		wantStatus: http.StatusRequestEntityTooLarge,
+		wantErrors: `[{"status":"413","code":"body_too_large","title":"request body too large","meta":{"limit":16}}]`,
+	}, {
+		name:       "GIVEN upload route THEN accept body within upload limit",
+		requests:   1,
+		body:       strings.Repeat("x", 17),
+		path:       "upload",
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "GIVEN too large upload THEN reject with upload limit",
+		requests:   1,
+		body:       strings.Repeat("x", 33),
+		path:       "upload",
+		wantStatus: http.StatusRequestEntityTooLarge,
+		wantErrors: `[{"status":"413","code":"body_too_large","title":"request body too large","meta":{"limit":32}}]`,
+	}, {
+		name:       "GIVEN too large document without length THEN fail to read",
+		requests:   1,
+		body:       strings.Repeat("x", 17),
+		chunked:    true,
+		wantStatus: http.StatusRequestEntityTooLarge,
+		wantErrors: `[{"status":"413","code":"body_too_large","title":"request body too large","detail":"http: request body too large","meta":{"limit":16}}]`,
+	}}
+	AcceptUpload(router.NewRoute("/v1", func(_, _ string, _ http.HandlerFunc) {}), http.MethodPost, "upload")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New().WithRateLimit(&RateLimit{PerMinute: 2, Burst: 2}).WithBodyLimits(16, 32)
+			handler := srv.limitRequests(http.MethodPost, "/v1/"+tt.path, Handle(&jsonapi.GenericHandler[*testItem]{}, func(req *http.Request) (*jsonapi.DocumentData[*testItem], error) {
+				if _, err := io.ReadAll(req.Body); err != nil {
+					return nil, InvalidBody(err)
+				}
+				return jsonapi.NewDocumentData[*testItem]([]*testItem{}, "/client"), nil
+			}))
+			var rec *httptest.ResponseRecorder
+			for i := 0; i < tt.requests; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/v1/"+tt.path, strings.NewReader(tt.body))
+				if tt.chunked {
+					req.ContentLength = -1
+				}
+				rec = httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("limitRequests() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.wantHeader {
+				t.Errorf("limitRequests() Retry-After = %s, want %s", got, tt.wantHeader)
+			}
+			if tt.wantErrors == "" {
+				return
+			}
+			doc := struct {
+				Errors json.RawMessage `json:"errors"`
+			}{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.wantErrors, string(doc.Errors)); diff != "" {
+				t.Errorf("limitRequests() errors mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package request
 const (
 	CtxKeyDatabase    ContextKey = "DATABASE"
 	CtxKeyTransaction ContextKey = "TRANSACTION"
+	CtxKeyCursorPage  ContextKey = "CURSOR_PAGE"
 )
 
 type ContextKey string
@@ -2,8 +2,11 @@ package request
 
 import (
 	"net/http"
+	"reflect"
 	"strconv"
+	"strings"
 
+	"github.com/vloryan/go-libs/httpx"
 	"github.com/vloryan/protrakgon/internal/app/server/db"
 )
 
@@ -26,3 +29,53 @@ func QueryInt(req *http.Request, name string, def int) int {
 	}
 	return int(u)
 }
+
+// BindFilter binds the filter query parameters to filter and rejects filter parameters
+// which are not declared by a form tag of filter.
+func BindFilter(req *http.Request, filter any) error {
+	known := make(map[string]bool)
+	t := reflect.TypeOf(filter)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if name := t.Field(i).Tag.Get("form"); name != "" {
+			known[name] = true
+		}
+	}
+	for key := range req.URL.Query() {
+		if strings.HasPrefix(key, "filter[") && !known[key] {
+			return &db.FieldError{Parameter: "filter", Field: strings.TrimSuffix(strings.TrimPrefix(key, "filter["), "]")}
+		}
+	}
+	return httpx.BindQuery(req, filter)
+}
+
+const (
+	defaultCursorPageSize = 50
+	maxCursorPageSize     = 500
+)
+
+// ExtractCursorPage reads the keyset pagination parameters page[after], page[before] and page[size].
+// It returns nil if the request uses neither page[after] nor page[before].
+func ExtractCursorPage(req *http.Request) *db.CursorPage {
+	query := req.URL.Query()
+	if !query.Has("page[after]") && !query.Has("page[before]") {
+		return nil
+	}
+	size := QueryInt(req, "page[size]", defaultCursorPageSize)
+	if size <= 0 || size > maxCursorPageSize {
+		size = defaultCursorPageSize
+	}
+	return &db.CursorPage{
+		Size:   size,
+		After:  query.Get("page[after]"),
+		Before: query.Get("page[before]"),
+	}
+}
+
+// CursorPage returns the keyset pagination of the request or nil if offset pagination is used.
+func CursorPage(req *http.Request) *db.CursorPage {
+	page, _ := req.Context().Value(CtxKeyCursorPage).(*db.CursorPage)
+	return page
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/vloryan/go-libs/httpx/router"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/request"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// snippetTokens is the number of tokens of a snippet around the matched terms.
+	snippetTokens = 12
+	// the snippets are marked with control characters, which cannot be part of the escaped text
+	snippetMarkStart = "\x02"
+	snippetMarkEnd   = "\x03"
+)
+
+// searchStmt ranks the entries of the search index with bm25, matches of names weigh more than of descriptions.
+const searchStmt = `SELECT type, ref_id, snippet(search, -1, char(2), char(3), '…', ?) AS snippet,
+       bm25(search, 0.0, 0.0, 4.0, 1.0) AS rank
+FROM search
+WHERE search MATCH ?
+ORDER BY rank
+LIMIT ?`
+
+// Search is an Extension serving a full-text search across clients, projects and slot descriptions at /search.
+// The index is the FTS5 table search, which is kept in sync by triggers of the indexed tables.
+type Search struct{}
+
+func NewSearch() *Search {
+	return &Search{}
+}
+
+type searchHit struct {
+	Type    string
+	RefID   int `db:"ref_id"`
+	Snippet string
+	Rank    float64
+}
+
+// SearchMeta is added to the meta member of each found resource object.
+type SearchMeta struct {
+	// Score is the relevance of the resource, the higher the better.
+	Score float64 `json:"score"`
+	// Snippet is the matched text with the matches enclosed in <mark>, all other HTML is escaped.
+	Snippet string `json:"snippet"`
+}
+
+func (s *Search) Apply(route router.RouteElement) {
+	route.GET("search", s.Handle)
+	APIDoc.Describe(route, http.MethodGet, "search", &Operation{
+		Summary: "Search clients, projects and slots ranked by relevance",
+		Response: &Content{MediaType: "application/vnd.api+json", Schema: Schema{
+			"type":     "object",
+			"required": []string{"data"},
+			"properties": Schema{
+				"data": Schema{"type": "array", "items": Schema{"$ref": "#/components/schemas/ResourceIdentifier"}},
+			},
+		}},
+		Query: &struct {
+			Q     string `form:"q"`
+			Limit int    `form:"page[limit]"`
+		}{},
+	})
+}
+
+// Handle searches the words of the query parameter q, each word matches as prefix and all words have to match.
+// The resources are returned in the order of their relevance, at most page[limit] (default 20, max 100).
+func (s *Search) Handle(w http.ResponseWriter, req *http.Request) {
+	match := matchQuery(request.Query(req, "q"))
+	if match == "" {
+		writeErrors(w, http.StatusBadRequest, NewError(http.StatusBadRequest, "invalid_parameter", "search needs a word").
+			WithParameter("q"))
+		return
+	}
+	limit := min(max(request.QueryInt(req, "page[limit]", defaultSearchLimit), 1), maxSearchLimit)
+	data := make([]resourceObject, 0)
+	err := request.DB(req).DoTransaction(func(tx db.Transaction) error {
+		var hits []*searchHit
+		if err := tx.Select(&hits, searchStmt, snippetTokens, match, limit); err != nil {
+			return err
+		}
+		for _, hit := range hits {
+			obj, err := s.resourceObject(tx, hit)
+			if err != nil {
+				return err
+			}
+			if obj != nil {
+				data = append(data, obj)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		apiErrs := translateAll(req, err)
+		writeErrors(w, apiErrs[0].Status, apiErrs...)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+// resourceObject loads the resource of hit, it is nil if the type is not registered or the resource is gone.
+func (s *Search) resourceObject(tx db.Transaction, hit *searchHit) (resourceObject, error) {
+	resource, ok := atomicResources[hit.Type]
+	if !ok {
+		return nil, nil
+	}
+	item, err := resource.getByID(tx, hit.RefID)
+	if err != nil || item == nil {
+		return nil, err
+	}
+	resObj, err := jsonapi.MarshalResourceObject(item, nil)
+	if err != nil {
+		return nil, err
+	}
+	resObj.Links = map[string]any{"self": joinSelfLink(resource.selfLink(item))}
+	obj, err := toResourceObject(resObj)
+	if err != nil {
+		return nil, err
+	}
+	// bm25 is negative, the better the match the lower it is
+	obj["meta"] = &SearchMeta{Score: -hit.Rank, Snippet: highlight(hit.Snippet)}
+	return obj, nil
+}
+
+// matchQuery turns the words of q into an FTS5 query matching all words as prefix. The words are quoted,
+// so the FTS5 syntax cannot be used and no query fails to parse. It is empty if q has no word.
+func matchQuery(q string) string {
+	var terms []string
+	for _, word := range strings.Fields(q) {
+		if strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// highlight escapes snippet and encloses the matches marked by the search index in <mark>.
+func highlight(snippet string) string {
+	return strings.NewReplacer(snippetMarkStart, "<mark>", snippetMarkEnd, "</mark>").Replace(html.EscapeString(snippet))
+}
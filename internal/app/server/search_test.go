@@ -0,0 +1,133 @@
+package server
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMatchQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		q    string
+		want string
+	}{{
+		name: "GIVEN words THEN match all as prefix",
+		q:    " web  Relaunch ",
+		want: `"web"* "Relaunch"*`,
+	}, {
+		name: "GIVEN fts syntax THEN quote it",
+		q:    `name:web OR "x`,
+		want: `"name:web"* "OR"* """x"*`,
+	}, {
+		name: "GIVEN no word THEN empty",
+		q:    " - * ",
+		want: "",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchQuery(tt.q); got != tt.want {
+				t.Errorf("matchQuery() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "test.db")
+	srv := New().WithDBFile(dbFile).WithAssets(os.DirFS("../../../assets"))
+	connection, _, err := srv.setupDB()
+	if err != nil {
+		t.Fatalf("setupDB() error = %v", err)
+	}
+	_ = connection.Close()
+	database, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.Close() }()
+	for _, stmt := range []string{
+		`INSERT INTO client (id, name, description) VALUES (1, 'ACME', 'Website relaunch')`,
+		`INSERT INTO project (id, client_id, name, description) VALUES (1, 1, 'Website', NULL), (2, 1, 'Shop', 'old')`,
+		`INSERT INTO slot (id, project_id, activity, started_at, description)
+		 VALUES (1, 1, 0, '2025-03-01 08:00:00', 'Fixed <b>the</b> website header'), (2, 2, 0, '2025-03-01 10:00:00', NULL)`,
+		`UPDATE project SET description = 'Café website' WHERE id = 2`,
+		`UPDATE slot SET description = 'Shop website' WHERE id = 2`,
+	} {
+		if _, err := database.Exec(stmt); err != nil {
+			t.Fatalf("Exec(%s) error = %v", stmt, err)
+		}
+	}
+	type hit struct {
+		Type    string
+		RefID   int
+		Snippet string
+	}
+	search := func(q string) []hit {
+		rows, err := database.Query(searchStmt, snippetTokens, matchQuery(q), maxSearchLimit)
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		defer func() { _ = rows.Close() }()
+		var hits []hit
+		for rows.Next() {
+			var h hit
+			var rank float64
+			if err := rows.Scan(&h.Type, &h.RefID, &h.Snippet, &rank); err != nil {
+				t.Fatal(err)
+			}
+			h.Snippet = highlight(h.Snippet)
+			hits = append(hits, h)
+		}
+		return hits
+	}
+	tests := []struct {
+		name string
+		q    string
+		want []hit
+	}{{
+		name: "GIVEN word THEN rank name matches first",
+		q:    "WEB",
+		want: []hit{
+			{"project", 1, "<mark>Website</mark>"},
+			{"project.slot", 2, "Shop <mark>website</mark>"},
+			{"client", 1, "<mark>Website</mark> relaunch"},
+			{"project", 2, "Café <mark>website</mark>"},
+			{"project.slot", 1, "Fixed &lt;b&gt;the&lt;/b&gt; <mark>website</mark> header"},
+		},
+	}, {
+		name: "GIVEN words THEN match all in any column",
+		q:    "shop web",
+		want: []hit{
+			{"project", 2, "<mark>Shop</mark>"},
+			{"project.slot", 2, "<mark>Shop</mark> <mark>website</mark>"},
+		},
+	}, {
+		name: "GIVEN word without diacritics THEN match",
+		q:    "cafe",
+		want: []hit{
+			{"project", 2, "<mark>Café</mark> website"},
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, search(tt.q)); diff != "" {
+				t.Errorf("search mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("GIVEN deleted rows THEN remove them from index", func(t *testing.T) {
+		for _, stmt := range []string{`DELETE FROM slot`, `DELETE FROM project`, `DELETE FROM client`} {
+			if _, err := database.Exec(stmt); err != nil {
+				t.Fatalf("Exec(%s) error = %v", stmt, err)
+			}
+		}
+		if got := search("web"); len(got) != 0 {
+			t.Errorf("search = %v, want no hits", got)
+		}
+	})
+}
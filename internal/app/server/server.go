@@ -1,7 +1,6 @@
 package server
 
 import (
-	"context"
 	"io/fs"
 	"mime"
 	"net/http"
@@ -9,19 +8,26 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/rs/zerolog/log"
 	"github.com/vloryan/go-libs/httpx"
 	"github.com/vloryan/go-libs/httpx/router"
 	"github.com/vloryan/goltmux"
 	"github.com/vloryan/protrakgon/internal/app/server/db"
 	"github.com/vloryan/protrakgon/internal/app/server/db/sqlite"
-	"github.com/vloryan/protrakgon/internal/app/server/request"
 )
 
+// DefaultListenAddress is the address the server listens on without WithListenAddress.
+const DefaultListenAddress = ":8080"
+
 type Server struct {
-	HTTP   *httpx.Server
-	Router *goltmux.Router
+	HTTP *http.Server
+	// Redirect redirects HTTP to HTTPS if TLSOptions.RedirectAddress is set.
+	Redirect *http.Server
+	Router   *goltmux.Router
 	/*ContextRoot defines the url prefix for all api routes*/
 	ContextRoot string
 	/*ProxyLocation defines a prefix used from outside to route to this instance. E.g. if you use a reverse proxy, the location url has to be defined here in order to provide correct path of resources.		 */
@@ -33,6 +39,20 @@ type Server struct {
 	moduleCreator    []func() Module
 	modules          []Module
 	indexHtml        string
+	backups          *Backups
+	backupInterval   time.Duration
+	tls              *TLSOptions
+	shutdownTimeout  time.Duration
+	migrator         *db.Migrator
+	schemaVersion    uint
+	stopping         atomic.Bool
+	slowQuery        time.Duration
+	tracer           *Tracer
+	rateLimit        *rateLimiter
+	maxBody          int64
+	maxUpload        int64
+	cors             *CORSOptions
+	csrf             *csrfProtection
 }
 
 func (svr *Server) WithModule(creator func() Module) *Server {
@@ -64,6 +84,36 @@ func (svr *Server) WithProxyLocation(proxyLocation string) *Server {
 	svr.ProxyLocation = proxyLocation
 	return svr
 }
+
+// WithBackups enables backups of the database. If interval is positive, a backup is written every interval.
+func (svr *Server) WithBackups(backups *Backups, interval time.Duration) *Server {
+	svr.backups = backups
+	svr.backupInterval = interval
+	return svr
+}
+
+// Backups returns the backups of the database or nil if backups are not enabled.
+func (svr *Server) Backups() *Backups {
+	return svr.backups
+}
+
+// WithListenAddress sets the TCP address the server listens on, e.g. ":8080" or "127.0.0.1:8443".
+func (svr *Server) WithListenAddress(addr string) *Server {
+	svr.HTTP.Addr = addr
+	return svr
+}
+
+// WithTLS serves HTTPS as configured by options. Without certificate and key files and without a
+// self-signed certificate HTTP is served.
+func (svr *Server) WithTLS(options *TLSOptions) *Server {
+	if options == nil || options.CertFile == "" && !options.SelfSigned {
+		svr.tls = nil
+		return svr
+	}
+	svr.tls = options
+	return svr
+}
+
 func (svr *Server) WithApiRoutePrefix(apiRoutePrefix string) *Server {
 	svr.ApiRoutePrefix = apiRoutePrefix
 	return svr
@@ -76,44 +126,82 @@ type Module interface {
 func New() *Server {
 	r := goltmux.NewRouter()
 	return &Server{
-		HTTP:   httpx.NewServer(r),
-		Router: r,
+		HTTP:            &http.Server{Addr: DefaultListenAddress, ReadHeaderTimeout: 10 * time.Second},
+		Router:          r,
+		shutdownTimeout: DefaultShutdownTimeout,
+		maxBody:         DefaultMaxBodySize,
+		maxUpload:       DefaultMaxUploadSize,
 	}
 }
 
-func (svr *Server) setupDB() (*sqlite.Connection, *db.MigrationInfo, error) {
-	connection, err := sqlite.NewConnection(svr.databaseFileName + "?_fk=on") // with foreign key support
+// DBFileName returns the name of the database file.
+func (svr *Server) DBFileName() string {
+	return svr.databaseFileName
+}
+
+// OpenDB opens the database without migrating it.
+func (svr *Server) OpenDB() (*sqlite.Connection, error) {
+	return sqlite.NewConnection(svr.databaseFileName + "?_fk=on") // with foreign key support
+}
+
+// Migrator returns the migrator applying the migrations of the assets to connection.
+func (svr *Server) Migrator(connection *sqlite.Connection) (*db.Migrator, error) {
+	d, err := iofs.New(svr.assets, "migrations")
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
+	drv, err := sqlite.MigrateDriver(connection)
+	if err != nil {
+		return nil, err
+	}
+	return db.NewMigrator(drv, "migrations", d)
+}
 
-	d, err := iofs.New(svr.assets, "migrations")
+// Modules creates the modules once and returns them. Creating the modules registers their resources,
+// so commands working without routes call it before they access the registries.
+func (svr *Server) Modules() []Module {
+	if svr.modules == nil {
+		for _, creator := range svr.moduleCreator {
+			svr.modules = append(svr.modules, creator())
+		}
+	}
+	return svr.modules
+}
+
+func (svr *Server) setupDB() (*sqlite.Connection, *db.MigrationInfo, error) {
+	connection, err := svr.OpenDB()
 	if err != nil {
 		return nil, nil, err
 	}
-	drv, err := sqlite.MigrateDriver(connection)
+	migrator, err := svr.Migrator(connection)
 	if err != nil {
+		_ = connection.Close()
 		return nil, nil, err
 	}
-	info, err := db.DoMigration(drv, "migrations", d)
+	info, err := migrator.Up()
 	if err != nil {
+		_ = connection.Close()
 		return nil, nil, err
 	}
+	if info.Migrated() {
+		log.Info().Uint("from", info.MigratedVersion).Uint("to", info.CurrentVersion).Msg("database migrated")
+	}
+	svr.migrator = migrator
+	svr.schemaVersion = info.CurrentVersion
 
 	return connection, info, nil
 }
 
-func (svr *Server) Run() error {
-	connection, _, err := svr.setupDB()
-	if err != nil {
-		return err
-	}
+// setup registers the routes of the modules and builds the handler of the HTTP server.
+func (svr *Server) setup(connection db.Connection) error {
+	var err error
+	database := db.WithStatementLogger(connection, Monitor.ObserveQuery).WithSlowQueryThreshold(svr.slowQuery)
 	fullPrefix := path.Join(svr.ProxyLocation, svr.ContextRoot)
 	svr.indexHtml, err = httpx.GenerateReplacedIndexHTML(svr.uiSrc, fullPrefix, `{apiUrl: "`+path.Join(fullPrefix, svr.ApiRoutePrefix)+`", contextRoot: "`+fullPrefix+`"}`)
 	if err != nil {
 		panic(err)
 	}
-	svr.Router.NotFoundHandler = func(w http.ResponseWriter, r *http.Request) {
+	svr.Router.NotFoundHandler = Monitor.Instrument("ui", func(w http.ResponseWriter, r *http.Request) {
 		if !strings.HasPrefix(r.URL.Path, svr.ContextRoot) {
 			w.WriteHeader(http.StatusNotFound)
 			return
@@ -128,20 +216,43 @@ func (svr *Server) Run() error {
 		w.WriteHeader(http.StatusOK)
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		_, _ = w.Write([]byte(svr.indexHtml))
-	}
+	})
+	svr.setupMonitoring(database)
 
+	routeMethods := make(map[string][]string)
 	root := router.NewRoute(path.Join(svr.ContextRoot, svr.ApiRoutePrefix), func(method, path string, handler http.HandlerFunc) {
-		svr.Router.HandleMethod(method, path, handler)
+		if _, ok := routeMethods[path]; !ok {
+			svr.Router.HandleMethod(http.MethodOptions, path, Monitor.Instrument(path, svr.preflight(func() []string {
+				return routeMethods[path]
+			})))
+		}
+		routeMethods[path] = append(routeMethods[path], method)
+		svr.Router.HandleMethod(method, path, Monitor.Instrument(path, svr.allowCrossOrigin(svr.limitRequests(method, path, svr.checkCSRF(handler)))))
 	})
-	for _, creator := range svr.moduleCreator {
-		module := creator()
-		svr.modules = append(svr.modules, module)
+	for _, module := range svr.Modules() {
 		module.Setup(root)
 	}
+	if svr.csrf != nil {
+		root.GET("csrf", svr.csrfToken)
+		APIDoc.Describe(root, http.MethodGet, "csrf", &Operation{
+			Summary: "Get the CSRF token of the session cookie",
+			Response: &Content{MediaType: "application/vnd.api+json", Schema: Schema{
+				"type":       "object",
+				"properties": Schema{"meta": Schema{"type": "object", "properties": Schema{"token": Schema{"type": "string"}}}},
+			}},
+		})
+	}
 
-	svr.HTTP.WithMiddleware(func(req *http.Request) *http.Request {
-		return req.WithContext(context.WithValue(req.Context(), request.CtxKeyDatabase, db.WithStatementLogger(connection)))
-	})
+	handler := svr.withDatabase(database, svr.Router)
+	if svr.tls != nil {
+		if svr.HTTP.TLSConfig, err = svr.tls.tlsConfig(filepath.Dir(svr.databaseFileName)); err != nil {
+			return err
+		}
+		if svr.tls.HSTSMaxAge > 0 {
+			handler = hsts(svr.tls.HSTSMaxAge, handler)
+		}
+	}
+	svr.HTTP.Handler = svr.accessLog(handler)
 
 	/*_ = svr.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
 		pathTemplate, err := route.GetPathTemplate()
@@ -168,8 +279,12 @@ func (svr *Server) Run() error {
 		return nil
 	})*/
 
-	return svr.HTTP.ListenAndServe()
+	if svr.tls != nil && svr.tls.RedirectAddress != "" {
+		svr.Redirect = &http.Server{Addr: svr.tls.RedirectAddress, Handler: httpsRedirect(svr.HTTP.Addr), ReadHeaderTimeout: svr.HTTP.ReadHeaderTimeout}
+	}
+	return nil
 }
+
 func isIndexAsset(path string) bool {
 	match, _ := regexp.MatchString("/assets/(index|icon)-[\\w-]+\\.(css|js|svg)$", path)
 	return match
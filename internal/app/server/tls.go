@@ -0,0 +1,222 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	selfSignedValidity = 365 * 24 * time.Hour
+	// selfSignedRenewal is the remaining validity at which a self-signed certificate is replaced on startup.
+	selfSignedRenewal = 30 * 24 * time.Hour
+)
+
+// TLSOptions configures HTTPS, it is enabled if CertFile and KeyFile are set or SelfSigned is enabled.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// SelfSigned generates a certificate for Hosts into CertFile and KeyFile if they do not exist or expire soon.
+	// Without files, the certificate is stored in the directory tls next to the database.
+	SelfSigned bool
+	// Hosts are the names of the self-signed certificate, localhost if empty.
+	Hosts []string
+	// RedirectAddress is the address of a listener redirecting HTTP requests to HTTPS, disabled if empty.
+	RedirectAddress string
+	// HSTSMaxAge is sent as Strict-Transport-Security header, disabled if 0.
+	HSTSMaxAge time.Duration
+}
+
+// tlsConfig returns the configuration of the HTTPS server, the certificate is reloaded when its files change.
+// A self-signed certificate without files is stored in the directory tls of dir.
+func (o *TLSOptions) tlsConfig(dir string) (*tls.Config, error) {
+	certFile, keyFile := o.CertFile, o.KeyFile
+	if o.SelfSigned {
+		if certFile == "" && keyFile == "" {
+			certFile, keyFile = filepath.Join(dir, "tls", "cert.pem"), filepath.Join(dir, "tls", "key.pem")
+		}
+		hosts := o.Hosts
+		if len(hosts) == 0 {
+			hosts = []string{"localhost"}
+		}
+		if err := ensureSelfSigned(certFile, keyFile, hosts, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: reloader.GetCertificate}, nil
+}
+
+// certReloader serves the certificate of its files and loads it again when the files are modified,
+// so rotated certificates are used without restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	modTime, err := r.lastModified()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	modTime, err := r.lastModified()
+	if err == nil && !modTime.Equal(r.modTime) {
+		// a certificate being replaced can be incomplete, the previous one is used until loading succeeds
+		if err := r.load(modTime); err != nil {
+			log.Warn().Err(err).Str("file", r.certFile).Msg("failed to reload certificate")
+		} else {
+			log.Info().Str("file", r.certFile).Msg("certificate reloaded")
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// lastModified returns the latest modification time of the certificate and the key file.
+func (r *certReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, fileName := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(fileName)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// ensureSelfSigned writes a self-signed certificate for hosts unless certFile holds a certificate valid
+// for at least selfSignedRenewal.
+func ensureSelfSigned(certFile, keyFile string, hosts []string, now time.Time) error {
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil && now.Add(selfSignedRenewal).Before(cert.Leaf.NotAfter) {
+		return nil
+	}
+	certPEM, keyPEM, err := selfSignedCertificate(hosts, now)
+	if err != nil {
+		return err
+	}
+	for _, fileName := range []string{certFile, keyFile} {
+		if err := os.MkdirAll(filepath.Dir(fileName), 0o750); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+		return err
+	}
+	log.Info().Str("file", certFile).Strs("hosts", hosts).Msg("self-signed certificate generated")
+	return nil
+}
+
+// selfSignedCertificate returns a PEM encoded certificate and key for hosts, which are DNS names or IP addresses.
+func selfSignedCertificate(hosts []string, now time.Time) ([]byte, []byte, error) {
+	if len(hosts) == 0 {
+		return nil, nil, errors.New("self-signed certificate needs at least one host")
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"protrakgon"}, CommonName: hosts[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// hsts adds the Strict-Transport-Security header, so browsers use HTTPS for maxAge without asking.
+func hsts(maxAge time.Duration, next http.Handler) http.Handler {
+	value := "max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+		next.ServeHTTP(w, req)
+	})
+}
+
+// httpsRedirect redirects all requests permanently to the HTTPS server listening on httpsAddress.
+func httpsRedirect(httpsAddress string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddress)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(req.Host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if net.ParseIP(host) != nil && net.ParseIP(host).To4() == nil {
+			host = "[" + host + "]"
+		}
+		target := fmt.Sprintf("https://%s%s", host, req.URL.RequestURI())
+		http.Redirect(w, req, target, http.StatusPermanentRedirect)
+	})
+}
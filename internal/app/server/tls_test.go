@@ -0,0 +1,159 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCertReloader_GetCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls", "cert.pem")
+	keyFile := filepath.Join(dir, "tls", "key.pem")
+	now := time.Now()
+	if err := ensureSelfSigned(certFile, keyFile, []string{"localhost", "127.0.0.1"}, now); err != nil {
+		t.Fatalf("ensureSelfSigned() error = %v", err)
+	}
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertReloader() error = %v", err)
+	}
+	first, _ := reloader.GetCertificate(&tls.ClientHelloInfo{})
+	if diff := cmp.Diff([]string{"localhost"}, first.Leaf.DNSNames); diff != "" {
+		t.Errorf("GetCertificate() DNS names mismatch (-want +got):\n%s", diff)
+	}
+
+	// a valid certificate is kept
+	if err := ensureSelfSigned(certFile, keyFile, []string{"localhost"}, now); err != nil {
+		t.Fatalf("ensureSelfSigned() error = %v", err)
+	}
+	if got, _ := reloader.GetCertificate(&tls.ClientHelloInfo{}); got != first {
+		t.Errorf("GetCertificate() reloaded unchanged certificate")
+	}
+
+	// a certificate expiring soon is replaced and the rotated files are reloaded
+	if err := ensureSelfSigned(certFile, keyFile, []string{"time.lan"}, now.Add(selfSignedValidity)); err != nil {
+		t.Fatalf("ensureSelfSigned() error = %v", err)
+	}
+	later := now.Add(time.Minute)
+	for _, fileName := range []string{certFile, keyFile} {
+		if err := os.Chtimes(fileName, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	second, _ := reloader.GetCertificate(&tls.ClientHelloInfo{})
+	if diff := cmp.Diff([]string{"time.lan"}, second.Leaf.DNSNames); diff != "" {
+		t.Errorf("GetCertificate() DNS names after rotation mismatch (-want +got):\n%s", diff)
+	}
+
+	// a broken certificate keeps the previous one
+	if err := os.WriteFile(certFile, []byte("broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reloader.GetCertificate(&tls.ClientHelloInfo{}); got != second {
+		t.Errorf("GetCertificate() replaced certificate by broken one")
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	tests := []struct {
+		name         string
+		httpsAddress string
+		target       string
+		want         string
+	}{{
+		name:         "GIVEN default port THEN redirect without port",
+		httpsAddress: ":443",
+		target:       "http://time.lan/v1/client?page[size]=10",
+		want:         "https://time.lan/v1/client?page[size]=10",
+	}, {
+		name:         "GIVEN other port THEN replace port",
+		httpsAddress: ":8443",
+		target:       "http://time.lan:8080/",
+		want:         "https://time.lan:8443/",
+	}, {
+		name:         "GIVEN IPv6 host THEN keep brackets",
+		httpsAddress: ":443",
+		target:       "http://[::1]:80/",
+		want:         "https://[::1]/",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			httpsRedirect(tt.httpsAddress).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != http.StatusPermanentRedirect {
+				t.Errorf("httpsRedirect() status = %d, want %d", rec.Code, http.StatusPermanentRedirect)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("httpsRedirect() location = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	rec := httptest.NewRecorder()
+	hsts(180*24*time.Hour, http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=15552000" {
+		t.Errorf("hsts() header = %s, want max-age=15552000", got)
+	}
+}
+
+func TestServer_WithTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *TLSOptions
+		wantTLS bool
+	}{{
+		name:    "GIVEN no options THEN serve HTTP",
+		options: nil,
+	}, {
+		name:    "GIVEN options without files THEN serve HTTP",
+		options: &TLSOptions{HSTSMaxAge: time.Hour},
+	}, {
+		name:    "GIVEN certificate files THEN serve HTTPS",
+		options: &TLSOptions{CertFile: "cert.pem", KeyFile: "key.pem"},
+		wantTLS: true,
+	}, {
+		name:    "GIVEN self-signed without files THEN serve HTTPS",
+		options: &TLSOptions{SelfSigned: true},
+		wantTLS: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := New().WithTLS(tt.options)
+			if got := svr.tls != nil; got != tt.wantTLS {
+				t.Errorf("WithTLS() TLS = %t, want %t", got, tt.wantTLS)
+			}
+		})
+	}
+}
+
+func TestTLSOptions_tlsConfig(t *testing.T) {
+	dir := t.TempDir()
+	options := &TLSOptions{SelfSigned: true}
+
+	if _, err := options.tlsConfig(dir); err != nil {
+		t.Fatalf("tlsConfig() error = %v", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "tls", "cert.pem"), filepath.Join(dir, "tls", "key.pem"))
+	if err != nil {
+		t.Fatalf("tlsConfig() stored no certificate next to the database: %v", err)
+	}
+	if diff := cmp.Diff([]string{"localhost"}, cert.Leaf.DNSNames); diff != "" {
+		t.Errorf("tlsConfig() hosts mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	spanKindServer = 2
+	spanKindClient = 3
+
+	spanStatusError = 2
+
+	traceBatchSize     = 512
+	traceQueueSize     = 4096
+	traceExportPeriod  = 5 * time.Second
+	traceExportTimeout = 10 * time.Second
+)
+
+type spanContextKey struct{}
+
+// Span is an operation of a trace, the spans of a request and its statements are exported by the Tracer.
+type Span struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	ParentID   [8]byte
+	Name       string
+	Kind       int
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]any
+	Failed     bool
+}
+
+// SpanFromContext returns the span of the request of ctx or nil without tracing.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// Tracer exports spans to an OpenTelemetry collector with OTLP over HTTP in the JSON encoding.
+// Spans are queued and exported in batches by Run, they are dropped if the queue is full.
+type Tracer struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+	queue       chan *Span
+	dropped     sync.Once
+}
+
+// NewTracer exports to the collector at endpoint, e.g. http://localhost:4318.
+func NewTracer(endpoint, serviceName string) *Tracer {
+	return &Tracer{
+		endpoint:    strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: traceExportTimeout},
+		queue:       make(chan *Span, traceQueueSize),
+	}
+}
+
+// StartRequest starts the server span of req, it continues the trace of a W3C traceparent header.
+func (t *Tracer) StartRequest(req *http.Request) *Span {
+	span := &Span{Name: req.Method, Kind: spanKindServer, Start: time.Now(), Attributes: map[string]any{
+		"http.request.method": req.Method,
+		"url.path":            req.URL.Path,
+	}}
+	if traceID, parentID, ok := parseTraceParent(req.Header.Get("traceparent")); ok {
+		span.TraceID = traceID
+		span.ParentID = parentID
+	} else {
+		_, _ = rand.Read(span.TraceID[:])
+	}
+	_, _ = rand.Read(span.SpanID[:])
+	return span
+}
+
+// StartChild starts a span of parent.
+func (t *Tracer) StartChild(parent *Span, name string, kind int, start time.Time) *Span {
+	span := &Span{TraceID: parent.TraceID, ParentID: parent.SpanID, Name: name, Kind: kind, Start: start, Attributes: map[string]any{}}
+	_, _ = rand.Read(span.SpanID[:])
+	return span
+}
+
+// Finish ends span and queues it for export.
+func (t *Tracer) Finish(span *Span, end time.Time) {
+	span.End = end
+	select {
+	case t.queue <- span:
+	default:
+		t.dropped.Do(func() {
+			log.Warn().Str("endpoint", t.endpoint).Msg("trace queue is full, spans are dropped")
+		})
+	}
+}
+
+// Run exports the queued spans until ctx is done, then it exports the remaining spans.
+func (t *Tracer) Run(ctx context.Context) {
+	ticker := time.NewTicker(traceExportPeriod)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, traceBatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case span := <-t.queue:
+					batch = append(batch, span)
+				default:
+					t.export(batch)
+					return
+				}
+			}
+		case span := <-t.queue:
+			batch = append(batch, span)
+			if len(batch) < traceBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		t.export(batch)
+		batch = batch[:0]
+	}
+}
+
+func (t *Tracer) export(spans []*Span) {
+	if len(spans) == 0 {
+		return
+	}
+	body, err := json.Marshal(t.document(spans))
+	if err != nil {
+		log.Err(err).Msg("failed to encode spans")
+		return
+	}
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Warn().Err(err).Int("spans", len(spans)).Msg("failed to export spans")
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Warn().Int("status", resp.StatusCode).Int("spans", len(spans)).Msg("failed to export spans")
+	}
+}
+
+// document returns the OTLP ExportTraceServiceRequest of spans.
+func (t *Tracer) document(spans []*Span) map[string]any {
+	otlpSpans := make([]map[string]any, len(spans))
+	for i, span := range spans {
+		s := map[string]any{
+			"traceId":           hex.EncodeToString(span.TraceID[:]),
+			"spanId":            hex.EncodeToString(span.SpanID[:]),
+			"name":              span.Name,
+			"kind":              span.Kind,
+			"startTimeUnixNano": strconv.FormatInt(span.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(span.Attributes),
+		}
+		if span.ParentID != [8]byte{} {
+			s["parentSpanId"] = hex.EncodeToString(span.ParentID[:])
+		}
+		if span.Failed {
+			s["status"] = map[string]any{"code": spanStatusError}
+		}
+		otlpSpans[i] = s
+	}
+	return map[string]any{"resourceSpans": []any{map[string]any{
+		"resource": map[string]any{"attributes": otlpAttributes(map[string]any{"service.name": t.serviceName})},
+		"scopeSpans": []any{map[string]any{
+			"scope": map[string]any{"name": "protrakgon"},
+			"spans": otlpSpans,
+		}},
+	}}}
+}
+
+func otlpAttributes(attributes map[string]any) []map[string]any {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]map[string]any, 0, len(attributes))
+	for _, key := range keys {
+		var value map[string]any
+		switch v := attributes[key].(type) {
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		result = append(result, map[string]any{"key": key, "value": value})
+	}
+	return result
+}
+
+// parseTraceParent returns the trace and parent span id of a W3C traceparent header, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func parseTraceParent(header string) ([16]byte, [8]byte, bool) {
+	var traceID [16]byte
+	var parentID [8]byte
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil || traceID == [16]byte{} {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil || parentID == [8]byte{} {
+		return traceID, parentID, false
+	}
+	return traceID, parentID, true
+}
@@ -0,0 +1,179 @@
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/go-libs/reflectx"
+)
+
+// Validator is implemented by resources with rules that can not be expressed by tags,
+// e.g. rules depending on several fields. Validate returns Errors or nil.
+type Validator interface {
+	Validate() error
+}
+
+type identifiable interface {
+	GetIdentifier() *jsonapi.ResourceIdentifierObject
+}
+
+// FieldError describes a field of a resource violating a rule.
+type FieldError struct {
+	// Pointer references the field in the request document, e.g. /data/attributes/name.
+	Pointer string
+	Rule    string
+	Message string
+}
+
+// Errors collects all field errors of a resource.
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Pointer + " " + fieldErr.Message
+	}
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+// Attribute creates the error of a rule violated by the attribute name.
+func Attribute(name, rule, message string) *FieldError {
+	return &FieldError{Pointer: "/data/attributes/" + name, Rule: rule, Message: message}
+}
+
+// Relationship creates the error of a rule violated by the relationship name.
+func Relationship(name, rule, message string) *FieldError {
+	return &FieldError{Pointer: "/data/relationships/" + name, Rule: rule, Message: message}
+}
+
+// Struct validates the fields of v by their validate tag and calls Validate if v implements Validator.
+// It returns Errors containing all violations or nil.
+//
+// Supported rules are `validate:"required,min=N,max=N"`. Required rejects zero values, nil pointers and
+// related resources without id. Min and max limit the length of strings and the value of numbers.
+func Struct(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: %T is no struct", v)
+	}
+	var errs Errors
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := reflectx.Tag(field, "validate").Value
+		if tag == "" || tag == "-" || !field.IsExported() {
+			continue
+		}
+		fieldErrs, err := validateField(field, rv.Field(i), tag)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, fieldErrs...)
+	}
+	if validator, ok := v.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			custom, ok := err.(Errors)
+			if !ok {
+				return err
+			}
+			errs = append(errs, custom...)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func validateField(field reflect.StructField, value reflect.Value, tag string) (Errors, error) {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		name = field.Name
+	}
+	newError := Attribute
+	if _, ok := value.Interface().(identifiable); ok {
+		newError = Relationship
+	}
+	var errs Errors
+	for _, rule := range strings.Split(tag, ",") {
+		ruleName, param, _ := strings.Cut(rule, "=")
+		switch ruleName {
+		case "required":
+			if isEmpty(value) {
+				errs = append(errs, newError(name, ruleName, "must not be empty"))
+				// further rules of empty values are meaningless
+				return errs, nil
+			}
+		case "min", "max":
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return nil, fmt.Errorf("validate: invalid %s of %s: %w", ruleName, field.Name, err)
+			}
+			if fieldErr := checkLimit(name, ruleName, limit, value, newError); fieldErr != nil {
+				errs = append(errs, fieldErr)
+			}
+		default:
+			return nil, fmt.Errorf("validate: unknown rule %s of %s", ruleName, field.Name)
+		}
+	}
+	return errs, nil
+}
+
+func isEmpty(value reflect.Value) bool {
+	if value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return true
+		}
+		if related, ok := value.Interface().(identifiable); ok {
+			id := related.GetIdentifier()
+			return id == nil || id.ID == ""
+		}
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.String {
+		return strings.TrimSpace(value.String()) == ""
+	}
+	return value.IsZero()
+}
+
+func checkLimit(name, rule string, limit float64, value reflect.Value, newError func(name, rule, message string) *FieldError) *FieldError {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	var actual float64
+	unit := ""
+	switch value.Kind() {
+	case reflect.String:
+		actual = float64(utf8.RuneCountInString(value.String()))
+		unit = " characters"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = value.Float()
+	default:
+		return nil
+	}
+	limitText := strconv.FormatFloat(limit, 'f', -1, 64)
+	if rule == "min" && actual < limit {
+		return newError(name, rule, "must be at least "+limitText+unit)
+	}
+	if rule == "max" && actual > limit {
+		return newError(name, rule, "must be at most "+limitText+unit)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package validate
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/go-libs/jsonapi"
+)
+
+type related struct {
+	ID string
+}
+
+func (r *related) GetIdentifier() *jsonapi.ResourceIdentifierObject {
+	return &jsonapi.ResourceIdentifierObject{Type: "related", ID: r.ID}
+}
+
+type item struct {
+	Name        string   `json:"name,omitempty" validate:"required,max=5"`
+	Description *string  `json:"description,omitempty" validate:"max=3"`
+	Count       int      `json:"count" validate:"min=1"`
+	Related     *related `json:"related,omitempty" validate:"required"`
+	Untagged    string   `json:"untagged"`
+}
+
+type validatedItem struct {
+	Name string `json:"name" validate:"required"`
+}
+
+func (v *validatedItem) Validate() error {
+	if v.Name == "forbidden" {
+		return Errors{Attribute("name", "forbidden", "must not be forbidden")}
+	}
+	return nil
+}
+
+func TestStruct(t *testing.T) {
+	tooLong := "long text"
+	tests := []struct {
+		name string
+		v    any
+		want Errors
+	}{{
+		name: "GIVEN valid item THEN return nil",
+		v:    &item{Name: "name", Count: 1, Related: &related{ID: "1"}},
+	}, {
+		name: "GIVEN empty item THEN return all violations",
+		v:    &item{Name: "  "},
+		want: Errors{
+			Attribute("name", "required", "must not be empty"),
+			Attribute("count", "min", "must be at least 1"),
+			Relationship("related", "required", "must not be empty"),
+		},
+	}, {
+		name: "GIVEN too long strings THEN return max violations",
+		v:    &item{Name: "too long", Description: &tooLong, Count: 1, Related: &related{ID: "1"}},
+		want: Errors{
+			Attribute("name", "max", "must be at most 5 characters"),
+			Attribute("description", "max", "must be at most 3 characters"),
+		},
+	}, {
+		name: "GIVEN related resource without id THEN return required violation",
+		v:    &item{Name: "name", Count: 1, Related: &related{}},
+		want: Errors{Relationship("related", "required", "must not be empty")},
+	}, {
+		name: "GIVEN Validator THEN add its violations",
+		v:    &validatedItem{Name: "forbidden"},
+		want: Errors{Attribute("name", "forbidden", "must not be forbidden")},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(tt.v)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Struct() error = %v, want nil", err)
+				}
+				return
+			}
+			var got Errors
+			if !errors.As(err, &got) {
+				t.Fatalf("Struct() error = %v, want Errors", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Struct() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
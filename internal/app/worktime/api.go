@@ -0,0 +1,33 @@
+package worktime
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/protrakgon/internal/app/server"
+)
+
+// Handlers returns the handlers of the working-time account, the days of the slots are based on zone.
+func Handlers(zone *time.Location) []jsonapi.ResourceHandler {
+	server.RegisterError(ErrScheduleExists, http.StatusConflict, "schedule_exists", "/data/attributes/validFrom")
+	server.RegisterError(ErrNoSchedule, http.StatusConflict, "no_work_schedule", "")
+	server.RegisterError(ErrInvalidPeriod, http.StatusBadRequest, "invalid_parameter", "")
+	server.RegisterError(ErrInvalidDate, http.StatusBadRequest, "invalid_parameter", "")
+	server.RegisterError(ErrUntilTooLate, http.StatusBadRequest, "invalid_parameter", "")
+	handler := NewHandler(zone)
+	registerAtomicResources(handler.Service)
+	return []jsonapi.ResourceHandler{
+		handler,
+	}
+}
+
+// registerAtomicResources makes the work schedules of service available to atomic operations and archives.
+func registerAtomicResources(service server.CrudService[*WorkSchedule, *ScheduleFilter]) {
+	server.RegisterAtomicResource("worktime.schedule", service, func() *WorkSchedule {
+		return &WorkSchedule{}
+	}, func(item *WorkSchedule) string {
+		return "worktime/schedule/" + strconv.Itoa(item.ID)
+	})
+}
@@ -0,0 +1,36 @@
+package worktime
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/protrakgon/internal/app/server"
+)
+
+func TestRegisterAtomicResources_archive(t *testing.T) {
+	exported := &inMemScheduleService{Schedules: []*WorkSchedule{
+		{ID: 4, ValidFrom: "2025-01-01", WeeklyMinutes: 2400, WorkingDays: WorkingWeek},
+		{ID: 9, ValidFrom: "2025-07-01", WeeklyMinutes: 1200, WorkingDays: WorkingWeek},
+	}}
+	registerAtomicResources(exported)
+	var archive bytes.Buffer
+	if _, err := server.ExportArchive(nil, &archive, 1); err != nil {
+		t.Fatalf("ExportArchive() error = %v", err)
+	}
+	imported := &inMemScheduleService{}
+	registerAtomicResources(imported)
+	want := []*WorkSchedule{
+		{ID: 1, ValidFrom: "2025-01-01", WeeklyMinutes: 2400, WorkingDays: WorkingWeek},
+		{ID: 2, ValidFrom: "2025-07-01", WeeklyMinutes: 1200, WorkingDays: WorkingWeek},
+	}
+
+	_, err := server.ImportArchive(nil, bytes.NewReader(archive.Bytes()), int64(archive.Len()), 1)
+
+	if err != nil {
+		t.Fatalf("ImportArchive() error = %v", err)
+	}
+	if diff := cmp.Diff(want, imported.Schedules); diff != "" {
+		t.Errorf("ImportArchive() schedules mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,211 @@
+package worktime
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/protrakgon/internal/app/project"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+)
+
+// Balance compares the worked with the target minutes of a day, an ISO week or a month. Periods cut by
+// the range of the report only cover the days of the range. The account starts with the first schedule,
+// the balance of all periods before is carried forward.
+type Balance struct {
+	// ID is the day, e.g. 2025-01-15, the week, e.g. 2025-W03, or the month, e.g. 2025-01.
+	ID            string `json:"-"`
+	Period        Period `json:"period"`
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	TargetMinutes int    `json:"targetMinutes"`
+	// WorkedMinutes is the work minus the breaks of the period.
+	WorkedMinutes int `json:"workedMinutes"`
+	// BalanceMinutes is the overtime of the period, negative if less than the target was worked.
+	BalanceMinutes int `json:"balanceMinutes"`
+	// CarriedMinutes is the balance of all periods before.
+	CarriedMinutes int `json:"carriedMinutes"`
+	// TotalMinutes is the balance of the account at the end of the period.
+	TotalMinutes int `json:"totalMinutes"`
+}
+
+func (b *Balance) SetIdentifier(id *jsonapi.ResourceIdentifierObject) {
+	if id == nil || id.Type != "worktime.balance" {
+		log.Error().Msgf("Balance identifier object is invalid")
+		return
+	}
+	b.ID = id.ID
+}
+
+func (b *Balance) GetIdentifier() *jsonapi.ResourceIdentifierObject {
+	return &jsonapi.ResourceIdentifierObject{Type: "worktime.balance", ID: b.ID}
+}
+
+// BalanceFilter selects the days of a report, e.g. 2025-01-01, without From it starts with the account
+// and without Until it ends today. Until is at most a year after today. The default period is month.
+type BalanceFilter struct {
+	From   string `form:"filter[from]"`
+	Until  string `form:"filter[until]"`
+	Period Period `form:"filter[period]"`
+}
+
+var (
+	ErrNoSchedule    = errors.New("no work schedule")
+	ErrInvalidPeriod = errors.New("period must be day, week or month")
+	ErrInvalidDate   = errors.New("date must be like 2025-01-31")
+	ErrUntilTooLate  = errors.New("until must not be more than a year after today")
+)
+
+type BalanceService interface {
+	Balances(tx db.Transaction, filter *BalanceFilter) ([]*Balance, error)
+}
+
+// NewBalanceService computes the balances of the slots of slotRepo, the days of the slots are based on zone.
+func NewBalanceService(schedules ScheduleService, slotRepo project.SlotRepo, zone *time.Location) BalanceService {
+	return &balanceService{schedules: schedules, slotRepo: slotRepo, zone: zone, now: time.Now}
+}
+
+type balanceService struct {
+	schedules ScheduleService
+	slotRepo  project.SlotRepo
+	zone      *time.Location
+	now       func() time.Time
+}
+
+// Balances returns the balances of the periods of the filter in chronological order. Running slots are
+// not counted until they end.
+func (s *balanceService) Balances(tx db.Transaction, filter *BalanceFilter) ([]*Balance, error) {
+	if filter.Period == "" {
+		filter.Period = PeriodMonth
+	}
+	if filter.Period != PeriodDay && filter.Period != PeriodWeek && filter.Period != PeriodMonth {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPeriod, filter.Period)
+	}
+	schedules, err := s.schedules.GetAll(tx, &pagination.Page{Limit: -1}, &ScheduleFilter{})
+	if err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, ErrNoSchedule
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].ValidFrom < schedules[j].ValidFrom
+	})
+	accountStart, err := time.ParseInLocation(time.DateOnly, schedules[0].ValidFrom, s.zone)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().In(s.zone)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.zone)
+	until := today
+	if filter.Until != "" {
+		if until, err = time.ParseInLocation(time.DateOnly, filter.Until, s.zone); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidDate, filter.Until)
+		}
+		// the report has a balance per day up to until
+		if until.After(today.AddDate(1, 0, 0)) {
+			return nil, fmt.Errorf("%w: %s", ErrUntilTooLate, filter.Until)
+		}
+	}
+	from := accountStart
+	if filter.From != "" {
+		day, err := time.ParseInLocation(time.DateOnly, filter.From, s.zone)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidDate, filter.From)
+		}
+		if day.After(from) {
+			from = day
+		}
+	}
+	worked, err := s.workedMinutes(tx, accountStart, until.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	return computeBalances(schedules, worked, accountStart, from, until, filter.Period), nil
+}
+
+// workedMinutes returns the work minus the breaks per day of the ended slots starting in [start, end).
+func (s *balanceService) workedMinutes(tx db.Transaction, start, end time.Time) (map[string]int, error) {
+	from := start.UTC().AddDate(0, 0, -1)
+	until := end.UTC().AddDate(0, 0, 1)
+	slots, err := s.slotRepo.GetAll(tx, &pagination.Page{Limit: -1}, &project.SlotFilter{
+		From:            &from,
+		FromComparator:  project.CompareOperatorGreaterThanOrEqual,
+		Until:           &until,
+		UntilComparator: project.CompareOperatorLessThanOrEqual,
+	})
+	if err != nil {
+		return nil, err
+	}
+	worked := make(map[string]int)
+	for _, slot := range slots {
+		if slot.End == nil || slot.Start.Before(start) || !slot.Start.Before(end) {
+			continue
+		}
+		minutes := int(slot.End.Sub(slot.Start).Minutes())
+		day := slot.Start.In(s.zone).Format(time.DateOnly)
+		switch slot.Activity {
+		case project.ActivityWork:
+			worked[day] += minutes
+		case project.ActivityBreak:
+			worked[day] -= minutes
+		}
+	}
+	return worked, nil
+}
+
+// computeBalances sums the days from accountStart until until, schedules are ordered by ValidFrom and the
+// first one starts the account. The days before from are only carried forward.
+func computeBalances(schedules []*WorkSchedule, worked map[string]int, accountStart, from, until time.Time, period Period) []*Balance {
+	balances := make([]*Balance, 0)
+	var current *Balance
+	total := 0
+	next := 0
+	for day := accountStart; !day.After(until); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+		for next < len(schedules) && schedules[next].ValidFrom <= date {
+			next++
+		}
+		target := schedules[next-1].TargetMinutes(day.Weekday())
+		balance := worked[date] - target
+		if day.Before(from) {
+			total += balance
+			continue
+		}
+		if id := periodID(day, period); current == nil || current.ID != id {
+			current = &Balance{ID: id, Period: period, Start: date, CarriedMinutes: total}
+			balances = append(balances, current)
+		}
+		current.End = date
+		current.TargetMinutes += target
+		current.WorkedMinutes += worked[date]
+		current.BalanceMinutes += balance
+		total += balance
+		current.TotalMinutes = total
+	}
+	return balances
+}
+
+func periodID(day time.Time, period Period) string {
+	switch period {
+	case PeriodDay:
+		return day.Format(time.DateOnly)
+	case PeriodWeek:
+		year, week := day.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	default:
+		return day.Format("2006-01")
+	}
+}
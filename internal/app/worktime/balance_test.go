@@ -0,0 +1,159 @@
+package worktime
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/go-libs/testhelper"
+	"github.com/vloryan/protrakgon/internal/app/project"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+)
+
+var cet = time.FixedZone("CET", 3600)
+
+type inMemScheduleService struct {
+	Schedules []*WorkSchedule
+}
+
+func (s *inMemScheduleService) Save(_ db.Transaction, item *WorkSchedule) error {
+	if item.ID == 0 {
+		item.ID = len(s.Schedules) + 1
+		s.Schedules = append(s.Schedules, item)
+	}
+	return nil
+}
+
+func (s *inMemScheduleService) GetAll(_ db.Transaction, _ *pagination.Page, _ *ScheduleFilter) ([]*WorkSchedule, error) {
+	return s.Schedules, nil
+}
+
+func (s *inMemScheduleService) GetByID(_ db.Transaction, _ int) (*WorkSchedule, error) {
+	return nil, nil
+}
+
+func (s *inMemScheduleService) Delete(_ db.Transaction, _ int) error {
+	return nil
+}
+
+// inMemSlotRepository returns all slots, the service selects the days itself.
+type inMemSlotRepository struct {
+	Slots []*project.Slot
+}
+
+func (r *inMemSlotRepository) Save(_ db.Transaction, _ *project.Slot) error {
+	return nil
+}
+
+func (r *inMemSlotRepository) GetByID(_ db.Transaction, _ int) (*project.Slot, error) {
+	return nil, nil
+}
+
+func (r *inMemSlotRepository) GetAll(_ db.Transaction, _ *pagination.Page, _ *project.SlotFilter) ([]*project.Slot, error) {
+	return r.Slots, nil
+}
+
+func (r *inMemSlotRepository) GetPage(_ db.Transaction, _ *db.CursorPage, _ *project.SlotFilter) ([]*project.Slot, error) {
+	return r.Slots, nil
+}
+
+func (r *inMemSlotRepository) Delete(_ db.Transaction, _ int) error {
+	return nil
+}
+
+// slot returns a slot starting at hour:minute UTC of the day in January 2025.
+func slot(activity project.Activity, day, hour, minute int, duration time.Duration) *project.Slot {
+	start := time.Date(2025, time.January, day, hour, minute, 0, 0, time.UTC)
+	return &project.Slot{ProjectID: 1, Activity: activity, Start: start, End: testhelper.Ptr(start.Add(duration))}
+}
+
+func TestBalanceService_Balances(t *testing.T) {
+	// 2025-01-13 is a Monday, FixedNow is Wednesday 2025-01-15
+	schedules := []*WorkSchedule{
+		{ID: 2, ValidFrom: "2025-01-15", WeeklyMinutes: 1200, WorkingDays: WorkingWeek},
+		{ID: 1, ValidFrom: "2025-01-13", WeeklyMinutes: 2400, WorkingDays: WorkingWeek},
+	}
+	slots := []*project.Slot{
+		slot(project.ActivityWork, 13, 7, 0, 9*time.Hour),
+		slot(project.ActivityBreak, 13, 11, 0, 30*time.Minute),
+		// Tuesday 00:30 in the zone of the account
+		slot(project.ActivityWork, 13, 23, 30, 7*time.Hour),
+		slot(project.ActivityWork, 15, 8, 0, 4*time.Hour),
+		{ProjectID: 1, Activity: project.ActivityWork, Start: time.Date(2025, time.January, 15, 13, 0, 0, 0, time.UTC)},
+		// before the account starts
+		slot(project.ActivityWork, 10, 8, 0, 8*time.Hour),
+	}
+	tests := []struct {
+		name    string
+		filter  *BalanceFilter
+		want    []*Balance
+		wantErr error
+	}{{
+		name:   "GIVEN daily report THEN subtract breaks and carry forward",
+		filter: &BalanceFilter{Period: PeriodDay},
+		want: []*Balance{
+			{ID: "2025-01-13", Period: PeriodDay, Start: "2025-01-13", End: "2025-01-13",
+				TargetMinutes: 480, WorkedMinutes: 510, BalanceMinutes: 30, TotalMinutes: 30},
+			{ID: "2025-01-14", Period: PeriodDay, Start: "2025-01-14", End: "2025-01-14",
+				TargetMinutes: 480, WorkedMinutes: 420, BalanceMinutes: -60, CarriedMinutes: 30, TotalMinutes: -30},
+			{ID: "2025-01-15", Period: PeriodDay, Start: "2025-01-15", End: "2025-01-15",
+				TargetMinutes: 240, WorkedMinutes: 240, CarriedMinutes: -30, TotalMinutes: -30},
+		},
+	}, {
+		name:   "GIVEN from after account start THEN carry balance of days before",
+		filter: &BalanceFilter{From: "2025-01-14", Until: "2025-01-19", Period: PeriodWeek},
+		want: []*Balance{
+			{ID: "2025-W03", Period: PeriodWeek, Start: "2025-01-14", End: "2025-01-19",
+				TargetMinutes: 1200, WorkedMinutes: 660, BalanceMinutes: -540, CarriedMinutes: 30, TotalMinutes: -510},
+		},
+	}, {
+		name:   "GIVEN monthly report THEN sum days until end of month",
+		filter: &BalanceFilter{From: "2025-01-01", Until: "2025-02-03"},
+		want: []*Balance{
+			{ID: "2025-01", Period: PeriodMonth, Start: "2025-01-13", End: "2025-01-31",
+				TargetMinutes: 480 + 480 + 13*240, WorkedMinutes: 1170, BalanceMinutes: 1170 - 4080, TotalMinutes: 1170 - 4080},
+			{ID: "2025-02", Period: PeriodMonth, Start: "2025-02-01", End: "2025-02-03",
+				TargetMinutes: 240, BalanceMinutes: -240, CarriedMinutes: 1170 - 4080, TotalMinutes: 1170 - 4080 - 240},
+		},
+	}, {
+		name:    "GIVEN unknown period THEN throw ErrInvalidPeriod",
+		filter:  &BalanceFilter{Period: "year"},
+		wantErr: ErrInvalidPeriod,
+	}, {
+		name:    "GIVEN until more than a year after today THEN throw ErrUntilTooLate",
+		filter:  &BalanceFilter{Until: "2026-01-16"},
+		wantErr: ErrUntilTooLate,
+	}, {
+		name:    "GIVEN invalid date THEN throw ErrInvalidDate",
+		filter:  &BalanceFilter{Until: "15.01.2025"},
+		wantErr: ErrInvalidDate,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &balanceService{
+				schedules: &inMemScheduleService{Schedules: append([]*WorkSchedule(nil), schedules...)},
+				slotRepo:  &inMemSlotRepository{Slots: slots},
+				zone:      cet,
+				now: func() time.Time {
+					return testhelper.FixedNow
+				},
+			}
+			got, err := s.Balances(nil, tt.filter)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Balances() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Balances() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("GIVEN no schedule THEN throw ErrNoSchedule", func(t *testing.T) {
+		s := NewBalanceService(&inMemScheduleService{}, &inMemSlotRepository{}, cet)
+		if _, err := s.Balances(nil, &BalanceFilter{}); !errors.Is(err, ErrNoSchedule) {
+			t.Errorf("Balances() error = %v, want ErrNoSchedule", err)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package worktime
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/vloryan/go-libs/httpx/router"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/protrakgon/internal/app/project"
+	"github.com/vloryan/protrakgon/internal/app/server"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/request"
+)
+
+// NewHandler serves the work schedules and the balance reports, the days of the slots are based on zone.
+func NewHandler(zone *time.Location) *Handler {
+	service := NewScheduleService(NewScheduleRepository())
+	return &Handler{
+		CrudHandler: &server.CrudHandler[*WorkSchedule, *ScheduleFilter]{
+			Service: service,
+			Path:    "schedule",
+			IDParam: ":scheduleID",
+			Tables:  []string{"work_schedule"},
+			NewItem: func() *WorkSchedule {
+				return &WorkSchedule{}
+			},
+			NewFilter: func(_ *http.Request) *ScheduleFilter {
+				return &ScheduleFilter{}
+			},
+			New: func(_ *http.Request) *WorkSchedule {
+				return &WorkSchedule{
+					ValidFrom:     time.Now().In(zone).Format(time.DateOnly),
+					WeeklyMinutes: 40 * 60,
+					WorkingDays:   WorkingWeek,
+				}
+			},
+			Link: func(_ *http.Request) string {
+				return "/worktime/schedule"
+			},
+		},
+		BalanceService: NewBalanceService(service, project.NewSlotRepository(), zone),
+	}
+}
+
+type Handler struct {
+	*server.CrudHandler[*WorkSchedule, *ScheduleFilter]
+	Balances       jsonapi.GenericHandler[*Balance]
+	BalanceService BalanceService
+}
+
+func (h *Handler) RegisterRoutes(route router.RouteElement) {
+	worktimeRoute := route.SubRoute("worktime")
+	h.CrudHandler.RegisterRoutes(worktimeRoute)
+	h.Balances.DocumentUpdaters = append(h.Balances.DocumentUpdaters, server.SelfLinkUpdaterInstance)
+	worktimeRoute.GET("balance", server.ETag(server.Handle(&h.Balances, h.GetBalances)))
+	server.APIDoc.Describe(worktimeRoute, http.MethodGet, "balance", &server.Operation{
+		Summary:  "Target and worked minutes with the overtime balance per day, week or month",
+		Response: server.CollectionDocument(&Balance{}),
+		Query:    &BalanceFilter{},
+	})
+}
+
+// GetBalances reports the balances of the query parameters filter[from], filter[until] (YYYY-MM-DD) and
+// filter[period] (day, week or month).
+func (h *Handler) GetBalances(req *http.Request) (data *jsonapi.DocumentData[*Balance], err error) {
+	filter := &BalanceFilter{
+		From:   request.Query(req, "filter[from]"),
+		Until:  request.Query(req, "filter[until]"),
+		Period: Period(request.Query(req, "filter[period]")),
+	}
+	if err := request.DB(req).DoTransaction(func(tx db.Transaction) error {
+		if notModified, err := server.CollectionETag(req, tx, "work_schedule", "slot"); err != nil || notModified {
+			data = jsonapi.NewDocumentData[*Balance]([]*Balance{}, "/worktime/balance")
+			return err
+		}
+		balances, err := h.BalanceService.Balances(tx, filter)
+		if err != nil {
+			return err
+		}
+		data = jsonapi.NewDocumentData[*Balance](balances, "/worktime/balance")
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,307 @@
+package worktime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/go-libs/sqlx/pagination"
+	"github.com/vloryan/protrakgon/internal/app/server/db"
+	"github.com/vloryan/protrakgon/internal/app/server/validate"
+)
+
+// WorkSchedule is the target working time from ValidFrom until the next schedule. There are no users yet,
+// so the schedules are the ones of the owner of the instance.
+type WorkSchedule struct {
+	ID int `json:"id,omitempty"`
+	// ValidFrom is the first day of the schedule, e.g. 2025-01-01.
+	ValidFrom     string   `json:"validFrom" db:"valid_from" validate:"required"`
+	WeeklyMinutes int      `json:"weeklyMinutes" db:"weekly_minutes" validate:"min=0,max=10080"`
+	WorkingDays   Weekdays `json:"workingDays" db:"working_days"`
+	Version       int      `json:"version,omitempty"`
+}
+
+func (s *WorkSchedule) SetIdentifier(id *jsonapi.ResourceIdentifierObject) {
+	if id == nil || id.Type != "worktime.schedule" {
+		log.Error().Msgf("WorkSchedule identifier object is invalid")
+		return
+	}
+	if len(id.ID) == 0 {
+		return
+	}
+	idInt, err := strconv.ParseInt(id.ID, 10, 64)
+	if err != nil {
+		log.Err(err).Msg("WorkSchedule identifier is not a valid identifier")
+		return
+	}
+	s.ID = int(idInt)
+}
+
+func (s *WorkSchedule) GetIdentifier() *jsonapi.ResourceIdentifierObject {
+	id := &jsonapi.ResourceIdentifierObject{
+		Type: "worktime.schedule",
+	}
+	if s.ID != 0 {
+		id.ID = strconv.Itoa(s.ID)
+	}
+	return id
+}
+
+func (s *WorkSchedule) GetVersion() int {
+	return s.Version
+}
+
+// Validate checks the date and requires working days for a target.
+func (s *WorkSchedule) Validate() error {
+	var errs validate.Errors
+	if _, err := time.Parse(time.DateOnly, s.ValidFrom); err != nil {
+		errs = append(errs, validate.Attribute("validFrom", "date", "must be a date like 2025-01-01"))
+	}
+	if s.WeeklyMinutes > 0 && s.WorkingDays == 0 {
+		errs = append(errs, validate.Attribute("workingDays", "required", "must not be empty if there are weekly minutes"))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// TargetMinutes returns the target of day. The weekly minutes are divided evenly by the working days,
+// the remainder is added to the first working days of the week, so the days add up to the week.
+func (s *WorkSchedule) TargetMinutes(day time.Weekday) int {
+	if !s.WorkingDays.Has(day) {
+		return 0
+	}
+	count := s.WorkingDays.Count()
+	target := s.WeeklyMinutes / count
+	if s.WorkingDays.rank(day) < s.WeeklyMinutes%count {
+		target++
+	}
+	return target
+}
+
+// Weekdays is a set of weekdays with Sunday as bit 0. Its JSON representation is a comma separated
+// list of the days from Monday, e.g. "mon,tue,wed,thu,fri".
+type Weekdays int
+
+// weekdays are the names of the days in the order of the week starting on Monday.
+var weekdays = []struct {
+	name string
+	day  time.Weekday
+}{
+	{"mon", time.Monday}, {"tue", time.Tuesday}, {"wed", time.Wednesday}, {"thu", time.Thursday},
+	{"fri", time.Friday}, {"sat", time.Saturday}, {"sun", time.Sunday},
+}
+
+// WorkingWeek are the days from Monday to Friday.
+const WorkingWeek = Weekdays(1<<time.Monday | 1<<time.Tuesday | 1<<time.Wednesday | 1<<time.Thursday | 1<<time.Friday)
+
+func (w Weekdays) Has(day time.Weekday) bool {
+	return w&(1<<day) != 0
+}
+
+func (w Weekdays) Count() int {
+	return bits.OnesCount(uint(w))
+}
+
+// rank returns the number of days of w before day in the week starting on Monday.
+func (w Weekdays) rank(day time.Weekday) int {
+	rank := 0
+	for _, weekday := range weekdays {
+		if weekday.day == day {
+			break
+		}
+		if w.Has(weekday.day) {
+			rank++
+		}
+	}
+	return rank
+}
+
+func (w Weekdays) MarshalJSON() ([]byte, error) {
+	names := make([]string, 0, len(weekdays))
+	for _, weekday := range weekdays {
+		if w.Has(weekday.day) {
+			names = append(names, weekday.name)
+		}
+	}
+	return json.Marshal(strings.Join(names, ","))
+}
+
+func (w *Weekdays) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	var days Weekdays
+	for _, name := range strings.Split(text, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, weekday := range weekdays {
+			if weekday.name == name {
+				days |= 1 << weekday.day
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown weekday: %s", name)
+		}
+	}
+	*w = days
+	return nil
+}
+
+type ScheduleFilter struct {
+	ValidFrom *string `form:"filter[validFrom]" query:"valid_from,eq"`
+}
+
+var ErrScheduleExists = errors.New("work schedule of day exists")
+
+type ScheduleService interface {
+	Save(tx db.Transaction, schedule *WorkSchedule) error
+	GetAll(tx db.Transaction, page *pagination.Page, filter *ScheduleFilter) ([]*WorkSchedule, error)
+	GetByID(tx db.Transaction, id int) (*WorkSchedule, error)
+	Delete(tx db.Transaction, id int) error
+}
+
+func NewScheduleService(repo db.CRUDRepository[*WorkSchedule, *ScheduleFilter]) ScheduleService {
+	return &scheduleService{repo: repo}
+}
+
+type scheduleService struct {
+	repo db.CRUDRepository[*WorkSchedule, *ScheduleFilter]
+}
+
+// Save rejects a second schedule starting on the same day.
+func (s *scheduleService) Save(tx db.Transaction, schedule *WorkSchedule) error {
+	existing, err := s.repo.GetAll(tx, pagination.First(), &ScheduleFilter{ValidFrom: &schedule.ValidFrom})
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 && existing[0].ID != schedule.ID {
+		return ErrScheduleExists
+	}
+	return s.repo.Save(tx, schedule)
+}
+
+func (s *scheduleService) GetAll(tx db.Transaction, page *pagination.Page, filter *ScheduleFilter) ([]*WorkSchedule, error) {
+	return s.repo.GetAll(tx, page, filter)
+}
+
+func (s *scheduleService) GetByID(tx db.Transaction, id int) (*WorkSchedule, error) {
+	return s.repo.GetByID(tx, id)
+}
+
+func (s *scheduleService) Delete(tx db.Transaction, id int) error {
+	return s.repo.Delete(tx, id)
+}
+
+type ScheduleRepository struct{}
+
+var scheduleQuery = &db.Query{
+	Table:   "work_schedule",
+	Columns: []string{"id", "valid_from", "weekly_minutes", "working_days", "version"},
+	SortFields: map[string]string{
+		"id":            "id",
+		"validFrom":     "valid_from",
+		"weeklyMinutes": "weekly_minutes",
+	},
+	DefaultSort: []string{"validFrom"},
+}
+
+func NewScheduleRepository() db.CRUDRepository[*WorkSchedule, *ScheduleFilter] {
+	return &ScheduleRepository{}
+}
+
+func (r *ScheduleRepository) Save(tx db.Transaction, item *WorkSchedule) error {
+	if item.ID == 0 {
+		stmt := `INSERT INTO work_schedule (valid_from, weekly_minutes, working_days)
+				  VALUES (:validFrom, :weeklyMinutes, :workingDays)`
+
+		result, err := tx.Exec(stmt, item)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		item.ID = int(id)
+		item.Version = 1
+		return nil
+	}
+	stmt := `UPDATE work_schedule
+			    SET
+			        valid_from     = :validFrom,
+			        weekly_minutes = :weeklyMinutes,
+			        working_days   = :workingDays,
+			        version        = version + 1
+			  WHERE
+			        id = :id
+			    AND version = :version`
+
+	result, err := tx.Exec(stmt, item)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return db.UpdateError(tx, "work_schedule", item.ID)
+	}
+	item.Version++
+	return nil
+}
+
+func (r *ScheduleRepository) GetByID(tx db.Transaction, id int) (*WorkSchedule, error) {
+	item := &WorkSchedule{}
+	stmt := `SELECT id, valid_from, weekly_minutes, working_days, version
+			   FROM work_schedule
+			  WHERE id = :id`
+
+	if err := tx.Select(item, stmt, map[string]any{"id": id}); err != nil {
+		return nil, err
+	}
+	if item.ID == 0 {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (r *ScheduleRepository) GetAll(tx db.Transaction, page *pagination.Page, filter *ScheduleFilter) ([]*WorkSchedule, error) {
+	items := make([]*WorkSchedule, 0, 10)
+	if err := scheduleQuery.GetAll(tx, &items, page, filter); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *ScheduleRepository) Delete(tx db.Transaction, id int) error {
+	stmt := `DELETE
+               FROM work_schedule
+              WHERE id = :id`
+
+	result, err := tx.Exec(stmt, map[string]any{"id": id})
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
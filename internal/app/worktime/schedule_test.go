@@ -0,0 +1,88 @@
+package worktime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWorkSchedule_TargetMinutes(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule *WorkSchedule
+		want     map[time.Weekday]int
+	}{{
+		name:     "GIVEN working week THEN divide evenly",
+		schedule: &WorkSchedule{WeeklyMinutes: 2310, WorkingDays: WorkingWeek},
+		want: map[time.Weekday]int{
+			time.Monday: 462, time.Tuesday: 462, time.Wednesday: 462, time.Thursday: 462, time.Friday: 462,
+		},
+	}, {
+		name:     "GIVEN remainder THEN add it to the first days of the week",
+		schedule: &WorkSchedule{WeeklyMinutes: 1202, WorkingDays: 1<<time.Sunday | 1<<time.Monday | 1<<time.Wednesday},
+		want:     map[time.Weekday]int{time.Monday: 401, time.Wednesday: 401, time.Sunday: 400},
+	}, {
+		name:     "GIVEN no working days THEN no target",
+		schedule: &WorkSchedule{},
+		want:     map[time.Weekday]int{},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			week := 0
+			for day := time.Sunday; day <= time.Saturday; day++ {
+				week += tt.schedule.TargetMinutes(day)
+				if got := tt.schedule.TargetMinutes(day); got != tt.want[day] {
+					t.Errorf("TargetMinutes(%s) = %d, want %d", day, got, tt.want[day])
+				}
+			}
+			if week != tt.schedule.WeeklyMinutes {
+				t.Errorf("TargetMinutes() of week = %d, want %d", week, tt.schedule.WeeklyMinutes)
+			}
+		})
+	}
+}
+
+func TestWeekdays_JSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    Weekdays
+		wantOut string
+		wantErr bool
+	}{{
+		name:    "GIVEN days THEN marshal them from monday",
+		json:    `"sun, MON,tue,wed,thu,fri"`,
+		want:    WorkingWeek | 1<<time.Sunday,
+		wantOut: `"mon,tue,wed,thu,fri,sun"`,
+	}, {
+		name:    "GIVEN empty list THEN no days",
+		json:    `""`,
+		wantOut: `""`,
+	}, {
+		name:    "GIVEN unknown day THEN fail",
+		json:    `"mon,funday"`,
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Weekdays
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("UnmarshalJSON() = %b, want %b", got, tt.want)
+			}
+			out, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("MarshalJSON() error = %v", err)
+			}
+			if string(out) != tt.wantOut {
+				t.Errorf("MarshalJSON() = %s, want %s", out, tt.wantOut)
+			}
+		})
+	}
+}
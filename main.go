@@ -2,16 +2,22 @@ package main
 
 import (
 	"embed"
+	"errors"
+	"flag"
+	"fmt"
 	"io/fs"
 	"os"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"github.com/vloryan/go-libs/env"
 	"github.com/vloryan/go-libs/jsonapi"
+	"github.com/vloryan/protrakgon/internal/app/admin"
+	"github.com/vloryan/protrakgon/internal/app/cli"
 	"github.com/vloryan/protrakgon/internal/app/client"
+	"github.com/vloryan/protrakgon/internal/app/config"
 	"github.com/vloryan/protrakgon/internal/app/project"
 	"github.com/vloryan/protrakgon/internal/app/server"
+	"github.com/vloryan/protrakgon/internal/app/worktime"
 )
 
 //go:embed assets/*
@@ -21,13 +27,22 @@ var embedDir embed.FS
 var uiDistDir embed.FS
 
 func main() {
-	debug := env.GetOrDefaultBool("DEBUG", false)
-	contextRoot := env.GetOrDefault("CONTEXT_ROOT", "/")
-	proxyLocation := env.GetOrDefault("PROXY_LOCATION", "/")
-	apiRoutePrefix := env.GetOrDefault("API_ROUTE_PREFIX", "/v1")
-	InitLog(debug)
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+	}
+	cfg, args, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stdout)
+		_, _ = fmt.Fprint(os.Stdout, admin.Usage)
+		os.Exit(0)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "protrakgon: invalid configuration:", err)
+		os.Exit(2)
+	}
+	InitLog(cfg)
 	var assetDir fs.FS
-	if debug {
+	if cfg.Debug {
 		assetDir = os.DirFS("assets")
 	} else {
 		subFs, err := fs.Sub(embedDir, "assets")
@@ -43,34 +58,73 @@ func main() {
 		panic(err)
 	}
 
+	backups := server.NewBackups(cfg.Backup.Dir, cfg.Backup.Keep)
 	srv := server.New().
-		WithContextRoot(contextRoot).
-		WithProxyLocation(proxyLocation).
-		WithApiRoutePrefix(apiRoutePrefix).
+		WithListenAddress(cfg.Listen).
+		WithShutdownTimeout(cfg.ShutdownTimeout).
+		WithSlowQueryThreshold(cfg.Log.SlowQuery).
+		WithRateLimit(&server.RateLimit{
+			PerMinute:  cfg.Limits.RequestsPerMinute,
+			Burst:      cfg.Limits.Burst,
+			TrustProxy: cfg.Limits.TrustProxy,
+			AdminToken: cfg.AdminToken,
+		}).
+		WithBodyLimits(cfg.Limits.MaxBodySize, cfg.Limits.MaxUploadSize).
+		WithCORS(&server.CORSOptions{
+			AllowedOrigins:   cfg.CORS.AllowedOrigins,
+			AllowedMethods:   cfg.CORS.AllowedMethods,
+			AllowedHeaders:   cfg.CORS.AllowedHeaders,
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           cfg.CORS.MaxAge,
+		}).
+		WithCSRF(&server.CSRFOptions{SessionCookie: cfg.CSRF.SessionCookie, Secret: []byte(cfg.CSRF.Secret)}).
+		WithTLS(&server.TLSOptions{
+			CertFile:        cfg.TLS.CertFile,
+			KeyFile:         cfg.TLS.KeyFile,
+			SelfSigned:      cfg.TLS.SelfSigned,
+			Hosts:           cfg.TLS.Hosts,
+			RedirectAddress: cfg.TLS.RedirectListen,
+			HSTSMaxAge:      cfg.TLS.HSTSMaxAge,
+		}).
+		WithContextRoot(cfg.ContextRoot).
+		WithProxyLocation(cfg.ProxyLocation).
+		WithApiRoutePrefix(cfg.APIRoutePrefix).
 		WithModule(func() server.Module {
-			return server.NewJsonAPIModule(domainHandler())
+			return server.NewJsonAPIModule(domainHandler(cfg)).
+				WithExtension(server.NewAtomicOperations()).
+				WithExtension(server.NewSearch()).
+				WithExtension(server.NewBackupEndpoint(backups, cfg.AdminToken))
 		}).
 		WithAssets(assetDir).
 		WithUISrc(uiSrc).
-		WithDBFile("./db/protrakgon.db")
+		WithDBFile(cfg.DBFile).
+		WithBackups(backups, cfg.Backup.Interval)
 
-	if err := srv.Run(); err != nil {
-		panic(err)
+	if cfg.Tracing.OTLPEndpoint != "" {
+		srv.WithTracer(server.NewTracer(cfg.Tracing.OTLPEndpoint, cfg.Tracing.ServiceName))
+	}
+
+	if err := admin.Run(srv, args, os.Stdout); err != nil {
+		if errors.Is(err, admin.ErrUsage) {
+			os.Exit(2)
+		}
+		_, _ = fmt.Fprintln(os.Stderr, "protrakgon:", err)
+		os.Exit(1)
 	}
 }
 
-func domainHandler() []jsonapi.ResourceHandler {
+func domainHandler(cfg *config.Config) []jsonapi.ResourceHandler {
 	var handlers []jsonapi.ResourceHandler
 	handlers = append(handlers, client.Handlers()...)
-	handlers = append(handlers, project.Handlers()...)
+	handlers = append(handlers, project.Handlers(project.Options{Zone: cfg.Location(), AdminToken: cfg.AdminToken})...)
+	handlers = append(handlers, worktime.Handlers(cfg.Location())...)
 
 	return handlers
 }
 
-func InitLog(debug bool) {
-	zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	if debug {
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+func InitLog(cfg *config.Config) {
+	zerolog.SetGlobalLevel(cfg.LogLevel())
+	if cfg.LogFormat() == config.LogFormatConsole {
 		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	} else {
 		zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vloryan/go-libs/httpx/router"
+	"github.com/vloryan/protrakgon/internal/app/config"
+	"github.com/vloryan/protrakgon/internal/app/server"
+)
+
+func TestOpenAPI(t *testing.T) {
+	module := server.NewJsonAPIModule(domainHandler(&config.Config{})).
+		WithExtension(server.NewAtomicOperations()).
+		WithExtension(server.NewBackupEndpoint(server.NewBackups(t.TempDir(), 1), ""))
+	module.Setup(router.NewRoute("/v1", func(_, _ string, _ http.HandlerFunc) {}))
+
+	if missing := server.APIDoc.Undocumented(); len(missing) > 0 {
+		t.Errorf("routes without schema: %v", missing)
+	}
+
+	rec := httptest.NewRecorder()
+	server.APIDoc.Serve(rec, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	doc := struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Serve() invalid document: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("Serve() openapi = %s, want 3.1.0", doc.OpenAPI)
+	}
+	for _, p := range []string{"/client", "/client/{clientID}", "/project/{projectID}/slot/{slotID}", "/project/timesheet/{timesheetID}", "/worktime/balance", "/operations", "/openapi.json"} {
+		if _, ok := doc.Paths[p]; !ok {
+			t.Errorf("Serve() missing path %s", p)
+		}
+	}
+}